@@ -3,29 +3,78 @@
 
 package netscape
 
+import "fmt"
+
+// A Position represents a location in a Netscape Bookmark document.
+type Position struct {
+	// Offset in bytes, starting at 0.
+	Offset int64
+
+	// Line number, starting at 1.
+	Line int
+
+	// Column number in bytes, starting at 1.
+	Column int
+}
+
+// String returns the string representation for this Position.
+func (p Position) String() string {
+	return fmt.Sprintf("line %d, column %d", p.Line, p.Column)
+}
+
+// A Span represents the range of a Netscape Bookmark document a node was
+// parsed from.
+type Span struct {
+	Start Position
+	End   Position
+}
+
 // A FileNode represents a Netscape Bookmark file.
 type FileNode struct {
+	Span Span
+
 	Title string
 	Root  FolderNode
 }
 
-// A FolderNode represents a bookmark (sub-)folder that may contain Bookmarks
-// and child Folders.
+// A FolderNode represents a bookmark (sub-)folder that may contain Bookmarks,
+// Separators and child Folders.
+//
+// The Span of a FolderNode starts with its <H1> or <H3> element, and ends with
+// the </DL> element closing its content.
 type FolderNode struct {
 	Parent *FolderNode
+	Span   Span
 
 	Name        string
 	Description string
 	Attributes  map[string]string
 
 	Bookmarks  []BookmarkNode
+	Separators []SeparatorNode
 	Subfolders []FolderNode
+
+	// Order lists the Bookmarks, Separators and Subfolders of this
+	// FolderNode in the order they appear in the document.
+	Order []ItemRef
 }
 
 // A BookmarkNode represents a Netscape bookmark.
+//
+// The Span of a BookmarkNode starts with its <A> element, and ends with its
+// description, if any.
 type BookmarkNode struct {
+	Span Span
+
 	Href        string
 	Title       string
 	Description string
 	Attributes  map[string]string
 }
+
+// A SeparatorNode represents a separator (<HR> element) between bookmarks.
+type SeparatorNode struct {
+	Span Span
+
+	Attributes map[string]string
+}
@@ -0,0 +1,50 @@
+// Copyright (c) VirtualTam
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/virtualtam/netscape-go/v2"
+)
+
+func main() {
+	jsonOutput := flag.Bool("json", false, "print changes as JSON")
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		log.Fatal("usage: diff [-json] OLD_FILE NEW_FILE")
+	}
+
+	oldDocument, err := netscape.UnmarshalFile(flag.Arg(0))
+	if err != nil {
+		fmt.Println("failed to unmarshal old file:", err)
+		os.Exit(1)
+	}
+
+	newDocument, err := netscape.UnmarshalFile(flag.Arg(1))
+	if err != nil {
+		fmt.Println("failed to unmarshal new file:", err)
+		os.Exit(1)
+	}
+
+	changeset := netscape.Diff(oldDocument, newDocument)
+
+	if !*jsonOutput {
+		fmt.Print(changeset.String())
+		return
+	}
+
+	jsonData, err := json.MarshalIndent(&changeset, "", "  ")
+	if err != nil {
+		fmt.Println("failed to marshal changeset:", err)
+		os.Exit(1)
+	}
+
+	fmt.Println(string(jsonData))
+}
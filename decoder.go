@@ -4,11 +4,19 @@
 package netscape
 
 import (
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"html"
+	"maps"
+	"net/url"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/virtualtam/netscape-go/v2/normalize"
 )
 
 const (
@@ -16,6 +24,70 @@ const (
 	updatedAtAttr string = "LAST_MODIFIED"
 	privateAttr   string = "PRIVATE"
 	tagsAttr      string = "TAGS"
+
+	lastVisitAttr string = "LAST_VISIT"
+	iconAttr      string = "ICON"
+	iconURIAttr   string = "ICON_URI"
+	keywordAttr   string = "SHORTCUTURL"
+	charsetAttr   string = "LAST_CHARSET"
+	postDataAttr  string = "POST_DATA"
+
+	toolbarFolderAttr string = "PERSONAL_TOOLBAR_FOLDER"
+	unfiledFolderAttr string = "UNFILED_BOOKMARKS_FOLDER"
+	foldedAttr        string = "FOLDED"
+)
+
+// wellKnownFolderRoles maps the (lowercase) names given to special folders by
+// Web browsers in various languages, to the corresponding FolderRole.
+//
+// Some browsers do not mark these folders with a dedicated attribute, in which
+// case their role can be guessed from their name, see WithFolderRoleGuessing.
+var wellKnownFolderRoles = map[string]FolderRole{
+	// Toolbar
+	"bookmarks bar":            FolderRoleToolbar,
+	"bookmarks toolbar":        FolderRoleToolbar,
+	"favorites":                FolderRoleToolbar,
+	"favorites bar":            FolderRoleToolbar,
+	"barre de favoris":         FolderRoleToolbar,
+	"barre personnelle":        FolderRoleToolbar,
+	"barre des marque-pages":   FolderRoleToolbar,
+	"favoris":                  FolderRoleToolbar,
+	"favoriten":                FolderRoleToolbar,
+	"lesezeichenleiste":        FolderRoleToolbar,
+	"lesezeichen-symbolleiste": FolderRoleToolbar,
+	"barra de marcadores":      FolderRoleToolbar,
+	"favoritos":                FolderRoleToolbar,
+
+	// Menu
+	"bookmarks menu":        FolderRoleMenu,
+	"menu signets":          FolderRoleMenu,
+	"menu des marque-pages": FolderRoleMenu,
+	"lesezeichen-menü":      FolderRoleMenu,
+	"menú de marcadores":    FolderRoleMenu,
+
+	// Unfiled
+	"other bookmarks":          FolderRoleUnfiled,
+	"unsorted bookmarks":       FolderRoleUnfiled,
+	"autres favoris":           FolderRoleUnfiled,
+	"autres marque-pages":      FolderRoleUnfiled,
+	"marque-pages non classés": FolderRoleUnfiled,
+	"weitere lesezeichen":      FolderRoleUnfiled,
+	"andere lesezeichen":       FolderRoleUnfiled,
+	"otros marcadores":         FolderRoleUnfiled,
+	"otros favoritos":          FolderRoleUnfiled,
+
+	// Mobile
+	"mobile bookmarks":     FolderRoleMobile,
+	"favoris sur mobile":   FolderRoleMobile,
+	"marque-pages mobiles": FolderRoleMobile,
+	"mobile lesezeichen":   FolderRoleMobile,
+	"marcadores del móvil": FolderRoleMobile,
+	"favoritos del móvil":  FolderRoleMobile,
+}
+
+var (
+	ErrDateInvalid = errors.New("invalid date")
+	ErrIconInvalid = errors.New("invalid icon")
 )
 
 // Decode walks a Netscape Bookmark AST and returns the corresponding document.
@@ -29,18 +101,154 @@ func Decode(f FileNode) (*Document, error) {
 type Decoder struct {
 	now     time.Time
 	maxTime time.Time
+	mode    Mode
+
+	clock          func() time.Time
+	location       *time.Location
+	maxFutureRange time.Duration
+	layouts        []string
+	tagSeparator   string
+	urlNormalizer  *normalize.Normalizer
+	guessRoles     bool
+
+	diagnostics []Diagnostic
+}
+
+// A DecoderOption configures a Decoder.
+type DecoderOption func(*Decoder)
+
+// WithMode sets how the Decoder handles invalid attribute values.
+//
+// In Lenient mode, attributes that cannot be decoded are kept verbatim in the
+// Attributes of the corresponding Bookmark or Folder, and reported as
+// Diagnostics.
+func WithMode(mode Mode) DecoderOption {
+	return func(d *Decoder) {
+		d.mode = mode
+	}
+}
+
+// WithLocation sets the location used to interpret dates that do not specify
+// a time zone, such as dates matching a layout passed to WithDateLayouts.
+//
+// Defaults to UTC, which is also used if location is nil.
+func WithLocation(location *time.Location) DecoderOption {
+	return func(d *Decoder) {
+		if location == nil {
+			location = time.UTC
+		}
+
+		d.location = location
+	}
+}
+
+// WithMaxFutureRange sets how far in the future a UNIX timestamp may be before
+// the Decoder attempts to interpret it with a finer precision (milliseconds,
+// microseconds, then nanoseconds).
+//
+// Defaults to 30 years.
+func WithMaxFutureRange(maxFutureRange time.Duration) DecoderOption {
+	return func(d *Decoder) {
+		d.maxFutureRange = maxFutureRange
+	}
+}
+
+// WithDateLayouts adds layouts, as accepted by time.Parse, that are tried in
+// order when a date is neither a UNIX timestamp, nor a RFC3339 or Common Log
+// date.
+func WithDateLayouts(layouts ...string) DecoderOption {
+	return func(d *Decoder) {
+		d.layouts = append(d.layouts, layouts...)
+	}
+}
+
+// WithTagSeparator sets the separator used to split the TAGS attribute of
+// bookmarks.
+//
+// If the separator only contains whitespace, tags are separated by any amount
+// of whitespace. Defaults to ",".
+func WithTagSeparator(separator string) DecoderOption {
+	return func(d *Decoder) {
+		d.tagSeparator = separator
+	}
+}
+
+// WithClock sets the function used to get the current time, from which the
+// maximum future range is computed.
+//
+// Defaults to time.Now.
+func WithClock(clock func() time.Time) DecoderOption {
+	return func(d *Decoder) {
+		d.clock = clock
+	}
+}
+
+// WithURLNormalizer sets the Normalizer used to normalize the URLs of
+// bookmarks. URLs that cannot be normalized are kept verbatim.
+//
+// By default, URLs are kept verbatim.
+func WithURLNormalizer(normalizer *normalize.Normalizer) DecoderOption {
+	return func(d *Decoder) {
+		d.urlNormalizer = normalizer
+	}
+}
+
+// WithFolderRoleGuessing sets whether the Decoder assigns a role to the Root
+// Folder and top-level folders that are not marked with a role attribute,
+// based on their name, e.g. "Bookmarks bar" or "Favoris".
+//
+// As the Encoder writes roles as Firefox attributes, guessed roles are added to
+// the output when marshaling the document back. Defaults to false.
+func WithFolderRoleGuessing(enabled bool) DecoderOption {
+	return func(d *Decoder) {
+		d.guessRoles = enabled
+	}
 }
 
 // NewDecoder initializes and returns a new Decoder.
-func NewDecoder() *Decoder {
-	now := time.Now().UTC()
-	rangeYears := 30
-	maxTime := now.AddDate(rangeYears, 0, 0)
+func NewDecoder(opts ...DecoderOption) *Decoder {
+	d := &Decoder{
+		clock:        time.Now,
+		location:     time.UTC,
+		tagSeparator: ",",
+	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	d.now = d.clock().UTC()
 
-	return &Decoder{
-		now:     now,
-		maxTime: maxTime,
+	if d.maxFutureRange > 0 {
+		d.maxTime = d.now.Add(d.maxFutureRange)
+	} else {
+		rangeYears := 30
+		d.maxTime = d.now.AddDate(rangeYears, 0, 0)
 	}
+
+	return d
+}
+
+// Decode walks a Netscape Bookmark AST and returns the corresponding document.
+func (d *Decoder) Decode(f FileNode) (*Document, error) {
+	return d.decodeFile(f)
+}
+
+// Diagnostics returns the problems found by the Decoder in Lenient mode.
+func (d *Decoder) Diagnostics() []Diagnostic {
+	return d.diagnostics
+}
+
+// recover reports whether the Decoder can recover from an error raised when
+// decoding the value of attr, in which case a Diagnostic is recorded.
+func (d *Decoder) recover(pos Position, attr string, err error) bool {
+	if d.mode != Lenient {
+		return false
+	}
+
+	d.diagnostics = append(d.diagnostics, newDiagnostic(SeverityWarning, pos, fmt.Sprintf("kept undecodable %s attribute", attr), err))
+
+	return true
 }
 
 func (d *Decoder) decodeFile(f FileNode) (*Document, error) {
@@ -54,40 +262,68 @@ func (d *Decoder) decodeFile(f FileNode) (*Document, error) {
 	}
 	document.Root = root
 
+	if d.guessRoles {
+		d.guessFolderRoles(&document)
+	}
+
 	return &document, nil
 }
 
-func (d *Decoder) decodeFolder(f FolderNode) (Folder, error) {
-	folder := Folder{
-		Name:        f.Name,
-		Description: f.Description,
+// guessFolderRoles assigns roles to the top-level folders that are not marked
+// with a role attribute, based on their name.
+func (d *Decoder) guessFolderRoles(document *Document) {
+	roles := make(assignedRoles)
+	for _, role := range []FolderRole{FolderRoleToolbar, FolderRoleMenu, FolderRoleUnfiled, FolderRoleMobile} {
+		roles[role] = document.FolderByRole(role) != nil
 	}
 
-	for attr, value := range f.Attributes {
-		switch attr {
-		case createdAtAttr:
-			createdAt, err := d.decodeDate(value)
-			if err != nil {
-				return Folder{}, err
-			}
-			folder.CreatedAt = createdAt
-			if folder.UpdatedAt.IsZero() {
-				folder.UpdatedAt = createdAt
-			}
-		case updatedAtAttr:
-			updatedAt, err := d.decodeDate(value)
-			if err != nil {
-				return Folder{}, err
-			}
-			folder.UpdatedAt = updatedAt
-		default:
-			if folder.Attributes == nil {
-				folder.Attributes = make(map[string]string, len(f.Attributes))
-			}
-			folder.Attributes[attr] = value
-		}
+	roles.guess(&document.Root, 0)
+
+	for index := range document.Root.Subfolders {
+		roles.guess(&document.Root.Subfolders[index], 1)
+	}
+}
+
+// assignedRoles records the folder roles assigned in a document.
+type assignedRoles map[FolderRole]bool
+
+// guess assigns a role to a folder located at the given depth, based on its
+// name, unless it is already marked with a role attribute.
+//
+// Only the Root Folder and top-level folders are considered, and the Root
+// Folder can only be recognized as the bookmarks menu, as exported by Firefox.
+// Each role is assigned at most once per document.
+func (a assignedRoles) guess(folder *Folder, depth int) {
+	if folder.Role != FolderRoleNone {
+		a[folder.Role] = true
+		return
+	}
+
+	if depth > 1 {
+		return
+	}
+
+	role := wellKnownFolderRoles[strings.ToLower(strings.TrimSpace(folder.Name))]
+
+	switch {
+	case role == FolderRoleNone || a[role]:
+		return
+	case depth == 0 && role != FolderRoleMenu:
+		return
 	}
 
+	folder.Role = role
+	a[role] = true
+}
+
+func (d *Decoder) decodeFolder(f FolderNode) (Folder, error) {
+	folder, err := d.decodeFolderHeader(f)
+	if err != nil {
+		return Folder{}, err
+	}
+
+	folder.Order = slices.Clone(f.Order)
+
 	if len(f.Bookmarks) > 0 {
 		folder.Bookmarks = make([]Bookmark, 0, len(f.Bookmarks))
 	}
@@ -100,6 +336,15 @@ func (d *Decoder) decodeFolder(f FolderNode) (Folder, error) {
 		folder.Bookmarks = append(folder.Bookmarks, bookmark)
 	}
 
+	if len(f.Separators) > 0 {
+		folder.Separators = make([]Separator, 0, len(f.Separators))
+	}
+	for _, s := range f.Separators {
+		folder.Separators = append(folder.Separators, Separator{
+			Attributes: maps.Clone(s.Attributes),
+		})
+	}
+
 	if len(f.Subfolders) > 0 {
 		folder.Subfolders = make([]Folder, 0, len(f.Subfolders))
 	}
@@ -115,6 +360,63 @@ func (d *Decoder) decodeFolder(f FolderNode) (Folder, error) {
 	return folder, nil
 }
 
+// decodeFolderHeader returns the Folder corresponding to the name, description
+// and attributes of f, without its bookmarks, separators and subfolders.
+func (d *Decoder) decodeFolderHeader(f FolderNode) (Folder, error) {
+	folder := Folder{
+		Name:        f.Name,
+		Description: html.UnescapeString(f.Description),
+	}
+
+	for attr, value := range f.Attributes {
+		switch attr {
+		case createdAtAttr:
+			createdAt, err := d.decodeDate(value)
+			if err != nil {
+				if !d.recover(f.Span.Start, attr, err) {
+					return Folder{}, err
+				}
+				folder.setAttribute(attr, value, len(f.Attributes))
+				continue
+			}
+			folder.CreatedAt = createdAt
+			if folder.UpdatedAt.IsZero() {
+				folder.UpdatedAt = createdAt
+			}
+		case updatedAtAttr:
+			updatedAt, err := d.decodeDate(value)
+			if err != nil {
+				if !d.recover(f.Span.Start, attr, err) {
+					return Folder{}, err
+				}
+				folder.setAttribute(attr, value, len(f.Attributes))
+				continue
+			}
+			folder.UpdatedAt = updatedAt
+		case toolbarFolderAttr:
+			if !strings.EqualFold(value, "true") {
+				folder.setAttribute(attr, value, len(f.Attributes))
+				continue
+			}
+			folder.Role = FolderRoleToolbar
+		case unfiledFolderAttr:
+			if !strings.EqualFold(value, "true") {
+				folder.setAttribute(attr, value, len(f.Attributes))
+				continue
+			}
+			if folder.Role == FolderRoleNone {
+				folder.Role = FolderRoleUnfiled
+			}
+		case foldedAttr:
+			folder.Folded = true
+		default:
+			folder.setAttribute(attr, value, len(f.Attributes))
+		}
+	}
+
+	return folder, nil
+}
+
 func (d *Decoder) decodeBookmark(b BookmarkNode) (Bookmark, error) {
 	bookmark := Bookmark{
 		Description: html.UnescapeString(b.Description),
@@ -122,12 +424,22 @@ func (d *Decoder) decodeBookmark(b BookmarkNode) (Bookmark, error) {
 		Title:       b.Title,
 	}
 
+	if d.urlNormalizer != nil {
+		if normalized, err := d.urlNormalizer.Normalize(b.Href); err == nil {
+			bookmark.URL = normalized
+		}
+	}
+
 	for attr, value := range b.Attributes {
 		switch attr {
 		case createdAtAttr:
 			createdAt, err := d.decodeDate(value)
 			if err != nil {
-				return Bookmark{}, err
+				if !d.recover(b.Span.Start, attr, err) {
+					return Bookmark{}, err
+				}
+				bookmark.setAttribute(attr, value, len(b.Attributes))
+				continue
 			}
 			bookmark.CreatedAt = createdAt
 			if bookmark.UpdatedAt.IsZero() {
@@ -136,7 +448,11 @@ func (d *Decoder) decodeBookmark(b BookmarkNode) (Bookmark, error) {
 		case updatedAtAttr:
 			updatedAt, err := d.decodeDate(value)
 			if err != nil {
-				return Bookmark{}, err
+				if !d.recover(b.Span.Start, attr, err) {
+					return Bookmark{}, err
+				}
+				bookmark.setAttribute(attr, value, len(b.Attributes))
+				continue
 			}
 			bookmark.UpdatedAt = updatedAt
 		case privateAttr:
@@ -145,11 +461,36 @@ func (d *Decoder) decodeBookmark(b BookmarkNode) (Bookmark, error) {
 			}
 		case tagsAttr:
 			bookmark.Tags = d.decodeTags(b.Attributes)
-		default:
-			if bookmark.Attributes == nil {
-				bookmark.Attributes = make(map[string]string, len(b.Attributes))
+
+		// Browser-specific attributes have historically been kept verbatim;
+		// values that cannot be decoded are still kept as is, and only
+		// reported in Lenient mode.
+		case lastVisitAttr:
+			lastVisitedAt, err := d.decodeDate(value)
+			if err != nil {
+				d.recover(b.Span.Start, attr, err)
+				bookmark.setAttribute(attr, value, len(b.Attributes))
+				continue
+			}
+			bookmark.LastVisitedAt = lastVisitedAt
+		case iconAttr:
+			icon, err := d.decodeIcon(value)
+			if err != nil {
+				d.recover(b.Span.Start, attr, err)
+				bookmark.setAttribute(attr, value, len(b.Attributes))
+				continue
 			}
-			bookmark.Attributes[attr] = value
+			bookmark.Icon = icon
+		case iconURIAttr:
+			bookmark.IconURI = value
+		case keywordAttr:
+			bookmark.Keyword = value
+		case charsetAttr:
+			bookmark.Charset = value
+		case postDataAttr:
+			bookmark.PostData = value
+		default:
+			bookmark.setAttribute(attr, value, len(b.Attributes))
 		}
 	}
 
@@ -162,7 +503,13 @@ func (d *Decoder) decodeTags(attr map[string]string) []string {
 		return []string{}
 	}
 
-	tags := strings.Split(rawTags, ",")
+	var tags []string
+	if strings.TrimSpace(d.tagSeparator) == "" {
+		tags = strings.Fields(rawTags)
+	} else {
+		tags = strings.Split(rawTags, d.tagSeparator)
+	}
+
 	for index, tag := range tags {
 		tags[index] = strings.TrimSpace(tag)
 	}
@@ -192,7 +539,48 @@ func (d *Decoder) decodeDate(input string) (time.Time, error) {
 		return date, nil
 	}
 
-	return time.Time{}, err
+	// Attempt to parse the date with user-provided layouts
+	for _, layout := range d.layouts {
+		date, err = time.ParseInLocation(layout, input, d.location)
+		if err == nil {
+			return date.UTC(), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("%w %q: %w", ErrDateInvalid, input, err)
+}
+
+// decodeIcon returns the Icon corresponding to a data URI, as specified by
+// RFC 2397.
+func (d *Decoder) decodeIcon(input string) (Icon, error) {
+	const scheme = "data:"
+
+	if len(input) < len(scheme) || !strings.EqualFold(input[:len(scheme)], scheme) {
+		return Icon{}, fmt.Errorf("%w: not a data URI", ErrIconInvalid)
+	}
+
+	header, data, ok := strings.Cut(input[len(scheme):], ",")
+	if !ok {
+		return Icon{}, fmt.Errorf("%w: missing data", ErrIconInvalid)
+	}
+
+	mediaType, isBase64 := strings.CutSuffix(header, ";base64")
+
+	if isBase64 {
+		decoded, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return Icon{}, fmt.Errorf("%w: %w", ErrIconInvalid, err)
+		}
+
+		return Icon{MediaType: mediaType, Data: decoded, dataURI: input}, nil
+	}
+
+	decoded, err := url.PathUnescape(data)
+	if err != nil {
+		return Icon{}, fmt.Errorf("%w: %w", ErrIconInvalid, err)
+	}
+
+	return Icon{MediaType: mediaType, Data: []byte(decoded), URLEncoded: true, dataURI: input}, nil
 }
 
 const (
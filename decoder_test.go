@@ -4,13 +4,18 @@
 package netscape
 
 import (
+	"errors"
+	"slices"
 	"testing"
 	"time"
+
+	"github.com/virtualtam/netscape-go/v2/normalize"
 )
 
 func TestDecodeFile(t *testing.T) {
 	cases := []struct {
 		tname string
+		opts  []DecoderOption
 		file  FileNode
 		want  Document
 	}{
@@ -56,11 +61,109 @@ func TestDecodeFile(t *testing.T) {
 				},
 			},
 		},
+		{
+			tname: "document with localized special folders",
+			opts:  []DecoderOption{WithFolderRoleGuessing(true)},
+			file: FileNode{
+				Title: "Signets",
+				Root: FolderNode{
+					Name: "Signets",
+					Subfolders: []FolderNode{
+						{Name: "Favoris"},
+						{Name: "Menu Signets"},
+						{
+							Name: "Misc",
+							Subfolders: []FolderNode{
+								{Name: "Other Bookmarks"},
+							},
+						},
+					},
+				},
+			},
+			want: Document{
+				Title: "Signets",
+				Root: Folder{
+					Name: "Signets",
+					Subfolders: []Folder{
+						{Name: "Favoris", Role: FolderRoleToolbar},
+						{Name: "Menu Signets", Role: FolderRoleMenu},
+						{
+							Name: "Misc",
+							Subfolders: []Folder{
+								{Name: "Other Bookmarks"},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			tname: "document with marked and localized special folders",
+			opts:  []DecoderOption{WithFolderRoleGuessing(true)},
+			file: FileNode{
+				Title: "Bookmarks",
+				Root: FolderNode{
+					Name: "Bookmarks Menu",
+					Subfolders: []FolderNode{
+						{Name: "Favorites"},
+						{
+							Name: "Bookmarks Toolbar",
+							Attributes: map[string]string{
+								"PERSONAL_TOOLBAR_FOLDER": "true",
+							},
+						},
+						{Name: "Other Bookmarks"},
+					},
+				},
+			},
+			want: Document{
+				Title: "Bookmarks",
+				Root: Folder{
+					Name: "Bookmarks Menu",
+					Role: FolderRoleMenu,
+					Subfolders: []Folder{
+						{Name: "Favorites"},
+						{Name: "Bookmarks Toolbar", Role: FolderRoleToolbar},
+						{Name: "Other Bookmarks", Role: FolderRoleUnfiled},
+					},
+				},
+			},
+		},
+		{
+			tname: "document with marked special folders and without role guessing",
+			file: FileNode{
+				Title: "Bookmarks",
+				Root: FolderNode{
+					Name: "Bookmarks Menu",
+					Subfolders: []FolderNode{
+						{Name: "Favorites"},
+						{
+							Name: "Bookmarks Toolbar",
+							Attributes: map[string]string{
+								"PERSONAL_TOOLBAR_FOLDER": "true",
+							},
+						},
+						{Name: "Other Bookmarks"},
+					},
+				},
+			},
+			want: Document{
+				Title: "Bookmarks",
+				Root: Folder{
+					Name: "Bookmarks Menu",
+					Subfolders: []Folder{
+						{Name: "Favorites"},
+						{Name: "Bookmarks Toolbar", Role: FolderRoleToolbar},
+						{Name: "Other Bookmarks"},
+					},
+				},
+			},
+		},
 	}
 
 	for _, tc := range cases {
 		t.Run(tc.tname, func(t *testing.T) {
-			d := NewDecoder()
+			d := NewDecoder(tc.opts...)
 
 			got, err := d.decodeFile(tc.file)
 
@@ -120,6 +223,7 @@ func TestDecodeFolder(t *testing.T) {
 					"ADD_DATE":                "1646154673",
 					"LAST_MODIFIED":           "1646172586",
 					"PERSONAL_TOOLBAR_FOLDER": "true",
+					"SYNC_ID":                 "toolbar_____",
 				},
 			},
 			want: Folder{
@@ -127,8 +231,39 @@ func TestDecodeFolder(t *testing.T) {
 				UpdatedAt:   folderUpdatedAt,
 				Name:        "Test Folder",
 				Description: "Add bookmarks to this folder",
+				Role:        FolderRoleToolbar,
 				Attributes: map[string]string{
-					"PERSONAL_TOOLBAR_FOLDER": "true",
+					"SYNC_ID": "toolbar_____",
+				},
+			},
+		},
+		{
+			tname: "folded unfiled folder",
+			input: FolderNode{
+				Name: "Other Bookmarks",
+				Attributes: map[string]string{
+					"FOLDED":                   "FOLDED",
+					"UNFILED_BOOKMARKS_FOLDER": "true",
+				},
+			},
+			want: Folder{
+				Name:   "Other Bookmarks",
+				Role:   FolderRoleUnfiled,
+				Folded: true,
+			},
+		},
+		{
+			tname: "folder with invalid role attribute",
+			input: FolderNode{
+				Name: "Test Folder",
+				Attributes: map[string]string{
+					"PERSONAL_TOOLBAR_FOLDER": "false",
+				},
+			},
+			want: Folder{
+				Name: "Test Folder",
+				Attributes: map[string]string{
+					"PERSONAL_TOOLBAR_FOLDER": "false",
 				},
 			},
 		},
@@ -403,6 +538,7 @@ delimiter
 				Href:  "https://domain.tld",
 				Title: "Test Domain",
 				Attributes: map[string]string{
+					"FEED":         "true",
 					"ICON_URI":     "https://domain.tld/favicon.ico",
 					"LAST_CHARSET": "windows-1252",
 					"PRIVATE":      "1",
@@ -412,9 +548,76 @@ delimiter
 				Title:   "Test Domain",
 				URL:     "https://domain.tld",
 				Private: true,
+				IconURI: "https://domain.tld/favicon.ico",
+				Charset: "windows-1252",
 				Attributes: map[string]string{
-					"ICON_URI":     "https://domain.tld/favicon.ico",
-					"LAST_CHARSET": "windows-1252",
+					"FEED": "true",
+				},
+			},
+		},
+		{
+			tname: "bookmark with browser attributes",
+			input: BookmarkNode{
+				Href:  "https://search.domain.tld/",
+				Title: "Test Search",
+				Attributes: map[string]string{
+					"ICON":         "data:image/png;base64,iVBORw0KGgo=",
+					"ICON_URI":     "https://search.domain.tld/favicon.png",
+					"LAST_CHARSET": "UTF-8",
+					"LAST_VISIT":   "1646154673000",
+					"POST_DATA":    "q=%s",
+					"SHORTCUTURL":  "search",
+				},
+			},
+			want: Bookmark{
+				Title:         "Test Search",
+				URL:           "https://search.domain.tld/",
+				LastVisitedAt: bookmarkCreatedAt,
+				Icon: Icon{
+					MediaType: "image/png",
+					Data:      []byte("\x89PNG\r\n\x1a\n"),
+				},
+				IconURI:  "https://search.domain.tld/favicon.png",
+				Keyword:  "search",
+				Charset:  "UTF-8",
+				PostData: "q=%s",
+			},
+		},
+		{
+			tname: "bookmark with URL-encoded icon",
+			input: BookmarkNode{
+				Href:  "https://domain.tld",
+				Title: "Test Domain",
+				Attributes: map[string]string{
+					"ICON": "data:image/svg+xml,%3Csvg%2F%3E",
+				},
+			},
+			want: Bookmark{
+				Title: "Test Domain",
+				URL:   "https://domain.tld",
+				Icon: Icon{
+					MediaType:  "image/svg+xml",
+					Data:       []byte("<svg/>"),
+					URLEncoded: true,
+				},
+			},
+		},
+		{
+			tname: "bookmark with undecodable browser attributes",
+			input: BookmarkNode{
+				Href:  "https://domain.tld",
+				Title: "Test Domain",
+				Attributes: map[string]string{
+					"ICON":       "https://domain.tld/favicon.ico",
+					"LAST_VISIT": "last week",
+				},
+			},
+			want: Bookmark{
+				Title: "Test Domain",
+				URL:   "https://domain.tld",
+				Attributes: map[string]string{
+					"ICON":       "https://domain.tld/favicon.ico",
+					"LAST_VISIT": "last week",
 				},
 			},
 		},
@@ -504,3 +707,268 @@ func TestDecodeDateTime(t *testing.T) {
 		})
 	}
 }
+
+func TestDecodeDateTimeWithOptions(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatalf("failed to load location: %s", err)
+	}
+
+	clock := func() time.Time {
+		return time.Date(2022, time.March, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	cases := []struct {
+		tname string
+		opts  []DecoderOption
+		input string
+		want  time.Time
+	}{
+		{
+			tname: "custom layout",
+			opts:  []DecoderOption{WithDateLayouts("2006-01-02 15:04:05")},
+			input: "2022-03-01 18:54:13",
+			want:  time.Date(2022, time.March, 1, 18, 54, 13, 0, time.UTC),
+		},
+		{
+			tname: "custom layouts, in location",
+			opts: []DecoderOption{
+				WithDateLayouts("2006-01-02", "2006-01-02T15:04:05"),
+				WithLocation(paris),
+			},
+			input: "2022-03-01T18:54:13",
+			want:  time.Date(2022, time.March, 1, 17, 54, 13, 0, time.UTC),
+		},
+		{
+			tname: "custom layout, nil location",
+			opts: []DecoderOption{
+				WithDateLayouts("2006-01-02 15:04:05"),
+				WithLocation(nil),
+			},
+			input: "2022-03-01 18:54:13",
+			want:  time.Date(2022, time.March, 1, 18, 54, 13, 0, time.UTC),
+		},
+		{
+			tname: "location does not apply to zoned dates",
+			opts:  []DecoderOption{WithLocation(paris)},
+			input: "2022-03-01T18:54:13+01:00",
+			want:  time.Date(2022, time.March, 1, 17, 54, 13, 0, time.UTC),
+		},
+		{
+			tname: "UNIX epoch within the max future range",
+			opts: []DecoderOption{
+				WithClock(clock),
+				WithMaxFutureRange(24 * time.Hour),
+			},
+			input: "1646154673",
+			want:  time.Date(2022, time.March, 1, 17, 11, 13, 0, time.UTC),
+		},
+		{
+			tname: "UNIX epoch beyond the max future range",
+			opts: []DecoderOption{
+				WithClock(clock),
+				WithMaxFutureRange(time.Hour),
+			},
+			input: "1646154673",
+			want:  time.Date(1970, time.January, 20, 1, 15, 54, 673000000, time.UTC),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.tname, func(t *testing.T) {
+			d := NewDecoder(tc.opts...)
+
+			got, err := d.decodeDate(tc.input)
+
+			if err != nil {
+				t.Fatalf("expected no error, got %q", err)
+			}
+
+			if !got.Equal(tc.want) {
+				t.Errorf("want date/time %q, got %q", tc.want, got)
+			}
+		})
+	}
+
+	t.Run("no matching layout", func(t *testing.T) {
+		d := NewDecoder(WithDateLayouts("2006-01-02"))
+
+		_, err := d.decodeDate("01/03/2022")
+
+		if !errors.Is(err, ErrDateInvalid) {
+			t.Errorf("want error %q, got %q", ErrDateInvalid, err)
+		}
+	})
+}
+
+func TestDecodeTagsWithSeparator(t *testing.T) {
+	cases := []struct {
+		tname     string
+		separator string
+		input     string
+		want      []string
+	}{
+		{
+			tname:     "comma",
+			separator: ",",
+			input:     "test, netscape,bookmark",
+			want:      []string{"bookmark", "netscape", "test"},
+		},
+		{
+			tname:     "whitespace",
+			separator: " ",
+			input:     "test  netscape\tbookmark ",
+			want:      []string{"bookmark", "netscape", "test"},
+		},
+		{
+			tname:     "semicolon",
+			separator: ";",
+			input:     "multi word; netscape",
+			want:      []string{"multi word", "netscape"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.tname, func(t *testing.T) {
+			d := NewDecoder(WithTagSeparator(tc.separator))
+
+			got := d.decodeTags(map[string]string{"TAGS": tc.input})
+
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("want tags %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestDecodeBookmarkWithURLNormalizer(t *testing.T) {
+	cases := []struct {
+		tname      string
+		normalizer *normalize.Normalizer
+		input      string
+		want       string
+	}{
+		{
+			tname: "verbatim",
+			input: "HTTP://Example.com:80/?utm_source=x",
+			want:  "HTTP://Example.com:80/?utm_source=x",
+		},
+		{
+			tname:      "normalized",
+			normalizer: normalize.New(),
+			input:      "HTTP://Example.com:80/?utm_source=x",
+			want:       "http://example.com",
+		},
+		{
+			tname:      "invalid URL kept verbatim",
+			normalizer: normalize.New(),
+			input:      "https://example.com:port/",
+			want:       "https://example.com:port/",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.tname, func(t *testing.T) {
+			d := NewDecoder(WithURLNormalizer(tc.normalizer))
+
+			got, err := d.decodeBookmark(BookmarkNode{Href: tc.input})
+			if err != nil {
+				t.Fatalf("expected no error, got %q", err)
+			}
+
+			if got.URL != tc.want {
+				t.Errorf("want URL %q, got %q", tc.want, got.URL)
+			}
+		})
+	}
+}
+
+func TestDecodeLenient(t *testing.T) {
+	bookmarkCreatedAt := time.Date(2022, time.March, 1, 17, 11, 13, 0, time.UTC)
+
+	file := FileNode{
+		Title: "Bookmarks",
+		Root: FolderNode{
+			Name: "Bookmarks",
+			Attributes: map[string]string{
+				"LAST_MODIFIED": "yesterday",
+			},
+			Bookmarks: []BookmarkNode{
+				{
+					Span: Span{
+						Start: Position{Offset: 96, Line: 5, Column: 9},
+					},
+					Href:  "https://domain.tld",
+					Title: "Test Domain",
+					Attributes: map[string]string{
+						"ADD_DATE":      "1646154673",
+						"LAST_MODIFIED": "not a date",
+						"ICON":          "favicon.ico",
+					},
+				},
+			},
+		},
+	}
+
+	want := Document{
+		Title: "Bookmarks",
+		Root: Folder{
+			Name: "Bookmarks",
+			Attributes: map[string]string{
+				"LAST_MODIFIED": "yesterday",
+			},
+			Bookmarks: []Bookmark{
+				{
+					CreatedAt: bookmarkCreatedAt,
+					UpdatedAt: bookmarkCreatedAt,
+					Title:     "Test Domain",
+					URL:       "https://domain.tld",
+					Attributes: map[string]string{
+						"LAST_MODIFIED": "not a date",
+						"ICON":          "favicon.ico",
+					},
+				},
+			},
+		},
+	}
+
+	t.Run("strict", func(t *testing.T) {
+		d := NewDecoder()
+
+		_, err := d.Decode(file)
+		if !errors.Is(err, ErrDateInvalid) {
+			t.Errorf("want error %q, got %q", ErrDateInvalid, err)
+		}
+	})
+
+	t.Run("lenient", func(t *testing.T) {
+		d := NewDecoder(WithMode(Lenient))
+
+		got, err := d.Decode(file)
+		if err != nil {
+			t.Fatalf("expected no error, got %q", err)
+		}
+
+		assertFoldersEqual(t, got.Root, want.Root)
+
+		diagnostics := d.Diagnostics()
+		if len(diagnostics) != 3 {
+			t.Fatalf("want 3 diagnostics, got %d: %v", len(diagnostics), diagnostics)
+		}
+
+		for _, diagnostic := range diagnostics {
+			if diagnostic.Severity != SeverityWarning {
+				t.Errorf("want severity %q, got %q", SeverityWarning, diagnostic.Severity)
+			}
+
+			if !errors.Is(diagnostic.Err, ErrDateInvalid) && !errors.Is(diagnostic.Err, ErrIconInvalid) {
+				t.Errorf("want error %q or %q, got %q", ErrDateInvalid, ErrIconInvalid, diagnostic.Err)
+			}
+		}
+
+		wantPos := Position{Offset: 96, Line: 5, Column: 9}
+		if diagnostics[1].Pos != wantPos {
+			t.Errorf("want position %#v, got %#v", wantPos, diagnostics[1].Pos)
+		}
+	})
+}
@@ -0,0 +1,84 @@
+// Copyright (c) VirtualTam
+// SPDX-License-Identifier: MIT
+
+package netscape
+
+import (
+	"errors"
+	"fmt"
+)
+
+// A Mode controls how problems found in a Netscape Bookmark document are
+// handled when parsing and decoding it.
+type Mode int
+
+const (
+	// Strict mode aborts processing on the first problem, and returns the
+	// corresponding error.
+	Strict Mode = iota
+
+	// Lenient mode skips or repairs malformed constructs, reports them as
+	// Diagnostics, and returns a best-effort result.
+	Lenient
+)
+
+// A Severity indicates how a problem reported by a Diagnostic affects the
+// result.
+type Severity int
+
+const (
+	// SeverityWarning indicates that a malformed construct has been repaired
+	// or skipped, without losing data.
+	SeverityWarning Severity = iota
+
+	// SeverityError indicates that processing could not continue past a
+	// malformed construct, and that the result is incomplete.
+	SeverityError
+)
+
+// String returns the string representation for this Severity.
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	default:
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+}
+
+// A Diagnostic describes a problem found in a Netscape Bookmark document while
+// processing it in Lenient mode.
+type Diagnostic struct {
+	Severity Severity
+
+	// Position in the input where the problem was found.
+	Pos Position
+
+	// Description of the problem, and of how it was handled.
+	Msg string
+
+	// Error describing the kind of problem, e.g. ErrFolderTitleEmpty, that
+	// can be checked with errors.Is.
+	Err error
+}
+
+func newDiagnostic(severity Severity, pos Position, msg string, err error) Diagnostic {
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		err = parseErr.Err
+	}
+
+	return Diagnostic{
+		Severity: severity,
+		Pos:      pos,
+		Msg:      msg,
+		Err:      err,
+	}
+}
+
+// String returns the string representation for this Diagnostic.
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s: %s: %s", d.Pos, d.Severity, d.Msg, d.Err)
+}
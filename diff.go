@@ -0,0 +1,636 @@
+// Copyright (c) VirtualTam
+// SPDX-License-Identifier: MIT
+
+package netscape
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// A ChangeKind identifies the type of a change between two Documents.
+type ChangeKind int
+
+const (
+	// ChangeAdded indicates that an item has been added.
+	ChangeAdded ChangeKind = iota
+
+	// ChangeRemoved indicates that an item has been removed.
+	ChangeRemoved
+
+	// ChangeMoved indicates that an item has been moved to another Folder.
+	ChangeMoved
+
+	// ChangeRenamed indicates that a Folder has been renamed.
+	ChangeRenamed
+
+	// ChangeModified indicates that fields of an item have been modified.
+	ChangeModified
+)
+
+var changeKindNames = map[ChangeKind]string{
+	ChangeAdded:    "added",
+	ChangeRemoved:  "removed",
+	ChangeMoved:    "moved",
+	ChangeRenamed:  "renamed",
+	ChangeModified: "modified",
+}
+
+// String returns the string representation for this ChangeKind.
+func (k ChangeKind) String() string {
+	if name, ok := changeKindNames[k]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("ChangeKind(%d)", int(k))
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (k ChangeKind) MarshalText() ([]byte, error) {
+	if _, ok := changeKindNames[k]; !ok {
+		return nil, fmt.Errorf("invalid change kind %d", int(k))
+	}
+
+	return []byte(k.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (k *ChangeKind) UnmarshalText(text []byte) error {
+	for kind, name := range changeKindNames {
+		if name == string(text) {
+			*k = kind
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown change kind %q", text)
+}
+
+// A FieldChange describes the modification of a field of a Bookmark or
+// Folder.
+type FieldChange struct {
+	// Field is the name of the field in the JSON representation of Bookmarks
+	// and Folders, e.g. "title", or "attributes.NAME" for attributes.
+	Field string `json:"field"`
+
+	// Old and New are the string representations of the values of the field;
+	// dates are formatted with RFC 3339, and tags are encoded as a JSON array
+	// of strings, e.g. ["dev","go"].
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+// A BookmarkChange describes a change affecting a Bookmark.
+type BookmarkChange struct {
+	Kind ChangeKind `json:"kind"`
+
+	// ID of the Bookmark in the old Document, or in the new Document for
+	// added Bookmarks.
+	ID string `json:"id"`
+
+	// Paths of the Folders containing the Bookmark in the old and new
+	// Documents, nil for added and removed Bookmarks, respectively.
+	OldPath Path `json:"old_path"`
+	NewPath Path `json:"new_path"`
+
+	// Old and New are the Bookmark in the old and new Documents, nil for
+	// added and removed Bookmarks, respectively.
+	Old *Bookmark `json:"old,omitempty"`
+	New *Bookmark `json:"new,omitempty"`
+
+	// Fields lists the modified fields, for ChangeModified.
+	Fields []FieldChange `json:"fields,omitempty"`
+}
+
+// A FolderChange describes a change affecting a Folder.
+type FolderChange struct {
+	Kind ChangeKind `json:"kind"`
+
+	// ID of the Folder in the old Document, or in the new Document for added
+	// Folders.
+	ID string `json:"id"`
+
+	// Paths of the Folder in the old and new Documents, nil for added and
+	// removed Folders, respectively.
+	OldPath Path `json:"old_path"`
+	NewPath Path `json:"new_path"`
+
+	// Old and New are the Folder in the old and new Documents, without their
+	// items, nil for added and removed Folders, respectively.
+	Old *Folder `json:"old,omitempty"`
+	New *Folder `json:"new,omitempty"`
+
+	// Fields lists the modified fields, for ChangeRenamed and
+	// ChangeModified.
+	Fields []FieldChange `json:"fields,omitempty"`
+}
+
+// A Changeset lists the changes between two Documents.
+type Changeset struct {
+	// Title describes the modification of the Document title, if any.
+	Title *FieldChange `json:"title,omitempty"`
+
+	Folders   []FolderChange   `json:"folders"`
+	Bookmarks []BookmarkChange `json:"bookmarks"`
+}
+
+// Len returns the number of changes in this Changeset.
+func (c Changeset) Len() int {
+	n := len(c.Folders) + len(c.Bookmarks)
+	if c.Title != nil {
+		n++
+	}
+
+	return n
+}
+
+// String returns a human-readable representation of this Changeset, with one
+// change per line, followed by the modified fields.
+func (c Changeset) String() string {
+	var b strings.Builder
+
+	if c.Title != nil {
+		fmt.Fprintf(&b, "modified title %q -> %q\n", c.Title.Old, c.Title.New)
+	}
+
+	for _, change := range c.Folders {
+		switch change.Kind {
+		case ChangeAdded:
+			fmt.Fprintf(&b, "added folder %q\n", displayPath(change.NewPath))
+		case ChangeRemoved:
+			fmt.Fprintf(&b, "removed folder %q\n", displayPath(change.OldPath))
+		case ChangeMoved:
+			fmt.Fprintf(&b, "moved folder %q to %q\n", displayPath(change.OldPath), displayPath(change.NewPath))
+		case ChangeRenamed:
+			fmt.Fprintf(&b, "renamed folder %q to %q\n", displayPath(change.OldPath), change.New.Name)
+		case ChangeModified:
+			fmt.Fprintf(&b, "modified folder %q\n", displayPath(change.NewPath))
+		}
+
+		writeFieldChanges(&b, change.Fields, change.Kind)
+	}
+
+	for _, change := range c.Bookmarks {
+		switch change.Kind {
+		case ChangeAdded:
+			fmt.Fprintf(&b, "added bookmark %s in %q\n", change.New.URL, displayPath(change.NewPath))
+		case ChangeRemoved:
+			fmt.Fprintf(&b, "removed bookmark %s from %q\n", change.Old.URL, displayPath(change.OldPath))
+		case ChangeMoved:
+			fmt.Fprintf(&b, "moved bookmark %s from %q to %q\n", change.New.URL, displayPath(change.OldPath), displayPath(change.NewPath))
+		case ChangeModified:
+			fmt.Fprintf(&b, "modified bookmark %s in %q\n", change.New.URL, displayPath(change.NewPath))
+		}
+
+		writeFieldChanges(&b, change.Fields, change.Kind)
+	}
+
+	return b.String()
+}
+
+func writeFieldChanges(b *strings.Builder, fields []FieldChange, kind ChangeKind) {
+	if kind != ChangeModified {
+		return
+	}
+
+	for _, field := range fields {
+		fmt.Fprintf(b, "  %s: %q -> %q\n", field.Field, field.Old, field.New)
+	}
+}
+
+// displayPath returns the representation of path in a Changeset, starting
+// with "/".
+func displayPath(path Path) string {
+	return string(pathSeparator) + FormatPath(path)
+}
+
+// Diff returns the changes between the old and new Documents.
+//
+// Folders are identified by their Path; a Folder that cannot be found at the
+// same Path is identified by its ID, unless derived from its name, or by its
+// name and the URLs of its Bookmarks, to detect moved and renamed Folders.
+// Bookmarks are identified by their ID attribute, then by their ID within the
+// same Folder, then by their ID, then by their URL within the same Folder,
+// then by their URL, then by their normalized URL, then by their title and
+// creation date.
+//
+// Changes are listed in document order, changes affecting old items first,
+// followed by added items.
+func Diff(oldDoc, newDoc *Document) Changeset {
+	if oldDoc == nil {
+		oldDoc = &Document{}
+	}
+	if newDoc == nil {
+		newDoc = &Document{}
+	}
+
+	m := matchDocuments(oldDoc, newDoc)
+
+	var changeset Changeset
+
+	if oldDoc.Title != newDoc.Title {
+		changeset.Title = &FieldChange{Field: "title", Old: oldDoc.Title, New: newDoc.Title}
+	}
+
+	changeset.Folders = m.folderChanges()
+	changeset.Bookmarks = m.bookmarkChanges()
+
+	return changeset
+}
+
+// A diffFolder is a Folder of a compared Document.
+type diffFolder struct {
+	folder *Folder
+	path   Path
+	id     string
+
+	// index of the parent Folder, -1 for the Root Folder
+	parent   int
+	children []int
+
+	// index of the matching Folder in the other Document, or -1
+	match int
+}
+
+// A diffBookmark is a Bookmark of a compared Document.
+type diffBookmark struct {
+	bookmark *Bookmark
+	id       string
+
+	// index of the Folder containing the Bookmark
+	folder int
+
+	// index of the matching Bookmark in the other Document, or -1
+	match int
+}
+
+// A diffTree lists the Folders and Bookmarks of a compared Document, in
+// document order.
+type diffTree struct {
+	folders   []diffFolder
+	bookmarks []diffBookmark
+
+	// indices of the Folders with each ID
+	folderIDs map[string][]int
+}
+
+func newDiffTree(d *Document) *diffTree {
+	t := &diffTree{
+		folderIDs: make(map[string][]int),
+	}
+	t.add(&d.Root, Path{}, -1)
+
+	return t
+}
+
+func (t *diffTree) add(folder *Folder, path Path, parent int) int {
+	index := len(t.folders)
+	id := folder.ID()
+
+	t.folders = append(t.folders, diffFolder{
+		folder: folder,
+		path:   path,
+		id:     id,
+		parent: parent,
+		match:  -1,
+	})
+	t.folderIDs[id] = append(t.folderIDs[id], index)
+
+	for _, ref := range folder.orderedItems() {
+		switch ref.Kind {
+		case BookmarkKind:
+			bookmark := &folder.Bookmarks[ref.Index]
+			t.bookmarks = append(t.bookmarks, diffBookmark{
+				bookmark: bookmark,
+				id:       bookmark.ID(),
+				folder:   index,
+				match:    -1,
+			})
+		case FolderKind:
+			subfolder := &folder.Subfolders[ref.Index]
+			child := t.add(subfolder, append(slices.Clip(path), subfolder.Name), index)
+			t.folders[index].children = append(t.folders[index].children, child)
+		}
+	}
+
+	return index
+}
+
+// A documentMatch pairs the Folders and Bookmarks of two Documents.
+type documentMatch struct {
+	oldTree *diffTree
+	newTree *diffTree
+}
+
+func matchDocuments(oldDoc, newDoc *Document) *documentMatch {
+	m := &documentMatch{
+		oldTree: newDiffTree(oldDoc),
+		newTree: newDiffTree(newDoc),
+	}
+
+	m.matchFolders()
+	m.matchBookmarks()
+
+	return m
+}
+
+func (m *documentMatch) matchFolders() {
+	m.pairFolders(0, 0)
+
+	for {
+		paired := false
+
+		for i := range m.oldTree.folders {
+			if m.oldTree.folders[i].match >= 0 {
+				continue
+			}
+
+			if j := m.findMovedFolder(i); j >= 0 {
+				m.pairFolders(i, j)
+				paired = true
+			}
+		}
+
+		if !paired {
+			return
+		}
+	}
+}
+
+// pairFolders pairs the old Folder i with the new Folder j, then their
+// Subfolders with the same names.
+func (m *documentMatch) pairFolders(i, j int) {
+	m.oldTree.folders[i].match = j
+	m.newTree.folders[j].match = i
+
+	for _, oldChild := range m.oldTree.folders[i].children {
+		if m.oldTree.folders[oldChild].match >= 0 {
+			continue
+		}
+
+		for _, newChild := range m.newTree.folders[j].children {
+			if m.newTree.folders[newChild].match < 0 && m.oldTree.folders[oldChild].folder.Name == m.newTree.folders[newChild].folder.Name {
+				m.pairFolders(oldChild, newChild)
+				break
+			}
+		}
+	}
+}
+
+// findMovedFolder returns the index of the unmatched new Folder that
+// corresponds to the old Folder i, or -1.
+func (m *documentMatch) findMovedFolder(i int) int {
+	old := m.oldTree.folders[i]
+
+	// Folders without ID attribute nor creation date have IDs derived from
+	// their names, which are only matched along with the URLs of their
+	// Bookmarks, below
+	if old.folder.Attributes[idAttribute] != "" || !old.folder.CreatedAt.IsZero() {
+		var candidates []int
+		for _, j := range m.newTree.folderIDs[old.id] {
+			if m.newTree.folders[j].match < 0 {
+				candidates = append(candidates, j)
+			}
+		}
+
+		if len(candidates) == 1 && len(m.oldTree.folderIDs[old.id]) == 1 {
+			return candidates[0]
+		}
+
+		// several Folders have the same ID, e.g. when created at the same
+		// time: select the one with the same parent or name
+		for _, j := range candidates {
+			candidate := m.newTree.folders[j]
+
+			sameParent := old.parent >= 0 && m.oldTree.folders[old.parent].match == candidate.parent
+			if sameParent || candidate.folder.Name == old.folder.Name {
+				return j
+			}
+		}
+	}
+
+	urls := folderURLs(old.folder)
+	if len(urls) == 0 {
+		return -1
+	}
+
+	for j, candidate := range m.newTree.folders {
+		if candidate.match < 0 && candidate.folder.Name == old.folder.Name && slices.Equal(folderURLs(candidate.folder), urls) {
+			return j
+		}
+	}
+
+	return -1
+}
+
+// folderURLs returns the sorted URLs of the Bookmarks of folder.
+func folderURLs(folder *Folder) []string {
+	urls := make([]string, 0, len(folder.Bookmarks))
+	for _, bookmark := range folder.Bookmarks {
+		urls = append(urls, bookmark.URL)
+	}
+
+	slices.Sort(urls)
+
+	return urls
+}
+
+func (m *documentMatch) matchBookmarks() {
+	// same ID attribute
+	m.pairBookmarksBy(func(b *diffBookmark, _ int) (string, bool) {
+		return b.id, b.bookmark.Attributes[idAttribute] != ""
+	})
+
+	// same ID, that is, same normalized URL and creation date for Bookmarks
+	// without ID attribute, preferably in the same Folder
+	m.pairBookmarksBy(func(b *diffBookmark, folder int) (string, bool) {
+		return b.id + "\x00" + strconv.Itoa(folder), folder >= 0
+	})
+
+	m.pairBookmarksBy(func(b *diffBookmark, _ int) (string, bool) {
+		return b.id, true
+	})
+
+	// same URL, same Folder
+	m.pairBookmarksBy(func(b *diffBookmark, folder int) (string, bool) {
+		return b.bookmark.URL + "\x00" + strconv.Itoa(folder), folder >= 0
+	})
+
+	// same URL
+	m.pairBookmarksBy(func(b *diffBookmark, _ int) (string, bool) {
+		return b.bookmark.URL, true
+	})
+
+	// same normalized URL
+	m.pairBookmarksBy(func(b *diffBookmark, _ int) (string, bool) {
+		return b.bookmark.NormalizedURL(), true
+	})
+
+	// same title and creation date
+	m.pairBookmarksBy(func(b *diffBookmark, _ int) (string, bool) {
+		if b.bookmark.CreatedAt.IsZero() {
+			return "", false
+		}
+
+		return b.bookmark.Title + "\x00" + strconv.FormatInt(b.bookmark.CreatedAt.UnixNano(), 10), true
+	})
+}
+
+// pairBookmarksBy pairs the unmatched old and new Bookmarks with the same key,
+// in document order.
+//
+// The key of a Bookmark is computed from the index of its Folder in the new
+// Document, or of the matching Folder for old Bookmarks (-1 if unmatched).
+func (m *documentMatch) pairBookmarksBy(key func(b *diffBookmark, folder int) (string, bool)) {
+	candidates := make(map[string][]int)
+
+	for j, candidate := range m.newTree.bookmarks {
+		if candidate.match >= 0 {
+			continue
+		}
+
+		if k, ok := key(&candidate, candidate.folder); ok {
+			candidates[k] = append(candidates[k], j)
+		}
+	}
+
+	for i, old := range m.oldTree.bookmarks {
+		if old.match >= 0 {
+			continue
+		}
+
+		k, ok := key(&old, m.oldTree.folders[old.folder].match)
+		if !ok || len(candidates[k]) == 0 {
+			continue
+		}
+
+		j := candidates[k][0]
+		candidates[k] = candidates[k][1:]
+
+		m.oldTree.bookmarks[i].match = j
+		m.newTree.bookmarks[j].match = i
+	}
+}
+
+func (m *documentMatch) folderChanges() []FolderChange {
+	var changes []FolderChange
+
+	for i, old := range m.oldTree.folders {
+		oldHeader := old.folder.header()
+
+		if old.match < 0 {
+			changes = append(changes, FolderChange{
+				Kind:    ChangeRemoved,
+				ID:      old.id,
+				OldPath: old.path,
+				Old:     &oldHeader,
+			})
+			continue
+		}
+
+		matched := m.newTree.folders[old.match]
+		newHeader := matched.folder.header()
+
+		change := FolderChange{
+			ID:      old.id,
+			OldPath: old.path,
+			NewPath: matched.path,
+			Old:     &oldHeader,
+			New:     &newHeader,
+		}
+
+		if i > 0 && m.oldTree.folders[old.parent].match != matched.parent {
+			change.Kind = ChangeMoved
+			changes = append(changes, change)
+		}
+
+		if old.folder.Name != matched.folder.Name {
+			change.Kind = ChangeRenamed
+			change.Fields = []FieldChange{{Field: "name", Old: old.folder.Name, New: matched.folder.Name}}
+			changes = append(changes, change)
+		}
+
+		if fields := folderFields.changes(old.folder, matched.folder); len(fields) > 0 {
+			change.Kind = ChangeModified
+			change.Fields = fields
+			changes = append(changes, change)
+		}
+	}
+
+	for _, added := range m.newTree.folders {
+		if added.match >= 0 {
+			continue
+		}
+
+		newHeader := added.folder.header()
+
+		changes = append(changes, FolderChange{
+			Kind:    ChangeAdded,
+			ID:      added.id,
+			NewPath: added.path,
+			New:     &newHeader,
+		})
+	}
+
+	return changes
+}
+
+func (m *documentMatch) bookmarkChanges() []BookmarkChange {
+	var changes []BookmarkChange
+
+	for _, old := range m.oldTree.bookmarks {
+		oldBookmark := *old.bookmark
+		oldPath := m.oldTree.folders[old.folder].path
+
+		if old.match < 0 {
+			changes = append(changes, BookmarkChange{
+				Kind:    ChangeRemoved,
+				ID:      old.id,
+				OldPath: oldPath,
+				Old:     &oldBookmark,
+			})
+			continue
+		}
+
+		matched := m.newTree.bookmarks[old.match]
+		newBookmark := *matched.bookmark
+
+		change := BookmarkChange{
+			ID:      old.id,
+			OldPath: oldPath,
+			NewPath: m.newTree.folders[matched.folder].path,
+			Old:     &oldBookmark,
+			New:     &newBookmark,
+		}
+
+		if m.oldTree.folders[old.folder].match != matched.folder {
+			change.Kind = ChangeMoved
+			changes = append(changes, change)
+		}
+
+		if fields := bookmarkFields.changes(old.bookmark, matched.bookmark); len(fields) > 0 {
+			change.Kind = ChangeModified
+			change.Fields = fields
+			changes = append(changes, change)
+		}
+	}
+
+	for _, added := range m.newTree.bookmarks {
+		if added.match >= 0 {
+			continue
+		}
+
+		newBookmark := *added.bookmark
+
+		changes = append(changes, BookmarkChange{
+			Kind:    ChangeAdded,
+			ID:      added.id,
+			NewPath: m.newTree.folders[added.folder].path,
+			New:     &newBookmark,
+		})
+	}
+
+	return changes
+}
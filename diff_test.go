@@ -0,0 +1,304 @@
+// Copyright (c) VirtualTam
+// SPDX-License-Identifier: MIT
+
+package netscape
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	oldDoc := loadTestDocument(t, "diff_old.htm")
+	newDoc := loadTestDocument(t, "diff_new.htm")
+
+	changeset := Diff(oldDoc, newDoc)
+
+	want := `modified title "Bookmarks" -> "My Bookmarks"
+renamed folder "/Dev" to "Development"
+modified folder "/Development"
+  description: "" -> "Software development"
+removed folder "/Old"
+moved folder "/Archive" to "/Reading/Archive"
+added folder "/Reading"
+modified bookmark https://go.dev in "/Development"
+  title: "Go" -> "The Go Programming Language"
+  private: "false" -> "true"
+  tags: "[\"golang\"]" -> "[\"go\",\"golang\"]"
+moved bookmark https://pkg.go.dev/ from "/Dev" to "/Development/Tools"
+modified bookmark https://pkg.go.dev/ in "/Development/Tools"
+  url: "https://pkg.go.dev" -> "https://pkg.go.dev/"
+removed bookmark https://removed.domain.tld from "/Old"
+added bookmark https://news.domain.tld in "/Reading"
+`
+
+	if got := changeset.String(); got != want {
+		t.Errorf("want changeset:\n%s\ngot:\n%s", want, got)
+	}
+
+	if got := changeset.Len(); got != 11 {
+		t.Errorf("want 11 changes, got %d", got)
+	}
+
+	if got := fmt.Sprint(changeset); got != want {
+		t.Errorf("want formatted changeset:\n%s\ngot:\n%s", want, got)
+	}
+
+	if got := Diff(oldDoc, newDoc).Len(); got != 11 {
+		t.Errorf("want 11 changes, got %d", got)
+	}
+
+	moved := changeset.Folders[3]
+	if !slices.Equal(moved.OldPath, Path{"Archive"}) || !slices.Equal(moved.NewPath, Path{"Reading", "Archive"}) {
+		t.Errorf("want folder moved from %q to %q, got %q to %q", "Archive", "Reading/Archive", moved.OldPath, moved.NewPath)
+	}
+
+	if moved.Old == nil || moved.New == nil || len(moved.New.Bookmarks) != 0 {
+		t.Errorf("want old and new folders without items, got %v and %v", moved.Old, moved.New)
+	}
+}
+
+func TestDiffJSON(t *testing.T) {
+	oldDoc := loadTestDocument(t, "diff_old.htm")
+	newDoc := loadTestDocument(t, "diff_new.htm")
+
+	changeset := Diff(oldDoc, newDoc)
+
+	data, err := json.Marshal(&changeset)
+	if err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+
+	var got struct {
+		Title *FieldChange `json:"title"`
+
+		Folders []struct {
+			Kind    ChangeKind `json:"kind"`
+			OldPath Path       `json:"old_path"`
+			NewPath Path       `json:"new_path"`
+		} `json:"folders"`
+
+		Bookmarks []struct {
+			Kind ChangeKind `json:"kind"`
+			New  *struct {
+				URL        string            `json:"url"`
+				Attributes map[string]string `json:"attributes"`
+			} `json:"new"`
+			Fields []FieldChange `json:"fields"`
+		} `json:"bookmarks"`
+	}
+
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("failed to unmarshal JSON changeset: %s", err)
+	}
+
+	if got.Title == nil || got.Title.New != "My Bookmarks" {
+		t.Errorf("want title change, got %v", got.Title)
+	}
+
+	if len(got.Folders) != len(changeset.Folders) || len(got.Bookmarks) != len(changeset.Bookmarks) {
+		t.Fatalf("want %d folder and %d bookmark changes, got %d and %d", len(changeset.Folders), len(changeset.Bookmarks), len(got.Folders), len(got.Bookmarks))
+	}
+
+	for i, change := range changeset.Folders {
+		if got.Folders[i].Kind != change.Kind {
+			t.Errorf("want folder change %d of kind %s, got %s", i, change.Kind, got.Folders[i].Kind)
+		}
+	}
+
+	removed := got.Folders[2]
+	if removed.Kind != ChangeRemoved || !slices.Equal(removed.OldPath, Path{"Old"}) || removed.NewPath != nil {
+		t.Errorf("want removed folder with old path only, got %v", removed)
+	}
+
+	added := got.Bookmarks[len(got.Bookmarks)-1]
+	if added.Kind != ChangeAdded || added.New == nil || added.New.Attributes["ID"] != "42" {
+		t.Errorf("want added bookmark with attributes, got %v", added)
+	}
+
+	if fields := got.Bookmarks[0].Fields; len(fields) != 3 || fields[0].Field != "title" {
+		t.Errorf("want modified fields, got %v", fields)
+	}
+}
+
+func TestDiffID(t *testing.T) {
+	oldDoc := &Document{
+		Root: Folder{
+			Name: "Bookmarks",
+			Bookmarks: []Bookmark{
+				{Title: "Go", URL: "https://golang.org", Attributes: map[string]string{"ID": "1"}},
+			},
+			Subfolders: []Folder{
+				{Name: "Dev", Attributes: map[string]string{"ID": "2"}},
+			},
+		},
+	}
+
+	newDoc := &Document{
+		Root: Folder{
+			Name: "Bookmarks",
+			Bookmarks: []Bookmark{
+				{Title: "Go", URL: "https://go.dev", Attributes: map[string]string{"ID": "1"}},
+			},
+			Subfolders: []Folder{
+				{Name: "Development", Attributes: map[string]string{"ID": "2"}},
+			},
+		},
+	}
+
+	changeset := Diff(oldDoc, newDoc)
+
+	want := `renamed folder "/Dev" to "Development"
+modified bookmark https://go.dev in "/"
+  url: "https://golang.org" -> "https://go.dev"
+`
+
+	if got := changeset.String(); got != want {
+		t.Errorf("want changeset:\n%s\ngot:\n%s", want, got)
+	}
+
+	if id := changeset.Bookmarks[0].ID; id != "1" {
+		t.Errorf("want bookmark ID %q, got %q", "1", id)
+	}
+
+	if id := changeset.Folders[0].ID; id != "2" {
+		t.Errorf("want folder ID %q, got %q", "2", id)
+	}
+}
+
+func TestDiffContentID(t *testing.T) {
+	devCreatedAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	firstCreatedAt := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	secondCreatedAt := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	oldDoc := &Document{
+		Root: Folder{
+			Name: "Bookmarks",
+			Subfolders: []Folder{
+				{
+					CreatedAt: devCreatedAt,
+					Name:      "Dev",
+					Bookmarks: []Bookmark{
+						{CreatedAt: firstCreatedAt, Title: "First", URL: "https://go.dev"},
+					},
+				},
+				{
+					Name: "Misc",
+					Bookmarks: []Bookmark{
+						{CreatedAt: secondCreatedAt, Title: "Second", URL: "https://go.dev/"},
+					},
+				},
+			},
+		},
+	}
+
+	// the Dev folder is moved and renamed at once, and the bookmarks swap
+	// folders while their URLs change
+	newDoc := &Document{
+		Root: Folder{
+			Name: "Bookmarks",
+			Subfolders: []Folder{
+				{
+					Name: "Reading",
+					Subfolders: []Folder{
+						{
+							CreatedAt: devCreatedAt,
+							Name:      "Development",
+							Bookmarks: []Bookmark{
+								{CreatedAt: secondCreatedAt, Title: "Second", URL: "https://GO.dev/"},
+							},
+						},
+					},
+				},
+				{
+					Name: "Misc",
+					Bookmarks: []Bookmark{
+						{CreatedAt: firstCreatedAt, Title: "First", URL: "https://Go.dev"},
+					},
+				},
+			},
+		},
+	}
+
+	changeset := Diff(oldDoc, newDoc)
+
+	want := `moved folder "/Dev" to "/Reading/Development"
+renamed folder "/Dev" to "Development"
+added folder "/Reading"
+moved bookmark https://Go.dev from "/Dev" to "/Misc"
+modified bookmark https://Go.dev in "/Misc"
+  url: "https://go.dev" -> "https://Go.dev"
+moved bookmark https://GO.dev/ from "/Misc" to "/Reading/Development"
+modified bookmark https://GO.dev/ in "/Reading/Development"
+  url: "https://go.dev/" -> "https://GO.dev/"
+`
+
+	if got := changeset.String(); got != want {
+		t.Errorf("want changeset:\n%s\ngot:\n%s", want, got)
+	}
+
+	if id, want := changeset.Folders[0].ID, oldDoc.Root.Subfolders[0].ContentID(); id != want {
+		t.Errorf("want folder ID %q, got %q", want, id)
+	}
+}
+
+func TestDiffSameURL(t *testing.T) {
+	firstCreatedAt := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	secondCreatedAt := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	oldDoc := &Document{
+		Root: Folder{
+			Name: "Bookmarks",
+			Bookmarks: []Bookmark{
+				{CreatedAt: firstCreatedAt, Title: "First", URL: "https://go.dev"},
+				{CreatedAt: secondCreatedAt, Title: "Second", URL: "https://go.dev"},
+			},
+		},
+	}
+
+	newDoc := &Document{
+		Root: Folder{
+			Name: "Bookmarks",
+			Bookmarks: []Bookmark{
+				{CreatedAt: secondCreatedAt, Title: "Second", URL: "https://go.dev"},
+			},
+		},
+	}
+
+	changeset := Diff(oldDoc, newDoc)
+
+	want := `removed bookmark https://go.dev from "/"
+`
+
+	if got := changeset.String(); got != want {
+		t.Errorf("want changeset:\n%s\ngot:\n%s", want, got)
+	}
+
+	if id, want := changeset.Bookmarks[0].ID, oldDoc.Root.Bookmarks[0].ContentID(); id != want {
+		t.Errorf("want bookmark ID %q, got %q", want, id)
+	}
+}
+
+func TestDiffIdentical(t *testing.T) {
+	oldDoc := loadTestDocument(t, "netscape_extended.htm")
+	newDoc := loadTestDocument(t, "netscape_extended.htm")
+
+	if changeset := Diff(oldDoc, newDoc); changeset.Len() != 0 {
+		t.Errorf("want no changes, got:\n%s", changeset.String())
+	}
+
+	changeset := Diff(nil, newDoc)
+
+	for _, change := range changeset.Bookmarks {
+		if change.Kind != ChangeAdded {
+			t.Errorf("want only added bookmarks, got %s", change.Kind)
+		}
+	}
+
+	if len(changeset.Bookmarks) == 0 {
+		t.Errorf("want added bookmarks")
+	}
+}
@@ -4,8 +4,16 @@
 package netscape
 
 import (
+	"bytes"
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
+	"iter"
+	"net/url"
+	"slices"
 	"time"
+
+	"github.com/virtualtam/netscape-go/v2/normalize"
 )
 
 // A Document represents a collection of Netscape Bookmarks.
@@ -16,16 +24,171 @@ type Document struct {
 
 // Flatten returns a flat version of this Document, with all Bookmarks attached
 // to the Root Folder.
+//
+// Flatten is equivalent to FlattenWithOptions without options.
 func (d *Document) Flatten() *Document {
-	flattenedRoot := d.Root.flatten()
+	return d.FlattenWithOptions()
+}
+
+// ToolbarFolder returns the Folder whose content is displayed in the browser
+// toolbar, or nil if there is none.
+func (d *Document) ToolbarFolder() *Folder {
+	return d.FolderByRole(FolderRoleToolbar)
+}
+
+// MenuFolder returns the Folder whose content is displayed in the browser
+// bookmarks menu, or nil if there is none.
+func (d *Document) MenuFolder() *Folder {
+	return d.FolderByRole(FolderRoleMenu)
+}
+
+// UnfiledFolder returns the Folder containing unsorted bookmarks, or nil if
+// there is none.
+func (d *Document) UnfiledFolder() *Folder {
+	return d.FolderByRole(FolderRoleUnfiled)
+}
+
+// MobileFolder returns the Folder containing bookmarks created on mobile
+// devices, or nil if there is none.
+func (d *Document) MobileFolder() *Folder {
+	return d.FolderByRole(FolderRoleMobile)
+}
+
+// FolderByRole returns the first Folder with the given role, in depth-first
+// order starting with the Root Folder, or nil if there is none.
+func (d *Document) FolderByRole(role FolderRole) *Folder {
+	return d.Root.folderByRole(role)
+}
+
+// All returns an iterator over the Bookmarks of this Document, in document
+// order, along with the Path of the Folder containing them.
+func (d *Document) All() iter.Seq2[Path, *Bookmark] {
+	return func(yield func(Path, *Bookmark) bool) {
+		d.Root.all(Path{}, yield)
+	}
+}
+
+// Folders returns an iterator over the Folders of this Document, in depth-first
+// order starting with the Root Folder, along with their Path.
+func (d *Document) Folders() iter.Seq2[Path, *Folder] {
+	return func(yield func(Path, *Folder) bool) {
+		d.Root.folders(Path{}, yield)
+	}
+}
+
+// A FolderRole identifies the special purpose given to a Folder by Web
+// browsers.
+type FolderRole int
+
+const (
+	FolderRoleNone FolderRole = iota
+	FolderRoleToolbar
+	FolderRoleMenu
+	FolderRoleUnfiled
+	FolderRoleMobile
+)
+
+var folderRoleNames = map[FolderRole]string{
+	FolderRoleNone:    "none",
+	FolderRoleToolbar: "toolbar",
+	FolderRoleMenu:    "menu",
+	FolderRoleUnfiled: "unfiled",
+	FolderRoleMobile:  "mobile",
+}
+
+// String returns the string representation for this FolderRole.
+func (r FolderRole) String() string {
+	if name, ok := folderRoleNames[r]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("FolderRole(%d)", int(r))
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (r FolderRole) MarshalText() ([]byte, error) {
+	if _, ok := folderRoleNames[r]; !ok {
+		return nil, fmt.Errorf("invalid folder role %d", int(r))
+	}
+
+	return []byte(r.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (r *FolderRole) UnmarshalText(text []byte) error {
+	for role, name := range folderRoleNames {
+		if name == string(text) {
+			*r = role
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown folder role %q", text)
+}
+
+// An ItemKind identifies the type of an Item.
+type ItemKind int
 
-	return &Document{
-		Title: d.Title,
-		Root:  *flattenedRoot,
+const (
+	BookmarkKind ItemKind = iota
+	FolderKind
+	SeparatorKind
+
+	// itemKindCount is the number of ItemKinds.
+	itemKindCount
+)
+
+var itemKindNames = map[ItemKind]string{
+	BookmarkKind:  "bookmark",
+	FolderKind:    "folder",
+	SeparatorKind: "separator",
+}
+
+// String returns the string representation for this ItemKind.
+func (k ItemKind) String() string {
+	if name, ok := itemKindNames[k]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("ItemKind(%d)", int(k))
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (k ItemKind) MarshalText() ([]byte, error) {
+	if _, ok := itemKindNames[k]; !ok {
+		return nil, fmt.Errorf("invalid item kind %d", int(k))
 	}
+
+	return []byte(k.String()), nil
 }
 
-// A Folder represents a folder containing Netscape Bookmarks and child Folders.
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (k *ItemKind) UnmarshalText(text []byte) error {
+	for kind, name := range itemKindNames {
+		if name == string(text) {
+			*k = kind
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown item kind %q", text)
+}
+
+// An ItemRef references an Item contained in a Folder (or FolderNode), by its
+// kind and its index in the corresponding slice.
+type ItemRef struct {
+	Kind  ItemKind `json:"kind"`
+	Index int      `json:"index"`
+}
+
+// An Item is an element contained in a Folder: a *Bookmark, a *Folder or a
+// *Separator.
+type Item interface {
+	itemKind() ItemKind
+}
+
+// A Folder represents a folder containing Netscape Bookmarks, Separators and
+// child Folders.
 type Folder struct {
 	CreatedAt time.Time
 	UpdatedAt time.Time
@@ -33,10 +196,134 @@ type Folder struct {
 	Description string
 	Name        string
 
+	// Role is the special purpose given to this Folder by Web browsers.
+	Role FolderRole
+
+	// Folded indicates whether this Folder is displayed collapsed.
+	Folded bool
+
 	Attributes map[string]string
 
 	Bookmarks  []Bookmark
+	Separators []Separator
 	Subfolders []Folder
+
+	// Order lists the Bookmarks, Separators and Subfolders of this Folder in
+	// the order they appear in the document.
+	//
+	// Items that are missing from Order are listed after the others,
+	// Bookmarks first, then Subfolders, then Separators. Invalid or duplicate
+	// references are ignored.
+	Order []ItemRef
+}
+
+func (f *Folder) itemKind() ItemKind {
+	return FolderKind
+}
+
+// Items returns the Bookmarks, Separators and Subfolders of this Folder, in
+// order.
+func (f *Folder) Items() []Item {
+	refs := f.orderedItems()
+	items := make([]Item, 0, len(refs))
+
+	for _, ref := range refs {
+		items = append(items, f.item(ref))
+	}
+
+	return items
+}
+
+// item returns the Item referenced by ref, which must be valid.
+func (f *Folder) item(ref ItemRef) Item {
+	switch ref.Kind {
+	case BookmarkKind:
+		return &f.Bookmarks[ref.Index]
+	case FolderKind:
+		return &f.Subfolders[ref.Index]
+	default:
+		return &f.Separators[ref.Index]
+	}
+}
+
+// orderedItems returns references to all the items of this Folder, following
+// Order.
+func (f *Folder) orderedItems() []ItemRef {
+	counts := [itemKindCount]int{
+		BookmarkKind:  len(f.Bookmarks),
+		FolderKind:    len(f.Subfolders),
+		SeparatorKind: len(f.Separators),
+	}
+
+	refs := make([]ItemRef, 0, len(f.Bookmarks)+len(f.Subfolders)+len(f.Separators))
+
+	var seen [itemKindCount][]bool
+	for kind, count := range counts {
+		seen[kind] = make([]bool, count)
+	}
+
+	for _, ref := range f.Order {
+		if ref.Kind < 0 || ref.Kind >= itemKindCount {
+			continue
+		}
+
+		if ref.Index < 0 || ref.Index >= counts[ref.Kind] || seen[ref.Kind][ref.Index] {
+			continue
+		}
+
+		seen[ref.Kind][ref.Index] = true
+		refs = append(refs, ref)
+	}
+
+	for kind := range itemKindCount {
+		for index := range counts[kind] {
+			if !seen[kind][index] {
+				refs = append(refs, ItemRef{Kind: kind, Index: index})
+			}
+		}
+	}
+
+	return refs
+}
+
+// hasDefaultOrder returns true if the items of this Folder are ordered as if
+// Order was empty: Bookmarks first, then Subfolders, then Separators.
+func (f *Folder) hasDefaultOrder() bool {
+	index := 0
+
+	for _, ref := range f.orderedItems() {
+		var want ItemRef
+
+		switch {
+		case index < len(f.Bookmarks):
+			want = ItemRef{Kind: BookmarkKind, Index: index}
+		case index < len(f.Bookmarks)+len(f.Subfolders):
+			want = ItemRef{Kind: FolderKind, Index: index - len(f.Bookmarks)}
+		default:
+			want = ItemRef{Kind: SeparatorKind, Index: index - len(f.Bookmarks) - len(f.Subfolders)}
+		}
+
+		if ref != want {
+			return false
+		}
+
+		index++
+	}
+
+	return true
+}
+
+// header returns a copy of this Folder without its items.
+func (f *Folder) header() Folder {
+	return Folder{
+		CreatedAt:   f.CreatedAt,
+		UpdatedAt:   f.UpdatedAt,
+		Description: f.Description,
+		Name:        f.Name,
+		Role:        f.Role,
+		Folded:      f.Folded,
+		Attributes:  f.Attributes,
+	}
 }
 
 func (f *Folder) MarshalJSON() ([]byte, error) {
@@ -47,20 +334,33 @@ func (f *Folder) MarshalJSON() ([]byte, error) {
 		Description string `json:"description,omitempty"`
 		Name        string `json:"name"`
 
+		Role   FolderRole `json:"role,omitempty"`
+		Folded bool       `json:"folded,omitempty"`
+
 		Attributes map[string]string `json:"attributes,omitempty"`
 
-		Bookmarks  []Bookmark `json:"bookmarks,omitempty"`
-		Subfolders []Folder   `json:"subfolders,omitempty"`
+		Bookmarks  []Bookmark  `json:"bookmarks,omitempty"`
+		Separators []Separator `json:"separators,omitempty"`
+		Subfolders []Folder    `json:"subfolders,omitempty"`
+
+		Order []ItemRef `json:"order,omitempty"`
 	}
 
 	jsonFolder := folder{
 		Description: f.Description,
 		Name:        f.Name,
+		Role:        f.Role,
+		Folded:      f.Folded,
 		Attributes:  f.Attributes,
 		Bookmarks:   f.Bookmarks,
+		Separators:  f.Separators,
 		Subfolders:  f.Subfolders,
 	}
 
+	if !f.hasDefaultOrder() {
+		jsonFolder.Order = f.orderedItems()
+	}
+
 	if !f.CreatedAt.IsZero() {
 		jsonFolder.CreatedAt = &f.CreatedAt
 	}
@@ -71,23 +371,110 @@ func (f *Folder) MarshalJSON() ([]byte, error) {
 	return json.Marshal(&jsonFolder)
 }
 
-func (f *Folder) flatten() *Folder {
-	flattened := &Folder{
-		CreatedAt:   f.CreatedAt,
-		UpdatedAt:   f.UpdatedAt,
-		Description: f.Description,
-		Name:        f.Name,
-		Attributes:  f.Attributes,
+func (f *Folder) UnmarshalJSON(data []byte) error {
+	type folder struct {
+		CreatedAt time.Time `json:"created_at"`
+		UpdatedAt time.Time `json:"updated_at"`
+
+		Description string `json:"description"`
+		Name        string `json:"name"`
+
+		Role   FolderRole `json:"role"`
+		Folded bool       `json:"folded"`
+
+		Attributes map[string]string `json:"attributes"`
+
+		Bookmarks  []Bookmark  `json:"bookmarks"`
+		Separators []Separator `json:"separators"`
+		Subfolders []Folder    `json:"subfolders"`
+
+		Order []ItemRef `json:"order"`
+	}
+
+	var jsonFolder folder
+	if err := json.Unmarshal(data, &jsonFolder); err != nil {
+		return err
+	}
+
+	*f = Folder{
+		CreatedAt:   jsonFolder.CreatedAt,
+		UpdatedAt:   jsonFolder.UpdatedAt,
+		Description: jsonFolder.Description,
+		Name:        jsonFolder.Name,
+		Role:        jsonFolder.Role,
+		Folded:      jsonFolder.Folded,
+		Attributes:  jsonFolder.Attributes,
+		Bookmarks:   jsonFolder.Bookmarks,
+		Separators:  jsonFolder.Separators,
+		Subfolders:  jsonFolder.Subfolders,
+		Order:       jsonFolder.Order,
+	}
+
+	return nil
+}
+
+// all yields the Bookmarks of this Folder and its Subfolders, and returns false
+// if the iteration has been stopped.
+func (f *Folder) all(path Path, yield func(Path, *Bookmark) bool) bool {
+	for _, ref := range f.orderedItems() {
+		switch ref.Kind {
+		case BookmarkKind:
+			if !yield(path, &f.Bookmarks[ref.Index]) {
+				return false
+			}
+		case FolderKind:
+			subfolder := &f.Subfolders[ref.Index]
+			if !subfolder.all(append(slices.Clip(path), subfolder.Name), yield) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// folders yields this Folder and its Subfolders, and returns false if the
+// iteration has been stopped.
+func (f *Folder) folders(path Path, yield func(Path, *Folder) bool) bool {
+	if !yield(path, f) {
+		return false
+	}
+
+	for _, ref := range f.orderedItems() {
+		if ref.Kind != FolderKind {
+			continue
+		}
+
+		subfolder := &f.Subfolders[ref.Index]
+		if !subfolder.folders(append(slices.Clip(path), subfolder.Name), yield) {
+			return false
+		}
 	}
 
-	flattened.Bookmarks = append(flattened.Bookmarks, f.Bookmarks...)
+	return true
+}
+
+func (f *Folder) folderByRole(role FolderRole) *Folder {
+	if f.Role == role {
+		return f
+	}
 
-	for _, subfolder := range f.Subfolders {
-		flattenedSubfolder := subfolder.flatten()
-		flattened.Bookmarks = append(flattened.Bookmarks, flattenedSubfolder.Bookmarks...)
+	for index := range f.Subfolders {
+		if folder := f.Subfolders[index].folderByRole(role); folder != nil {
+			return folder
+		}
 	}
 
-	return flattened
+	return nil
+}
+
+// setAttribute sets the value of an arbitrary attribute, allocating the
+// Attributes map with the given size hint if needed.
+func (f *Folder) setAttribute(name, value string, sizeHint int) {
+	if f.Attributes == nil {
+		f.Attributes = make(map[string]string, sizeHint)
+	}
+	f.Attributes[name] = value
 }
 
 // A Bookmark represents a Netscape Bookmark.
@@ -102,9 +489,44 @@ type Bookmark struct {
 	Private     bool
 	Tags        []string
 
+	// LastVisitedAt is the date of the last visit to this Bookmark.
+	LastVisitedAt time.Time
+
+	// Icon is the favicon embedded in the document as a data URI.
+	Icon Icon
+
+	// IconURI is the URI the favicon was retrieved from.
+	IconURI string
+
+	// Keyword is the shortcut that can be typed in the address bar to open
+	// this Bookmark.
+	Keyword string
+
+	// Charset is the character encoding used by the page the last time it
+	// was visited.
+	Charset string
+
+	// PostData holds the data sent with POST requests for keyword searches.
+	PostData string
+
 	Attributes map[string]string
 }
 
+func (b *Bookmark) itemKind() ItemKind {
+	return BookmarkKind
+}
+
+// NormalizedURL returns the URL of this Bookmark normalized with
+// normalize.DefaultRules, or the URL as is if it cannot be parsed.
+func (b *Bookmark) NormalizedURL() string {
+	normalized, err := normalize.Normalize(b.URL)
+	if err != nil {
+		return b.URL
+	}
+
+	return normalized
+}
+
 func (b *Bookmark) MarshalJSON() ([]byte, error) {
 	type bookmark struct {
 		CreatedAt *time.Time `json:"created_at,omitempty"`
@@ -117,6 +539,14 @@ func (b *Bookmark) MarshalJSON() ([]byte, error) {
 		Private     bool     `json:"private"`
 		Tags        []string `json:"tags,omitempty"`
 
+		LastVisitedAt *time.Time `json:"last_visited_at,omitempty"`
+
+		Icon     *Icon  `json:"icon,omitempty"`
+		IconURI  string `json:"icon_uri,omitempty"`
+		Keyword  string `json:"keyword,omitempty"`
+		Charset  string `json:"charset,omitempty"`
+		PostData string `json:"post_data,omitempty"`
+
 		Attributes map[string]string `json:"attributes,omitempty"`
 	}
 
@@ -126,6 +556,10 @@ func (b *Bookmark) MarshalJSON() ([]byte, error) {
 		Description: b.Description,
 		Private:     b.Private,
 		Tags:        b.Tags,
+		IconURI:     b.IconURI,
+		Keyword:     b.Keyword,
+		Charset:     b.Charset,
+		PostData:    b.PostData,
 		Attributes:  b.Attributes,
 	}
 
@@ -135,6 +569,130 @@ func (b *Bookmark) MarshalJSON() ([]byte, error) {
 	if !b.UpdatedAt.IsZero() {
 		jsonBookmark.UpdatedAt = &b.UpdatedAt
 	}
+	if !b.LastVisitedAt.IsZero() {
+		jsonBookmark.LastVisitedAt = &b.LastVisitedAt
+	}
+	if !b.Icon.IsZero() {
+		jsonBookmark.Icon = &b.Icon
+	}
 
 	return json.Marshal(&jsonBookmark)
 }
+
+func (b *Bookmark) UnmarshalJSON(data []byte) error {
+	type bookmark struct {
+		CreatedAt time.Time `json:"created_at"`
+		UpdatedAt time.Time `json:"updated_at"`
+
+		Title string `json:"title"`
+		URL   string `json:"url"`
+
+		Description string   `json:"description"`
+		Private     bool     `json:"private"`
+		Tags        []string `json:"tags"`
+
+		LastVisitedAt time.Time `json:"last_visited_at"`
+
+		Icon     Icon   `json:"icon"`
+		IconURI  string `json:"icon_uri"`
+		Keyword  string `json:"keyword"`
+		Charset  string `json:"charset"`
+		PostData string `json:"post_data"`
+
+		Attributes map[string]string `json:"attributes"`
+	}
+
+	var jsonBookmark bookmark
+	if err := json.Unmarshal(data, &jsonBookmark); err != nil {
+		return err
+	}
+
+	*b = Bookmark{
+		CreatedAt:     jsonBookmark.CreatedAt,
+		UpdatedAt:     jsonBookmark.UpdatedAt,
+		Title:         jsonBookmark.Title,
+		URL:           jsonBookmark.URL,
+		Description:   jsonBookmark.Description,
+		Private:       jsonBookmark.Private,
+		Tags:          jsonBookmark.Tags,
+		LastVisitedAt: jsonBookmark.LastVisitedAt,
+		Icon:          jsonBookmark.Icon,
+		IconURI:       jsonBookmark.IconURI,
+		Keyword:       jsonBookmark.Keyword,
+		Charset:       jsonBookmark.Charset,
+		PostData:      jsonBookmark.PostData,
+		Attributes:    jsonBookmark.Attributes,
+	}
+
+	return nil
+}
+
+// setAttribute sets the value of an arbitrary attribute, allocating the
+// Attributes map with the given size hint if needed.
+func (b *Bookmark) setAttribute(name, value string, sizeHint int) {
+	if b.Attributes == nil {
+		b.Attributes = make(map[string]string, sizeHint)
+	}
+	b.Attributes[name] = value
+}
+
+// An Icon represents a favicon embedded in a Netscape Bookmark document.
+type Icon struct {
+	// MediaType is the MIME type of the icon, e.g. "image/png".
+	MediaType string `json:"media_type,omitempty"`
+
+	// Data holds the raw icon data.
+	Data []byte `json:"data"`
+
+	// URLEncoded indicates whether Data is percent-encoded in the data URI,
+	// rather than base64-encoded.
+	URLEncoded bool `json:"url_encoded,omitempty"`
+
+	// dataURI is the data URI this Icon has been decoded from, if any.
+	dataURI string
+}
+
+// IsZero reports whether this Icon is empty.
+func (i Icon) IsZero() bool {
+	return i.MediaType == "" && len(i.Data) == 0
+}
+
+// DataURI returns the representation of this Icon as a data URI, or an empty
+// string if the Icon is empty.
+//
+// The data URI an Icon has been decoded from is returned as is, unless the
+// Icon has been modified since. Otherwise, Data is percent-encoded if
+// URLEncoded is set, and base64-encoded if not.
+func (i Icon) DataURI() string {
+	if i.IsZero() {
+		return ""
+	}
+
+	if i.dataURI != "" {
+		var d Decoder
+		if icon, err := d.decodeIcon(i.dataURI); err == nil && icon.equal(i) {
+			return i.dataURI
+		}
+	}
+
+	if i.URLEncoded {
+		return "data:" + i.MediaType + "," + url.PathEscape(string(i.Data))
+	}
+
+	return "data:" + i.MediaType + ";base64," + base64.StdEncoding.EncodeToString(i.Data)
+}
+
+// equal returns true if this Icon and other have the same media type, encoding
+// and data.
+func (i Icon) equal(other Icon) bool {
+	return i.MediaType == other.MediaType && i.URLEncoded == other.URLEncoded && bytes.Equal(i.Data, other.Data)
+}
+
+// A Separator represents a separator between the items of a Folder.
+type Separator struct {
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+func (s *Separator) itemKind() ItemKind {
+	return SeparatorKind
+}
@@ -4,6 +4,12 @@
 package netscape
 
 import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
 	"testing"
 	"time"
 )
@@ -167,6 +173,61 @@ func TestDocumentFlatten(t *testing.T) {
 				},
 			},
 		},
+		{
+			tname: "nested, with ordered items",
+			document: Document{
+				Title: "Nested",
+				Root: Folder{
+					Name: "Nested",
+					Bookmarks: []Bookmark{
+						{
+							Title: "Nested 1",
+							URL:   "https://n1.domain.tld",
+						},
+						{
+							Title: "Nested 2",
+							URL:   "https://n2.domain.tld",
+						},
+					},
+					Subfolders: []Folder{
+						{
+							Name: "Subfolder A",
+							Bookmarks: []Bookmark{
+								{
+									Title: "Nested A1",
+									URL:   "https://na1.domain.tld",
+								},
+							},
+						},
+					},
+					Order: []ItemRef{
+						{Kind: BookmarkKind, Index: 0},
+						{Kind: FolderKind, Index: 0},
+						{Kind: BookmarkKind, Index: 1},
+					},
+				},
+			},
+			want: Document{
+				Title: "Nested",
+				Root: Folder{
+					Name: "Nested",
+					Bookmarks: []Bookmark{
+						{
+							Title: "Nested 1",
+							URL:   "https://n1.domain.tld",
+						},
+						{
+							Title: "Nested A1",
+							URL:   "https://na1.domain.tld",
+						},
+						{
+							Title: "Nested 2",
+							URL:   "https://n2.domain.tld",
+						},
+					},
+				},
+			},
+		},
 	}
 
 	for _, tc := range cases {
@@ -186,6 +247,371 @@ func TestDocumentFlatten(t *testing.T) {
 	}
 }
 
+func TestFolderItems(t *testing.T) {
+	folder := Folder{
+		Bookmarks: []Bookmark{
+			{Title: "Bookmark 0"},
+			{Title: "Bookmark 1"},
+			{Title: "Bookmark 2"},
+		},
+		Subfolders: []Folder{
+			{Name: "Folder 0"},
+			{Name: "Folder 1"},
+		},
+	}
+
+	cases := []struct {
+		tname string
+		order []ItemRef
+		want  []string
+	}{
+		{
+			tname: "no order",
+			want:  []string{"Bookmark 0", "Bookmark 1", "Bookmark 2", "Folder 0", "Folder 1"},
+		},
+		{
+			tname: "complete order",
+			order: []ItemRef{
+				{Kind: FolderKind, Index: 1},
+				{Kind: BookmarkKind, Index: 2},
+				{Kind: FolderKind, Index: 0},
+				{Kind: BookmarkKind, Index: 0},
+				{Kind: BookmarkKind, Index: 1},
+			},
+			want: []string{"Folder 1", "Bookmark 2", "Folder 0", "Bookmark 0", "Bookmark 1"},
+		},
+		{
+			tname: "partial order",
+			order: []ItemRef{
+				{Kind: FolderKind, Index: 1},
+				{Kind: BookmarkKind, Index: 1},
+			},
+			want: []string{"Folder 1", "Bookmark 1", "Bookmark 0", "Bookmark 2", "Folder 0"},
+		},
+		{
+			tname: "invalid and duplicate references",
+			order: []ItemRef{
+				{Kind: BookmarkKind, Index: 2},
+				{Kind: BookmarkKind, Index: 3},
+				{Kind: FolderKind, Index: -1},
+				{Kind: ItemKind(42), Index: 0},
+				{Kind: BookmarkKind, Index: 2},
+				{Kind: FolderKind, Index: 0},
+			},
+			want: []string{"Bookmark 2", "Folder 0", "Bookmark 0", "Bookmark 1", "Folder 1"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.tname, func(t *testing.T) {
+			folder.Order = tc.order
+
+			items := folder.Items()
+
+			got := make([]string, 0, len(items))
+			for _, item := range items {
+				switch i := item.(type) {
+				case *Bookmark:
+					got = append(got, i.Title)
+				case *Folder:
+					got = append(got, i.Name)
+				}
+			}
+
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("want items %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestFolderJSON(t *testing.T) {
+	cases := []struct {
+		tname string
+		input Folder
+	}{
+		{
+			tname: "default order",
+			input: Folder{
+				Name:       "Bookmarks",
+				Bookmarks:  []Bookmark{{Title: "Bookmark 0"}},
+				Subfolders: []Folder{{Name: "Folder 0"}},
+			},
+		},
+		{
+			tname: "interleaved items and separators",
+			input: Folder{
+				Name: "Bookmarks",
+				Bookmarks: []Bookmark{
+					{Title: "Bookmark 0"},
+					{Title: "Bookmark 1"},
+				},
+				Separators: []Separator{
+					{},
+					{Attributes: map[string]string{"CLASS": "separator"}},
+				},
+				Subfolders: []Folder{
+					{
+						Name:       "Folder 0",
+						Bookmarks:  []Bookmark{{Title: "Bookmark 2"}},
+						Separators: []Separator{{}},
+						Order: []ItemRef{
+							{Kind: SeparatorKind, Index: 0},
+							{Kind: BookmarkKind, Index: 0},
+						},
+					},
+				},
+				Order: []ItemRef{
+					{Kind: FolderKind, Index: 0},
+					{Kind: SeparatorKind, Index: 1},
+					{Kind: BookmarkKind, Index: 1},
+					{Kind: SeparatorKind, Index: 0},
+					{Kind: BookmarkKind, Index: 0},
+				},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.tname, func(t *testing.T) {
+			data, err := json.Marshal(&tc.input)
+			if err != nil {
+				t.Fatalf("expected no error, got %q", err)
+			}
+
+			var got Folder
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("expected no error, got %q", err)
+			}
+
+			assertFoldersEqual(t, got, tc.input)
+
+			if !slices.Equal(got.orderedItems(), tc.input.orderedItems()) {
+				t.Errorf("want order %v, got %v", tc.input.orderedItems(), got.orderedItems())
+			}
+
+			for index, wantSeparator := range tc.input.Separators {
+				assertAttributesEqual(t, got.Separators[index].Attributes, wantSeparator.Attributes)
+			}
+		})
+	}
+}
+
+func TestDocumentFolderByRole(t *testing.T) {
+	document := Document{
+		Root: Folder{
+			Name: "Bookmarks Menu",
+			Role: FolderRoleMenu,
+			Subfolders: []Folder{
+				{
+					Name: "Misc",
+					Subfolders: []Folder{
+						{Name: "Bookmarks Toolbar", Role: FolderRoleToolbar},
+					},
+				},
+				{Name: "Other Bookmarks", Role: FolderRoleUnfiled},
+			},
+		},
+	}
+
+	cases := []struct {
+		tname  string
+		lookup func() *Folder
+		want   string
+	}{
+		{
+			tname:  "toolbar",
+			lookup: document.ToolbarFolder,
+			want:   "Bookmarks Toolbar",
+		},
+		{
+			tname:  "menu",
+			lookup: document.MenuFolder,
+			want:   "Bookmarks Menu",
+		},
+		{
+			tname:  "unfiled",
+			lookup: document.UnfiledFolder,
+			want:   "Other Bookmarks",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.tname, func(t *testing.T) {
+			got := tc.lookup()
+
+			if got == nil {
+				t.Fatalf("want folder %q, got nil", tc.want)
+			}
+
+			if got.Name != tc.want {
+				t.Errorf("want folder %q, got %q", tc.want, got.Name)
+			}
+		})
+	}
+
+	t.Run("mobile", func(t *testing.T) {
+		if got := document.MobileFolder(); got != nil {
+			t.Errorf("want no folder, got %q", got.Name)
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+		document.ToolbarFolder().Folded = true
+
+		if !document.Root.Subfolders[0].Subfolders[0].Folded {
+			t.Error("want the toolbar folder to be updated in place")
+		}
+	})
+}
+
+func TestEnumText(t *testing.T) {
+	t.Run("change kind", func(t *testing.T) {
+		assertEnumText(t, changeKindNames, ChangeKind(42))
+	})
+	t.Run("conflict kind", func(t *testing.T) {
+		assertEnumText(t, conflictKindNames, ConflictKind(42))
+	})
+	t.Run("folder role", func(t *testing.T) {
+		assertEnumText(t, folderRoleNames, FolderRole(42))
+	})
+	t.Run("item kind", func(t *testing.T) {
+		assertEnumText(t, itemKindNames, ItemKind(42))
+	})
+	t.Run("operation kind", func(t *testing.T) {
+		assertEnumText(t, operationKindNames, OperationKind(42))
+	})
+	t.Run("side", func(t *testing.T) {
+		assertEnumText(t, sideNames, Side(42))
+	})
+}
+
+func TestIconDataURI(t *testing.T) {
+	cases := []struct {
+		tname  string
+		input  string
+		update func(*Icon)
+		want   string
+	}{
+		{
+			tname: "base64-encoded",
+			input: "data:image/png;base64,iVBORw0KGgo=",
+			want:  "data:image/png;base64,iVBORw0KGgo=",
+		},
+		{
+			tname: "URL-encoded",
+			input: "data:image/svg+xml,%3Csvg xmlns='http://www.w3.org/2000/svg'/%3E",
+			want:  "data:image/svg+xml,%3Csvg xmlns='http://www.w3.org/2000/svg'/%3E",
+		},
+		{
+			tname: "URL-encoded with parameters",
+			input: "data:image/svg+xml;charset=utf-8,%3Csvg%2F%3E",
+			want:  "data:image/svg+xml;charset=utf-8,%3Csvg%2F%3E",
+		},
+		{
+			tname: "modified base64-encoded",
+			input: "data:image/png;base64,iVBORw0KGgo=",
+			update: func(icon *Icon) {
+				icon.Data = []byte("GIF89a")
+				icon.MediaType = "image/gif"
+			},
+			want: "data:image/gif;base64,R0lGODlh",
+		},
+		{
+			tname: "modified URL-encoded",
+			input: "data:image/svg+xml,%3Csvg/%3E",
+			update: func(icon *Icon) {
+				icon.Data = []byte("<svg></svg>")
+			},
+			want: "data:image/svg+xml,%3Csvg%3E%3C%2Fsvg%3E",
+		},
+		{
+			tname: "converted to base64",
+			input: "data:image/svg+xml,%3Csvg/%3E",
+			update: func(icon *Icon) {
+				icon.URLEncoded = false
+			},
+			want: "data:image/svg+xml;base64,PHN2Zy8+",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.tname, func(t *testing.T) {
+			var d Decoder
+
+			icon, err := d.decodeIcon(tc.input)
+			if err != nil {
+				t.Fatalf("expected no error, got %q", err)
+			}
+
+			if tc.update != nil {
+				tc.update(&icon)
+			}
+
+			got := icon.DataURI()
+
+			if got != tc.want {
+				t.Errorf("want data URI %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+// assertEnumText checks that the values of an enumerated type are encoded to
+// their names and decoded back, and that unknown names and invalid values are
+// rejected.
+func assertEnumText[E interface {
+	comparable
+	encoding.TextMarshaler
+}, P interface {
+	*E
+	encoding.TextUnmarshaler
+}](t *testing.T, names map[E]string, invalid E) {
+	t.Helper()
+
+	for value, name := range names {
+		text, err := value.MarshalText()
+		if err != nil {
+			t.Fatalf("expected no error, got %q", err)
+		}
+
+		if string(text) != name {
+			t.Errorf("want text %q, got %q", name, text)
+		}
+
+		var got E
+		if err := P(&got).UnmarshalText(text); err != nil {
+			t.Fatalf("expected no error, got %q", err)
+		}
+
+		if got != value {
+			t.Errorf("want %v, got %v", value, got)
+		}
+	}
+
+	var got E
+	if err := P(&got).UnmarshalText([]byte("unknown")); err == nil {
+		t.Error("expected an error, got none")
+	}
+
+	if _, err := invalid.MarshalText(); err == nil {
+		t.Error("expected an error, got none")
+	}
+}
+
+// loadTestDocument returns the Document decoded from the given file of the
+// testdata/input directory.
+func loadTestDocument(t *testing.T, name string) *Document {
+	t.Helper()
+
+	document, err := UnmarshalFile(filepath.Join("testdata", "input", name))
+	if err != nil {
+		t.Fatalf("failed to unmarshal input file: %s", err)
+	}
+
+	return document
+}
+
 func assertFoldersEqual(t *testing.T, got Folder, want Folder) {
 	t.Helper()
 
@@ -200,6 +626,14 @@ func assertFoldersEqual(t *testing.T, got Folder, want Folder) {
 		t.Errorf("want name %q, got %q", want.Name, got.Name)
 	}
 
+	if got.Role != want.Role {
+		t.Errorf("want folder %q role %q, got %q", want.Name, want.Role, got.Role)
+	}
+
+	if got.Folded != want.Folded {
+		t.Errorf("want folder %q folded %t, got %t", want.Name, want.Folded, got.Folded)
+	}
+
 	assertAttributesEqual(t, got.Attributes, want.Attributes)
 
 	if len(got.Bookmarks) != len(want.Bookmarks) {
@@ -217,6 +651,14 @@ func assertFoldersEqual(t *testing.T, got Folder, want Folder) {
 	for index, wantSubfolder := range want.Subfolders {
 		assertFoldersEqual(t, got.Subfolders[index], wantSubfolder)
 	}
+
+	if len(got.Separators) != len(want.Separators) {
+		t.Fatalf("want %d separators, got %d", len(want.Separators), len(got.Separators))
+	}
+
+	if want.Order != nil && !slices.Equal(got.orderedItems(), want.orderedItems()) {
+		t.Errorf("want order %v, got %v", want.orderedItems(), got.orderedItems())
+	}
 }
 
 func assertBookmarksEqual(t *testing.T, got Bookmark, want Bookmark) {
@@ -249,6 +691,36 @@ func assertBookmarksEqual(t *testing.T, got Bookmark, want Bookmark) {
 		}
 	}
 
+	assertDatesEqual(t, "last visit", got.LastVisitedAt, want.LastVisitedAt)
+
+	if got.Icon.MediaType != want.Icon.MediaType {
+		t.Errorf("want icon media type %q, got %q", want.Icon.MediaType, got.Icon.MediaType)
+	}
+
+	if !bytes.Equal(got.Icon.Data, want.Icon.Data) {
+		t.Errorf("want icon data %q, got %q", want.Icon.Data, got.Icon.Data)
+	}
+
+	if got.Icon.URLEncoded != want.Icon.URLEncoded {
+		t.Errorf("want icon URL-encoded %t, got %t", want.Icon.URLEncoded, got.Icon.URLEncoded)
+	}
+
+	if got.IconURI != want.IconURI {
+		t.Errorf("want icon URI %q, got %q", want.IconURI, got.IconURI)
+	}
+
+	if got.Keyword != want.Keyword {
+		t.Errorf("want keyword %q, got %q", want.Keyword, got.Keyword)
+	}
+
+	if got.Charset != want.Charset {
+		t.Errorf("want charset %q, got %q", want.Charset, got.Charset)
+	}
+
+	if got.PostData != want.PostData {
+		t.Errorf("want POST data %q, got %q", want.PostData, got.PostData)
+	}
+
 	assertAttributesEqual(t, got.Attributes, want.Attributes)
 }
 
@@ -273,3 +745,161 @@ func assertDatesEqual(t *testing.T, name string, got, want time.Time) {
 		t.Errorf("want %s date %q, got %q", name, want.String(), got.String())
 	}
 }
+
+func TestDocumentAll(t *testing.T) {
+	document := Document{
+		Root: Folder{
+			Name: "Bookmarks",
+			Bookmarks: []Bookmark{
+				{URL: "https://root.domain.tld"},
+				{URL: "https://last.domain.tld"},
+			},
+			Subfolders: []Folder{
+				{
+					Name: "Level 1",
+					Bookmarks: []Bookmark{
+						{URL: "https://l1.domain.tld"},
+					},
+					Subfolders: []Folder{
+						{
+							Name: "Level 2",
+							Bookmarks: []Bookmark{
+								{URL: "https://l2.domain.tld"},
+							},
+						},
+					},
+				},
+			},
+			Order: []ItemRef{
+				{Kind: BookmarkKind, Index: 0},
+				{Kind: FolderKind, Index: 0},
+				{Kind: BookmarkKind, Index: 1},
+			},
+		},
+	}
+
+	t.Run("all", func(t *testing.T) {
+		var got []string
+		for path, bookmark := range document.All() {
+			got = append(got, fmt.Sprintf("%q %s", path, bookmark.URL))
+		}
+
+		want := []string{
+			`[] https://root.domain.tld`,
+			`["Level 1"] https://l1.domain.tld`,
+			`["Level 1" "Level 2"] https://l2.domain.tld`,
+			`[] https://last.domain.tld`,
+		}
+
+		if !slices.Equal(got, want) {
+			t.Errorf("want %q, got %q", want, got)
+		}
+	})
+
+	t.Run("break", func(t *testing.T) {
+		var got []string
+		for _, bookmark := range document.All() {
+			got = append(got, bookmark.URL)
+			if len(got) == 2 {
+				break
+			}
+		}
+
+		want := []string{"https://root.domain.tld", "https://l1.domain.tld"}
+
+		if !slices.Equal(got, want) {
+			t.Errorf("want %q, got %q", want, got)
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+		for _, bookmark := range document.All() {
+			bookmark.Private = true
+		}
+
+		if !document.Root.Subfolders[0].Subfolders[0].Bookmarks[0].Private {
+			t.Error("want bookmark to be updated")
+		}
+	})
+}
+
+func TestDocumentFolders(t *testing.T) {
+	document := Document{
+		Root: Folder{
+			Name: "Bookmarks",
+			Subfolders: []Folder{
+				{
+					Name: "Level 1A",
+					Subfolders: []Folder{
+						{Name: "Level 2A"},
+					},
+				},
+				{Name: "Level 1B"},
+			},
+		},
+	}
+
+	t.Run("all", func(t *testing.T) {
+		var got []string
+		for path, folder := range document.Folders() {
+			got = append(got, fmt.Sprintf("%q %s", path, folder.Name))
+		}
+
+		want := []string{
+			`[] Bookmarks`,
+			`["Level 1A"] Level 1A`,
+			`["Level 1A" "Level 2A"] Level 2A`,
+			`["Level 1B"] Level 1B`,
+		}
+
+		if !slices.Equal(got, want) {
+			t.Errorf("want %q, got %q", want, got)
+		}
+	})
+
+	t.Run("break", func(t *testing.T) {
+		var got []Path
+		for path := range document.Folders() {
+			got = append(got, path)
+			if len(got) == 3 {
+				break
+			}
+		}
+
+		// paths remain valid after the iteration
+		want := []Path{{}, {"Level 1A"}, {"Level 1A", "Level 2A"}}
+
+		if !slices.EqualFunc(got, want, slices.Equal) {
+			t.Errorf("want %q, got %q", want, got)
+		}
+	})
+}
+
+func TestBookmarkNormalizedURL(t *testing.T) {
+	cases := []struct {
+		tname string
+		url   string
+		want  string
+	}{
+		{
+			tname: "normalized",
+			url:   "HTTPS://Example.com/?utm_source=x&id=1#top",
+			want:  "https://example.com?id=1",
+		},
+		{
+			tname: "invalid URL",
+			url:   "https://example.com:port/",
+			want:  "https://example.com:port/",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.tname, func(t *testing.T) {
+			bookmark := Bookmark{URL: tc.url}
+
+			if got := bookmark.NormalizedURL(); got != tc.want {
+				t.Errorf("want %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,265 @@
+// Copyright (c) VirtualTam
+// SPDX-License-Identifier: MIT
+
+package netscape
+
+import (
+	"net/url"
+	"strings"
+)
+
+// A DuplicateMatch selects how duplicate Bookmarks are detected.
+type DuplicateMatch int
+
+const (
+	// MatchURL detects Bookmarks with the same URL.
+	MatchURL DuplicateMatch = iota
+
+	// MatchNormalizedURL detects Bookmarks with the same normalized URL.
+	MatchNormalizedURL
+
+	// MatchTitleHost detects Bookmarks with the same title, ignoring case,
+	// and the same URL host. Bookmarks with an empty title or host are never
+	// duplicates.
+	MatchTitleHost
+
+	// MatchID detects Bookmarks with the same ID, as returned by Bookmark.ID.
+	MatchID
+)
+
+// A SurvivorPolicy selects which Bookmark of a DuplicateGroup is kept when
+// deduplicating, and thus in which Folder it lives.
+type SurvivorPolicy int
+
+const (
+	// SurvivorFirst keeps the first Bookmark, in document order.
+	SurvivorFirst SurvivorPolicy = iota
+
+	// SurvivorLast keeps the last Bookmark, in document order.
+	SurvivorLast
+
+	// SurvivorNewest keeps the most recently updated Bookmark.
+	SurvivorNewest
+
+	// SurvivorDeepest keeps the Bookmark in the most nested Folder.
+	SurvivorDeepest
+)
+
+// DuplicateOptions configures how duplicate Bookmarks are detected and
+// collapsed.
+type DuplicateOptions struct {
+	Match DuplicateMatch
+
+	// NormalizeURL returns the normalized URL used by MatchNormalizedURL.
+	//
+	// If nil, URLs are normalized with Bookmark.NormalizedURL.
+	NormalizeURL func(url string) string
+
+	// Survivor selects which Bookmark of each group is kept by Deduplicate.
+	Survivor SurvivorPolicy
+}
+
+// A DuplicateGroup lists Bookmarks detected as duplicates, in document order.
+type DuplicateGroup struct {
+	// Key shared by the duplicate Bookmarks, e.g. their normalized URL.
+	Key string
+
+	Entries []Entry
+}
+
+// FindDuplicates returns the groups of duplicate Bookmarks of this Document,
+// ordered by their first Bookmark.
+func (d *Document) FindDuplicates(opts DuplicateOptions) []DuplicateGroup {
+	groups, _ := d.findDuplicates(opts)
+
+	return groups
+}
+
+// Deduplicate collapses each group of duplicate Bookmarks into the Bookmark
+// selected by opts.Survivor, which is kept in its Folder, and returns the
+// collapsed groups.
+//
+// The tags of the duplicate Bookmarks are merged, in document order, and the
+// kept Bookmark gets their earliest creation date, latest update and visit
+// dates, and is private if any of them is private. Other fields are those of
+// the kept Bookmark.
+func (d *Document) Deduplicate(opts DuplicateOptions) []DuplicateGroup {
+	groups, indices := d.findDuplicates(opts)
+	if len(groups) == 0 {
+		return nil
+	}
+
+	// survivor Bookmark for each group, and group of each duplicate Bookmark
+	// by index in document order
+	survivors := make([]Bookmark, len(groups))
+	survivorIndices := make([]int, len(groups))
+	groupByIndex := make(map[int]int)
+
+	for g, group := range groups {
+		s := opts.Survivor.choose(group.Entries)
+
+		survivors[g] = collapseDuplicates(group.Entries, s)
+		survivorIndices[g] = indices[g][s]
+
+		for _, index := range indices[g] {
+			groupByIndex[index] = g
+		}
+	}
+
+	index := -1
+
+	d.Walk(func(_ []string, item Item) WalkAction {
+		bookmark, ok := item.(*Bookmark)
+		if !ok {
+			return WalkContinue
+		}
+
+		index++
+
+		g, ok := groupByIndex[index]
+		if !ok {
+			return WalkContinue
+		}
+
+		if survivorIndices[g] != index {
+			return WalkDelete
+		}
+
+		*bookmark = survivors[g]
+
+		return WalkContinue
+	})
+
+	return groups
+}
+
+// findDuplicates returns the groups of duplicate Bookmarks, along with the
+// index of each Bookmark in document order.
+func (d *Document) findDuplicates(opts DuplicateOptions) ([]DuplicateGroup, [][]int) {
+	var (
+		groups  []DuplicateGroup
+		indices [][]int
+	)
+
+	groupByKey := make(map[string]int)
+
+	index := -1
+
+	for path, bookmark := range d.All() {
+		index++
+
+		key, ok := opts.key(bookmark)
+		if !ok {
+			continue
+		}
+
+		g, ok := groupByKey[key]
+		if !ok {
+			g = len(groups)
+			groupByKey[key] = g
+			groups = append(groups, DuplicateGroup{Key: key})
+			indices = append(indices, nil)
+		}
+
+		groups[g].Entries = append(groups[g].Entries, Entry{Path: path, Bookmark: *bookmark})
+		indices[g] = append(indices[g], index)
+	}
+
+	// only keep the groups containing duplicates
+	n := 0
+	for g := range groups {
+		if len(groups[g].Entries) < 2 {
+			continue
+		}
+
+		groups[n] = groups[g]
+		indices[n] = indices[g]
+		n++
+	}
+
+	if n == 0 {
+		return nil, nil
+	}
+
+	return groups[:n], indices[:n]
+}
+
+// key returns the key shared by the duplicates of bookmark, if it can have
+// any.
+func (o *DuplicateOptions) key(bookmark *Bookmark) (string, bool) {
+	switch o.Match {
+	case MatchNormalizedURL:
+		if o.NormalizeURL != nil {
+			return o.NormalizeURL(bookmark.URL), true
+		}
+
+		return bookmark.NormalizedURL(), true
+	case MatchTitleHost:
+		title := strings.ToLower(strings.TrimSpace(bookmark.Title))
+		if title == "" {
+			return "", false
+		}
+
+		u, err := url.Parse(bookmark.URL)
+		if err != nil || u.Hostname() == "" {
+			return "", false
+		}
+
+		return title + " " + strings.ToLower(u.Hostname()), true
+	case MatchID:
+		return bookmark.ID(), true
+	default:
+		return bookmark.URL, true
+	}
+}
+
+// choose returns the index of the Entry that is kept.
+func (p SurvivorPolicy) choose(entries []Entry) int {
+	chosen := 0
+
+	for i := 1; i < len(entries); i++ {
+		switch p {
+		case SurvivorLast:
+			chosen = i
+		case SurvivorNewest:
+			if entries[i].Bookmark.UpdatedAt.After(entries[chosen].Bookmark.UpdatedAt) {
+				chosen = i
+			}
+		case SurvivorDeepest:
+			if len(entries[i].Path) > len(entries[chosen].Path) {
+				chosen = i
+			}
+		}
+	}
+
+	return chosen
+}
+
+// collapseDuplicates returns the Bookmark of the Entry with index survivor,
+// updated with the tags, dates and privacy of all entries.
+func collapseDuplicates(entries []Entry, survivor int) Bookmark {
+	bookmark := entries[survivor].Bookmark
+	bookmark.Tags = nil
+
+	for _, entry := range entries {
+		other := entry.Bookmark
+
+		bookmark.Tags = appendMissing(bookmark.Tags, other.Tags...)
+
+		if !other.CreatedAt.IsZero() && (bookmark.CreatedAt.IsZero() || other.CreatedAt.Before(bookmark.CreatedAt)) {
+			bookmark.CreatedAt = other.CreatedAt
+		}
+
+		if other.UpdatedAt.After(bookmark.UpdatedAt) {
+			bookmark.UpdatedAt = other.UpdatedAt
+		}
+
+		if other.LastVisitedAt.After(bookmark.LastVisitedAt) {
+			bookmark.LastVisitedAt = other.LastVisitedAt
+		}
+
+		bookmark.Private = bookmark.Private || other.Private
+	}
+
+	return bookmark
+}
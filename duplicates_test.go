@@ -0,0 +1,193 @@
+// Copyright (c) VirtualTam
+// SPDX-License-Identifier: MIT
+
+package netscape
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+// formatGroups returns a representation of the Path and URL of the Bookmarks
+// of each group.
+func formatGroups(groups []DuplicateGroup) [][]string {
+	var got [][]string
+
+	for _, group := range groups {
+		var entries []string
+		for _, entry := range group.Entries {
+			entries = append(entries, fmt.Sprintf("%s %s", FormatPath(entry.Path), entry.Bookmark.URL))
+		}
+		got = append(got, entries)
+	}
+
+	return got
+}
+
+func TestDocumentFindDuplicates(t *testing.T) {
+	cases := []struct {
+		tname string
+		opts  DuplicateOptions
+		want  [][]string
+	}{
+		{
+			tname: "exact URL",
+			want: [][]string{
+				{" https://go.dev", "Dev/Go https://go.dev"},
+			},
+		},
+		{
+			tname: "normalized URL",
+			opts:  DuplicateOptions{Match: MatchNormalizedURL},
+			want: [][]string{
+				{" https://go.dev", "Dev HTTPS://GO.dev/", "Dev/Go https://go.dev"},
+			},
+		},
+		{
+			tname: "custom URL normalization",
+			opts: DuplicateOptions{
+				Match: MatchNormalizedURL,
+				NormalizeURL: func(rawURL string) string {
+					return rawURL[:min(len(rawURL), len("https://go.dev"))]
+				},
+			},
+			want: [][]string{
+				{" https://go.dev", "Dev/Go https://go.dev/doc", "Dev/Go https://go.dev"},
+			},
+		},
+		{
+			tname: "title and host",
+			opts:  DuplicateOptions{Match: MatchTitleHost},
+			want: [][]string{
+				{" https://go.dev", "Dev/Go https://go.dev/doc", "Dev/Go https://go.dev"},
+			},
+		},
+		{
+			// IDs are derived from the creation dates, which differ
+			tname: "ID",
+			opts:  DuplicateOptions{Match: MatchID},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.tname, func(t *testing.T) {
+			document := loadTestDocument(t, "duplicates.htm")
+
+			got := formatGroups(document.FindDuplicates(tc.opts))
+
+			if !slices.EqualFunc(got, tc.want, slices.Equal) {
+				t.Errorf("want groups %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestDocumentDeduplicate(t *testing.T) {
+	merged := func(title, url string) Bookmark {
+		return Bookmark{
+			CreatedAt: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+			UpdatedAt: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+			Title:     title,
+			URL:       url,
+			Private:   true,
+			Tags:      []string{"golang", "go", "reference"},
+		}
+	}
+
+	docGo := Bookmark{
+		UpdatedAt: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		Title:     "go",
+		URL:       "https://go.dev/doc",
+		Tags:      []string{"docs"},
+	}
+	example := Bookmark{Title: "Example", URL: "https://example.com"}
+
+	cases := []struct {
+		tname    string
+		survivor SurvivorPolicy
+		want     map[string][]Bookmark
+	}{
+		{
+			tname:    "first",
+			survivor: SurvivorFirst,
+			want: map[string][]Bookmark{
+				"":       {merged("Go", "https://go.dev"), example},
+				"Dev":    {},
+				"Dev/Go": {docGo},
+			},
+		},
+		{
+			tname:    "last",
+			survivor: SurvivorLast,
+			want: map[string][]Bookmark{
+				"":       {example},
+				"Dev":    {},
+				"Dev/Go": {docGo, merged("Go", "https://go.dev")},
+			},
+		},
+		{
+			tname:    "newest",
+			survivor: SurvivorNewest,
+			want: map[string][]Bookmark{
+				"":       {example},
+				"Dev":    {merged("The Go Programming Language", "HTTPS://GO.dev/")},
+				"Dev/Go": {docGo},
+			},
+		},
+		{
+			tname:    "deepest",
+			survivor: SurvivorDeepest,
+			want: map[string][]Bookmark{
+				"":       {example},
+				"Dev":    {},
+				"Dev/Go": {docGo, merged("Go", "https://go.dev")},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.tname, func(t *testing.T) {
+			document := loadTestDocument(t, "duplicates.htm")
+
+			groups := document.Deduplicate(DuplicateOptions{
+				Match:    MatchNormalizedURL,
+				Survivor: tc.survivor,
+			})
+
+			if len(groups) != 1 || len(groups[0].Entries) != 3 {
+				t.Fatalf("want 1 group of 3 bookmarks, got %q", formatGroups(groups))
+			}
+
+			for pathStr, want := range tc.want {
+				path, _ := ParsePath(pathStr)
+
+				folder, err := document.FindFolder(path)
+				if err != nil {
+					t.Fatalf("expected no error, got %q", err)
+				}
+
+				if len(folder.Bookmarks) != len(want) {
+					t.Fatalf("want %d bookmarks in %q, got %d", len(want), pathStr, len(folder.Bookmarks))
+				}
+
+				for i := range want {
+					assertBookmarksEqual(t, folder.Bookmarks[i], want[i])
+				}
+			}
+		})
+	}
+
+	t.Run("no duplicates", func(t *testing.T) {
+		document := loadTestDocument(t, "duplicates.htm")
+
+		if groups := document.Deduplicate(DuplicateOptions{Match: MatchURL}); len(groups) != 1 {
+			t.Fatalf("want 1 group, got %d", len(groups))
+		}
+
+		if groups := document.Deduplicate(DuplicateOptions{Match: MatchURL}); groups != nil {
+			t.Errorf("want no groups, got %q", formatGroups(groups))
+		}
+	})
+}
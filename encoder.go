@@ -5,41 +5,256 @@ package netscape
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"html"
 	"io"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
+)
+
+var (
+	ErrEncoderSequenceInvalid = errors.New("invalid encoder call sequence")
 )
 
 // An Encoder writes Netscape Bookmark data to an output stream.
+//
+// An Encoder either writes a complete Document with Encode, or writes a
+// document incrementally with StartDocument, StartFolder, WriteBookmark,
+// WriteSeparator, EndFolder and Close.
 type Encoder struct {
 	p printer
+
+	started     bool
+	openFolders int
+	rootClosed  bool
+	closed      bool
+}
+
+// An EncoderOption configures an Encoder.
+type EncoderOption func(*Encoder)
+
+// WithIndent sets the string used to indent nested elements.
+//
+// Defaults to four spaces.
+func WithIndent(indent string) EncoderOption {
+	return func(e *Encoder) {
+		e.p.indent = indent
+	}
+}
+
+// WithCRLF makes the Encoder write CRLF line endings instead of LF.
+func WithCRLF() EncoderOption {
+	return func(e *Encoder) {
+		e.p.crlf = true
+	}
+}
+
+// WithHeaderComment sets the comment written after the DOCTYPE declaration.
+//
+// An empty comment is omitted, and "--" sequences, that would end the comment
+// early, are written as "- -". Defaults to the "DO NOT EDIT!" notice written
+// by Netscape and most Web browsers.
+func WithHeaderComment(comment string) EncoderOption {
+	return func(e *Encoder) {
+		e.p.headerComment = comment
+	}
+}
+
+// WithCharsetMeta makes the Encoder write a META element declaring the UTF-8
+// character encoding, as Web browsers do.
+func WithCharsetMeta() EncoderOption {
+	return func(e *Encoder) {
+		e.p.charsetMeta = true
+	}
+}
+
+// WithOmitDefaults makes the Encoder omit attributes set to the value the
+// Decoder assumes when they are missing: PRIVATE="0", and LAST_MODIFIED when
+// it is equal to ADD_DATE.
+func WithOmitDefaults() EncoderOption {
+	return func(e *Encoder) {
+		e.p.omitDefaults = true
+	}
+}
+
+// WithTimestampPrecision sets the precision of the UNIX timestamps written
+// for dates: time.Second, time.Millisecond, time.Microsecond or
+// time.Nanosecond.
+//
+// Defaults to time.Second.
+func WithTimestampPrecision(precision time.Duration) EncoderOption {
+	return func(e *Encoder) {
+		e.p.precision = precision
+	}
 }
 
 // NewEncoder returns a new encoder that writes to w.
-func NewEncoder(w io.Writer) *Encoder {
-	return &Encoder{
-		printer{
-			Writer: bufio.NewWriter(w),
-			indent: "    ",
+func NewEncoder(w io.Writer, opts ...EncoderOption) *Encoder {
+	e := &Encoder{
+		p: printer{
+			indent:        defaultIndent,
+			headerComment: defaultHeaderComment,
+			precision:     time.Second,
+			fields:        allFields,
 		},
 	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	if e.p.crlf {
+		w = &crlfWriter{w: w}
+	}
+	e.p.Writer = bufio.NewWriter(w)
+
+	return e
 }
 
 // Encode writes the Netscape Bookmark encoding of d to the stream.
 func (e *Encoder) Encode(d *Document) error {
+	if e.started {
+		return fmt.Errorf("%w: Encode called on a streamed document", ErrEncoderSequenceInvalid)
+	}
+
 	if err := e.p.marshalDocument(d); err != nil {
 		return err
 	}
 	return e.p.Flush()
 }
 
+// StartDocument writes the document header, with the given title.
+//
+// StartDocument must be called once, before any other streaming method. The
+// first call to StartFolder then writes the root folder.
+func (e *Encoder) StartDocument(title string) error {
+	if e.started {
+		return fmt.Errorf("%w: StartDocument called twice", ErrEncoderSequenceInvalid)
+	}
+
+	e.started = true
+
+	return e.p.marshalHeader(title)
+}
+
+// StartFolder writes the header of folder f, and opens it: subsequent
+// bookmarks, separators and folders are written into f until the matching
+// call to EndFolder.
+//
+// The bookmarks, separators and subfolders of f are not written.
+func (e *Encoder) StartFolder(f *Folder) error {
+	switch {
+	case !e.started:
+		return fmt.Errorf("%w: StartFolder called before StartDocument", ErrEncoderSequenceInvalid)
+	case e.rootClosed:
+		return fmt.Errorf("%w: StartFolder called after the root folder was closed", ErrEncoderSequenceInvalid)
+	}
+
+	isRoot := e.openFolders == 0
+	e.openFolders++
+
+	return e.p.startFolder(f, isRoot)
+}
+
+// WriteBookmark writes bookmark b into the current folder.
+func (e *Encoder) WriteBookmark(b *Bookmark) error {
+	if e.openFolders == 0 {
+		return fmt.Errorf("%w: WriteBookmark called outside of a folder", ErrEncoderSequenceInvalid)
+	}
+
+	return e.p.marshalBookmark(b)
+}
+
+// WriteSeparator writes separator s into the current folder.
+func (e *Encoder) WriteSeparator(s *Separator) error {
+	if e.openFolders == 0 {
+		return fmt.Errorf("%w: WriteSeparator called outside of a folder", ErrEncoderSequenceInvalid)
+	}
+
+	return e.p.marshalSeparator(s)
+}
+
+// EndFolder closes the current folder.
+func (e *Encoder) EndFolder() error {
+	if e.openFolders == 0 {
+		return fmt.Errorf("%w: EndFolder called without a matching StartFolder", ErrEncoderSequenceInvalid)
+	}
+
+	e.openFolders--
+	if e.openFolders == 0 {
+		e.rootClosed = true
+	}
+
+	return e.p.endFolder()
+}
+
+// Close checks that the streamed document is complete, and flushes buffered
+// data to the underlying writer.
+//
+// Close does not close the underlying writer.
+func (e *Encoder) Close() error {
+	switch {
+	case e.closed:
+		return fmt.Errorf("%w: Close called twice", ErrEncoderSequenceInvalid)
+	case !e.started:
+		return fmt.Errorf("%w: Close called before StartDocument", ErrEncoderSequenceInvalid)
+	case e.openFolders > 0:
+		return fmt.Errorf("%w: %d folder(s) left open", ErrEncoderSequenceInvalid, e.openFolders)
+	case !e.rootClosed:
+		return fmt.Errorf("%w: missing root folder", ErrEncoderSequenceInvalid)
+	}
+
+	e.closed = true
+
+	return e.p.Flush()
+}
+
+// A crlfWriter converts LF line endings to CRLF, leaving existing CRLF line
+// endings untouched.
+type crlfWriter struct {
+	w    io.Writer
+	last byte
+}
+
+func (cw *crlfWriter) Write(p []byte) (int, error) {
+	buf := make([]byte, 0, len(p)+bytes.Count(p, []byte("\n")))
+
+	for _, b := range p {
+		if b == '\n' && cw.last != '\r' {
+			buf = append(buf, '\r')
+		}
+		buf = append(buf, b)
+		cw.last = b
+	}
+
+	if _, err := cw.w.Write(buf); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
 type printer struct {
 	*bufio.Writer
 	depth  int
 	indent string
+
+	crlf          bool
+	headerComment string
+	charsetMeta   bool
+	extraHeader   string
+	omitDefaults  bool
+	precision     time.Duration
+
+	fields               fieldSet
+	attributes           []string
+	lastModifiedFallback bool
+	attributeNames       map[string]string
 }
 
 func (p *printer) writeString(s string) (int, error) {
@@ -52,13 +267,56 @@ func (p *printer) writeString(s string) (int, error) {
 	return p.WriteString(s)
 }
 
-func (p *printer) marshalDocument(d *Document) error {
-	const header = `<!DOCTYPE NETSCAPE-Bookmark-file-1>
-<!-- This is an automatically generated file.
+// formatTimestamp returns the UNIX timestamp for t, with the configured
+// precision.
+func (p *printer) formatTimestamp(t time.Time) string {
+	switch p.precision {
+	case time.Millisecond:
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	case time.Microsecond:
+		return strconv.FormatInt(t.UnixMicro(), 10)
+	case time.Nanosecond:
+		return strconv.FormatInt(t.UnixNano(), 10)
+	default:
+		return strconv.FormatInt(t.Unix(), 10)
+	}
+}
+
+// escapeText returns the escaped representation of s, as written by
+// xml.Marshal for the text of the <H3> and <A> elements.
+func escapeText(s string) string {
+	var b strings.Builder
+
+	// writing to a strings.Builder never fails
+	_ = xml.EscapeText(&b, []byte(s))
+
+	return b.String()
+}
+
+// escapeComment returns s, with the "--" sequences that would end an HTML
+// comment early broken up as "- -".
+func escapeComment(s string) string {
+	for strings.Contains(s, "--") {
+		s = strings.ReplaceAll(s, "--", "- -")
+	}
+
+	return s
+}
+
+const (
+	defaultIndent string = "    "
+
+	doctype string = "<!DOCTYPE NETSCAPE-Bookmark-file-1>"
+
+	defaultHeaderComment string = `This is an automatically generated file.
      It will be read and overwritten.
-     DO NOT EDIT! -->`
+     DO NOT EDIT!`
 
-	if _, err := fmt.Fprintf(p, "%s\n<TITLE>%s</TITLE>\n", header, d.Title); err != nil {
+	charsetMeta string = `<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">`
+)
+
+func (p *printer) marshalDocument(d *Document) error {
+	if err := p.marshalHeader(d.Title); err != nil {
 		return err
 	}
 
@@ -69,6 +327,43 @@ func (p *printer) marshalDocument(d *Document) error {
 	return nil
 }
 
+func (p *printer) marshalHeader(title string) error {
+	if _, err := fmt.Fprintf(p, "%s\n", doctype); err != nil {
+		return err
+	}
+
+	if p.headerComment != "" {
+		if _, err := fmt.Fprintf(p, "<!-- %s -->\n", escapeComment(p.headerComment)); err != nil {
+			return err
+		}
+	}
+
+	if p.charsetMeta {
+		if _, err := fmt.Fprintf(p, "%s\n", charsetMeta); err != nil {
+			return err
+		}
+	}
+
+	if p.extraHeader != "" {
+		if _, err := fmt.Fprintf(p, "%s\n", p.extraHeader); err != nil {
+			return err
+		}
+	}
+
+	if _, err := fmt.Fprintf(p, "<TITLE>%s</TITLE>\n", escapeText(title)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// A typedAttr references the value of an attribute written from a typed
+// field.
+type typedAttr struct {
+	name  string
+	value *string
+}
+
 type netscapeH3 struct {
 	XMLName xml.Name `xml:"H3"`
 
@@ -77,25 +372,42 @@ type netscapeH3 struct {
 	AddDate      string `xml:"ADD_DATE,attr,omitempty"`
 	LastModified string `xml:"LAST_MODIFIED,attr,omitempty"`
 
+	ToolbarFolder string `xml:"PERSONAL_TOOLBAR_FOLDER,attr,omitempty"`
+	UnfiledFolder string `xml:"UNFILED_BOOKMARKS_FOLDER,attr,omitempty"`
+	Folded        string `xml:"FOLDED,attr,omitempty"`
+
 	Attrs []xml.Attr `xml:",attr,omitempty"`
 }
 
-func newNetscapeH3(f *Folder) *netscapeH3 {
+func (p *printer) newNetscapeH3(f *Folder) *netscapeH3 {
 	h3 := netscapeH3{
 		Name: f.Name,
 	}
 
-	if !f.CreatedAt.IsZero() {
-		h3.AddDate = fmt.Sprintf("%d", f.CreatedAt.Unix())
+	// Only the toolbar and unfiled folders are marked with an attribute; the
+	// bookmarks menu and mobile folders are identified by their name.
+	switch {
+	case f.Role == FolderRoleToolbar && p.fields.has(fieldToolbarFolder):
+		h3.ToolbarFolder = "true"
+	case f.Role == FolderRoleUnfiled && p.fields.has(fieldUnfiledFolder):
+		h3.UnfiledFolder = "true"
+	}
+
+	if f.Folded && p.fields.has(fieldFolded) {
+		h3.Folded = "FOLDED"
 	}
 
-	if !f.UpdatedAt.IsZero() {
-		h3.LastModified = fmt.Sprintf("%d", f.UpdatedAt.Unix())
+	if !f.CreatedAt.IsZero() && p.fields.has(fieldAddDate) {
+		h3.AddDate = p.formatTimestamp(f.CreatedAt)
 	}
 
+	h3.LastModified = p.lastModified(fieldFolderLastModified, f.CreatedAt, f.UpdatedAt)
+
 	var keys []string
 	for k := range f.Attributes {
-		keys = append(keys, k)
+		if p.writesAttribute(k) {
+			keys = append(keys, k)
+		}
 	}
 	sort.Strings(keys)
 
@@ -104,11 +416,47 @@ func newNetscapeH3(f *Folder) *netscapeH3 {
 		h3.Attrs = append(h3.Attrs, attr)
 	}
 
+	h3.Attrs = p.renameAttributes(h3.typedAttributes(), h3.Attrs)
+
 	return &h3
 }
 
+// typedAttributes returns the typed attributes of this element, in order.
+func (h3 *netscapeH3) typedAttributes() []typedAttr {
+	return []typedAttr{
+		{createdAtAttr, &h3.AddDate},
+		{updatedAtAttr, &h3.LastModified},
+		{toolbarFolderAttr, &h3.ToolbarFolder},
+		{unfiledFolderAttr, &h3.UnfiledFolder},
+		{foldedAttr, &h3.Folded},
+	}
+}
+
+// lastModified returns the value of the LAST_MODIFIED attribute of an item
+// with the given creation and update dates, or an empty string if it is
+// omitted or if field is not written.
+func (p *printer) lastModified(field fieldSet, createdAt, updatedAt time.Time) string {
+	if updatedAt.IsZero() && p.lastModifiedFallback {
+		updatedAt = createdAt
+	}
+
+	if updatedAt.IsZero() || !p.fields.has(field) {
+		return ""
+	}
+
+	lastModified := p.formatTimestamp(updatedAt)
+
+	// The Decoder sets the update date to the creation date when LAST_MODIFIED
+	// is missing.
+	if p.omitDefaults && !p.lastModifiedFallback && !createdAt.IsZero() && p.fields.has(fieldAddDate) && lastModified == p.formatTimestamp(createdAt) {
+		return ""
+	}
+
+	return lastModified
+}
+
 func (p *printer) marshalFolderHeader(f *Folder) error {
-	h3 := newNetscapeH3(f)
+	h3 := p.newNetscapeH3(f)
 
 	m, err := xml.Marshal(h3)
 	if err != nil {
@@ -124,19 +472,45 @@ func (p *printer) marshalFolderHeader(f *Folder) error {
 }
 
 func (p *printer) marshalFolder(f *Folder, isRoot bool) error {
+	if err := p.startFolder(f, isRoot); err != nil {
+		return err
+	}
+
+	for _, ref := range f.orderedItems() {
+		switch ref.Kind {
+		case BookmarkKind:
+			if err := p.marshalBookmark(&f.Bookmarks[ref.Index]); err != nil {
+				return err
+			}
+		case FolderKind:
+			if err := p.marshalFolder(&f.Subfolders[ref.Index], false); err != nil {
+				return err
+			}
+		case SeparatorKind:
+			if err := p.marshalSeparator(&f.Separators[ref.Index]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return p.endFolder()
+}
+
+// startFolder writes the header of folder f, and increases the depth.
+func (p *printer) startFolder(f *Folder, isRoot bool) error {
 	if !isRoot {
 		if err := p.marshalFolderHeader(f); err != nil {
 			return err
 		}
 	} else {
-		_, err := p.writeString(fmt.Sprintf("<H1>%s</H1>\n", f.Name))
+		_, err := p.writeString(fmt.Sprintf("<H1>%s</H1>\n", escapeText(f.Name)))
 		if err != nil {
 			return err
 		}
 	}
 
-	if f.Description != "" {
-		_, err := p.writeString(fmt.Sprintf("<DD>%s\n", f.Description))
+	if f.Description != "" && p.fields.has(fieldDescription) {
+		_, err := p.writeString(fmt.Sprintf("<DD>%s\n", html.EscapeString(f.Description)))
 		if err != nil {
 			return err
 		}
@@ -148,21 +522,14 @@ func (p *printer) marshalFolder(f *Folder, isRoot bool) error {
 
 	p.depth++
 
-	for _, b := range f.Bookmarks {
-		if err := p.marshalBookmark(&b); err != nil {
-			return err
-		}
-	}
-
-	for _, sf := range f.Subfolders {
-		if err := p.marshalFolder(&sf, false); err != nil {
-			return err
-		}
-	}
+	return nil
+}
 
+// endFolder decreases the depth, and closes the current folder.
+func (p *printer) endFolder() error {
 	p.depth--
 
-	_, err = p.writeString("</DL><p>\n")
+	_, err := p.writeString("</DL><p>\n")
 	if err != nil {
 		return err
 	}
@@ -178,36 +545,74 @@ type netscapeA struct {
 
 	AddDate      string `xml:"ADD_DATE,attr,omitempty"`
 	LastModified string `xml:"LAST_MODIFIED,attr,omitempty"`
+	LastVisit    string `xml:"LAST_VISIT,attr,omitempty"`
 
-	Private int    `xml:"PRIVATE,attr"`
+	Private string `xml:"PRIVATE,attr,omitempty"`
 	Tags    string `xml:"TAGS,attr,omitempty"`
 
+	IconURI  string `xml:"ICON_URI,attr,omitempty"`
+	Icon     string `xml:"ICON,attr,omitempty"`
+	Keyword  string `xml:"SHORTCUTURL,attr,omitempty"`
+	PostData string `xml:"POST_DATA,attr,omitempty"`
+	Charset  string `xml:"LAST_CHARSET,attr,omitempty"`
+
 	Attrs []xml.Attr `xml:",attr,omitempty"`
 }
 
-func newNetscapeA(b *Bookmark) *netscapeA {
+func (p *printer) newNetscapeA(b *Bookmark) *netscapeA {
 	a := netscapeA{
 		Href:  b.URL,
 		Title: b.Title,
-		Tags:  strings.Join(b.Tags, ","),
 		Attrs: []xml.Attr{},
 	}
 
-	if !b.CreatedAt.IsZero() {
-		a.AddDate = fmt.Sprintf("%d", b.CreatedAt.Unix())
+	if p.fields.has(fieldTags) {
+		a.Tags = strings.Join(b.Tags, ",")
 	}
 
-	if !b.UpdatedAt.IsZero() {
-		a.LastModified = fmt.Sprintf("%d", b.UpdatedAt.Unix())
+	if p.fields.has(fieldIconURI) {
+		a.IconURI = b.IconURI
 	}
 
-	if b.Private {
-		a.Private = 1
+	if p.fields.has(fieldIcon) {
+		a.Icon = b.Icon.DataURI()
+	}
+
+	if p.fields.has(fieldKeyword) {
+		a.Keyword = b.Keyword
+	}
+
+	if p.fields.has(fieldPostData) {
+		a.PostData = b.PostData
+	}
+
+	if p.fields.has(fieldCharset) {
+		a.Charset = b.Charset
+	}
+
+	if !b.CreatedAt.IsZero() && p.fields.has(fieldAddDate) {
+		a.AddDate = p.formatTimestamp(b.CreatedAt)
+	}
+
+	a.LastModified = p.lastModified(fieldLastModified, b.CreatedAt, b.UpdatedAt)
+
+	if !b.LastVisitedAt.IsZero() && p.fields.has(fieldLastVisit) {
+		a.LastVisit = p.formatTimestamp(b.LastVisitedAt)
+	}
+
+	switch {
+	case !p.fields.has(fieldPrivate):
+	case b.Private:
+		a.Private = "1"
+	case !p.omitDefaults:
+		a.Private = "0"
 	}
 
 	var keys []string
 	for k := range b.Attributes {
-		keys = append(keys, k)
+		if p.writesAttribute(k) {
+			keys = append(keys, k)
+		}
 	}
 	sort.Strings(keys)
 
@@ -216,11 +621,29 @@ func newNetscapeA(b *Bookmark) *netscapeA {
 		a.Attrs = append(a.Attrs, attr)
 	}
 
+	a.Attrs = p.renameAttributes(a.typedAttributes(), a.Attrs)
+
 	return &a
 }
 
+// typedAttributes returns the typed attributes of this element, in order.
+func (a *netscapeA) typedAttributes() []typedAttr {
+	return []typedAttr{
+		{createdAtAttr, &a.AddDate},
+		{updatedAtAttr, &a.LastModified},
+		{lastVisitAttr, &a.LastVisit},
+		{privateAttr, &a.Private},
+		{tagsAttr, &a.Tags},
+		{iconURIAttr, &a.IconURI},
+		{iconAttr, &a.Icon},
+		{keywordAttr, &a.Keyword},
+		{postDataAttr, &a.PostData},
+		{charsetAttr, &a.Charset},
+	}
+}
+
 func (p *printer) marshalBookmark(b *Bookmark) error {
-	a := newNetscapeA(b)
+	a := p.newNetscapeA(b)
 
 	m, err := xml.Marshal(a)
 	if err != nil {
@@ -232,7 +655,7 @@ func (p *printer) marshalBookmark(b *Bookmark) error {
 		return err
 	}
 
-	if b.Description != "" {
+	if b.Description != "" && p.fields.has(fieldDescription) {
 		_, err = p.writeString(fmt.Sprintf("<DD>%s\n", html.EscapeString(b.Description)))
 		if err != nil {
 			return err
@@ -241,3 +664,31 @@ func (p *printer) marshalBookmark(b *Bookmark) error {
 
 	return nil
 }
+
+func (p *printer) marshalSeparator(s *Separator) error {
+	if !p.fields.has(fieldSeparator) {
+		return nil
+	}
+
+	var keys []string
+	for k := range s.Attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var attrs strings.Builder
+	for _, k := range keys {
+		attrs.WriteString(" ")
+		attrs.WriteString(k)
+		attrs.WriteString(`="`)
+		attrs.WriteString(html.EscapeString(s.Attributes[k]))
+		attrs.WriteString(`"`)
+	}
+
+	_, err := p.writeString(fmt.Sprintf("<HR%s>\n", attrs.String()))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -5,6 +5,7 @@ package netscape_test
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/virtualtam/netscape-go/v2"
 )
@@ -78,3 +79,41 @@ func ExampleMarshal() {
 	//     </DL><p>
 	// </DL><p>
 }
+
+func ExampleEncoder_StartDocument() {
+	e := netscape.NewEncoder(os.Stdout, netscape.WithHeaderComment(""), netscape.WithOmitDefaults())
+
+	steps := []func() error{
+		func() error { return e.StartDocument("Bookmarks") },
+		func() error { return e.StartFolder(&netscape.Folder{Name: "Bookmarks"}) },
+		func() error {
+			return e.WriteBookmark(&netscape.Bookmark{URL: "https://domain.tld", Title: "Test Domain"})
+		},
+		func() error { return e.StartFolder(&netscape.Folder{Name: "Sub"}) },
+		func() error {
+			return e.WriteBookmark(&netscape.Bookmark{URL: "https://local.domain.tld", Title: "Local Test Domain"})
+		},
+		e.EndFolder,
+		e.EndFolder,
+		e.Close,
+	}
+
+	for _, step := range steps {
+		if err := step(); err != nil {
+			fmt.Println("failed to write document:", err)
+			os.Exit(1)
+		}
+	}
+
+	// Output:
+	// <!DOCTYPE NETSCAPE-Bookmark-file-1>
+	// <TITLE>Bookmarks</TITLE>
+	// <H1>Bookmarks</H1>
+	// <DL><p>
+	//     <DT><A HREF="https://domain.tld">Test Domain</A>
+	//     <DT><H3>Sub</H3>
+	//     <DL><p>
+	//         <DT><A HREF="https://local.domain.tld">Local Test Domain</A>
+	//     </DL><p>
+	// </DL><p>
+}
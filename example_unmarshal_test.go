@@ -107,3 +107,33 @@ func ExampleUnmarshal() {
 	//   }
 	// }
 }
+
+func ExampleUnmarshalLenient() {
+	blob := `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<TITLE>Bookmarks</TITLE>
+<DL><p>
+    <DT><H3></H3>
+	<DL><p>
+		<DT><A HREF="https://go.dev" ADD_DATE="yesterday">Go</A>
+	</DL><p>
+</DL><p>
+`
+
+	document, diagnostics, err := netscape.UnmarshalLenient([]byte(blob))
+	if err != nil {
+		fmt.Println("failed to unmarshal file:", err)
+		os.Exit(1)
+	}
+
+	for _, diagnostic := range diagnostics {
+		fmt.Println(diagnostic)
+	}
+
+	fmt.Println(document.Root.Name, "/", document.Root.Subfolders[0].Name, "/", document.Root.Subfolders[0].Bookmarks[0].Title)
+
+	// Output:
+	// line 3, column 1: warning: created a root folder: missing root folder (<H1> tag)
+	// line 4, column 9: warning: named folder "Untitled": empty folder title
+	// line 6, column 7: warning: kept undecodable ADD_DATE attribute: invalid date "yesterday": parsing time "yesterday" as "02/Jan/2006:15:04:05 -0700": cannot parse "yesterday" as "02"
+	// Bookmarks / Untitled / Go
+}
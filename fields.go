@@ -0,0 +1,252 @@
+// Copyright (c) VirtualTam
+// SPDX-License-Identifier: MIT
+
+package netscape
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	attributeFieldPrefix string = "attributes."
+)
+
+// An itemField reads and writes the string representation of a field of a
+// Bookmark or Folder, as described by FieldChange.
+type itemField[T any] struct {
+	name string
+	get  func(item *T) string
+	set  func(item *T, value string) error
+}
+
+// itemFields lists the fields of a Bookmark or Folder that are compared by
+// Diff, and updated by patches.
+type itemFields[T any] struct {
+	fields     []itemField[T]
+	attributes func(item *T) *map[string]string
+}
+
+var bookmarkFields = itemFields[Bookmark]{
+	fields: []itemField[Bookmark]{
+		timeField("created_at", func(b *Bookmark) *time.Time { return &b.CreatedAt }),
+		timeField("updated_at", func(b *Bookmark) *time.Time { return &b.UpdatedAt }),
+		stringField("title", func(b *Bookmark) *string { return &b.Title }),
+		stringField("url", func(b *Bookmark) *string { return &b.URL }),
+		stringField("description", func(b *Bookmark) *string { return &b.Description }),
+		boolField("private", func(b *Bookmark) *bool { return &b.Private }),
+		{
+			name: "tags",
+			get: func(b *Bookmark) string {
+				if len(b.Tags) == 0 {
+					return ""
+				}
+
+				// tags may contain commas, and are encoded as a JSON array
+				data, _ := json.Marshal(b.Tags)
+				return string(data)
+			},
+			set: func(b *Bookmark, value string) error {
+				b.Tags = nil
+				if value == "" {
+					return nil
+				}
+
+				return json.Unmarshal([]byte(value), &b.Tags)
+			},
+		},
+		timeField("last_visited_at", func(b *Bookmark) *time.Time { return &b.LastVisitedAt }),
+		{
+			name: "icon",
+			get: func(b *Bookmark) string {
+				return b.Icon.DataURI()
+			},
+			set: func(b *Bookmark, value string) error {
+				if value == "" {
+					b.Icon = Icon{}
+					return nil
+				}
+
+				var d Decoder
+				icon, err := d.decodeIcon(value)
+				if err != nil {
+					return err
+				}
+
+				b.Icon = icon
+				return nil
+			},
+		},
+		stringField("icon_uri", func(b *Bookmark) *string { return &b.IconURI }),
+		stringField("keyword", func(b *Bookmark) *string { return &b.Keyword }),
+		stringField("charset", func(b *Bookmark) *string { return &b.Charset }),
+		stringField("post_data", func(b *Bookmark) *string { return &b.PostData }),
+	},
+	attributes: func(b *Bookmark) *map[string]string { return &b.Attributes },
+}
+
+// folderFields lists the fields of a Folder, except its name, which is
+// handled separately to detect renamed Folders.
+var folderFields = itemFields[Folder]{
+	fields: []itemField[Folder]{
+		timeField("created_at", func(f *Folder) *time.Time { return &f.CreatedAt }),
+		timeField("updated_at", func(f *Folder) *time.Time { return &f.UpdatedAt }),
+		stringField("description", func(f *Folder) *string { return &f.Description }),
+		{
+			name: "role",
+			get: func(f *Folder) string {
+				return f.Role.String()
+			},
+			set: func(f *Folder, value string) error {
+				return f.Role.UnmarshalText([]byte(value))
+			},
+		},
+		boolField("folded", func(f *Folder) *bool { return &f.Folded }),
+	},
+	attributes: func(f *Folder) *map[string]string { return &f.Attributes },
+}
+
+func stringField[T any](name string, field func(item *T) *string) itemField[T] {
+	return itemField[T]{
+		name: name,
+		get: func(item *T) string {
+			return *field(item)
+		},
+		set: func(item *T, value string) error {
+			*field(item) = value
+			return nil
+		},
+	}
+}
+
+func boolField[T any](name string, field func(item *T) *bool) itemField[T] {
+	return itemField[T]{
+		name: name,
+		get: func(item *T) string {
+			return strconv.FormatBool(*field(item))
+		},
+		set: func(item *T, value string) error {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return err
+			}
+
+			*field(item) = b
+			return nil
+		},
+	}
+}
+
+func timeField[T any](name string, field func(item *T) *time.Time) itemField[T] {
+	return itemField[T]{
+		name: name,
+		get: func(item *T) string {
+			return formatChangeTime(*field(item))
+		},
+		set: func(item *T, value string) error {
+			if value == "" {
+				*field(item) = time.Time{}
+				return nil
+			}
+
+			t, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				return err
+			}
+
+			*field(item) = t.UTC()
+			return nil
+		},
+	}
+}
+
+func formatChangeTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// changes returns the fields that differ between the old and new items.
+func (f itemFields[T]) changes(oldItem, newItem *T) []FieldChange {
+	var changes []FieldChange
+
+	for _, field := range f.fields {
+		if oldValue, newValue := field.get(oldItem), field.get(newItem); oldValue != newValue {
+			changes = append(changes, FieldChange{Field: field.name, Old: oldValue, New: newValue})
+		}
+	}
+
+	oldAttributes, newAttributes := *f.attributes(oldItem), *f.attributes(newItem)
+
+	names := slices.Collect(maps.Keys(oldAttributes))
+	for name := range newAttributes {
+		if _, ok := oldAttributes[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		if oldValue, newValue := oldAttributes[name], newAttributes[name]; oldValue != newValue {
+			changes = append(changes, FieldChange{Field: attributeFieldPrefix + name, Old: oldValue, New: newValue})
+		}
+	}
+
+	return changes
+}
+
+// get returns the value of the field with the given name.
+func (f itemFields[T]) get(item *T, name string) (string, error) {
+	if attribute, ok := strings.CutPrefix(name, attributeFieldPrefix); ok {
+		return (*f.attributes(item))[attribute], nil
+	}
+
+	for _, field := range f.fields {
+		if field.name == name {
+			return field.get(item), nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: unknown field %q", ErrPatchInvalid, name)
+}
+
+// set sets the value of the field with the given name; setting an attribute
+// to an empty value removes it.
+func (f itemFields[T]) set(item *T, name, value string) error {
+	if attribute, ok := strings.CutPrefix(name, attributeFieldPrefix); ok {
+		attributes := f.attributes(item)
+
+		if value == "" {
+			delete(*attributes, attribute)
+			return nil
+		}
+
+		if *attributes == nil {
+			*attributes = make(map[string]string)
+		}
+		(*attributes)[attribute] = value
+
+		return nil
+	}
+
+	for _, field := range f.fields {
+		if field.name != name {
+			continue
+		}
+
+		if err := field.set(item, value); err != nil {
+			return fmt.Errorf("%w: invalid value %q for field %q: %w", ErrPatchInvalid, value, name, err)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("%w: unknown field %q", ErrPatchInvalid, name)
+}
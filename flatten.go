@@ -0,0 +1,238 @@
+// Copyright (c) VirtualTam
+// SPDX-License-Identifier: MIT
+
+package netscape
+
+import (
+	"maps"
+	"slices"
+	"strings"
+)
+
+// A TagCase controls the case of tags generated from folder names.
+type TagCase int
+
+const (
+	// TagCasePreserve keeps folder names as they are.
+	TagCasePreserve TagCase = iota
+
+	// TagCaseLower converts folder names to lower case.
+	TagCaseLower
+
+	// TagCaseUpper converts folder names to upper case.
+	TagCaseUpper
+)
+
+func (c TagCase) apply(s string) string {
+	switch c {
+	case TagCaseLower:
+		return strings.ToLower(s)
+	case TagCaseUpper:
+		return strings.ToUpper(s)
+	default:
+		return s
+	}
+}
+
+type flattenOptions struct {
+	pathTags     bool
+	tagSeparator string
+	tagCase      TagCase
+
+	pathAttribute string
+
+	keepEmptyFolders bool
+	deduplicate      bool
+}
+
+// A FlattenOption configures how a Document is flattened.
+type FlattenOption func(*flattenOptions)
+
+// WithPathTags records the Path of the Folder containing each Bookmark as
+// tags.
+//
+// If separator is empty, each folder name is added as a distinct tag;
+// otherwise, folder names are joined with separator to form a single tag, e.g.
+// "dev/go" for a "go" folder in a "dev" folder.
+//
+// As tags are separated by commas when encoded, commas in folder names and
+// separator are replaced with spaces, and consecutive whitespace is collapsed.
+// The tags of each Bookmark are then sorted and deduplicated.
+func WithPathTags(separator string) FlattenOption {
+	return func(o *flattenOptions) {
+		o.pathTags = true
+		o.tagSeparator = separator
+	}
+}
+
+// WithPathTagCase sets the case of the tags added by WithPathTags.
+//
+// Defaults to TagCasePreserve.
+func WithPathTagCase(tagCase TagCase) FlattenOption {
+	return func(o *flattenOptions) {
+		o.tagCase = tagCase
+	}
+}
+
+// WithPathAttribute records the Path of the Folder containing each Bookmark
+// in the given attribute, using the string representation of the Path.
+func WithPathAttribute(name string) FlattenOption {
+	return func(o *flattenOptions) {
+		o.pathAttribute = name
+	}
+}
+
+// WithEmptyFolders keeps the Folders that contain no Bookmarks nor Subfolders,
+// as Subfolders of the Root Folder named after their Path.
+func WithEmptyFolders() FlattenOption {
+	return func(o *flattenOptions) {
+		o.keepEmptyFolders = true
+	}
+}
+
+// WithDeduplication keeps a single Bookmark per URL: the first one found, in
+// document order, to which the tags of the other Bookmarks with the same URL
+// are added.
+//
+// When combined with WithPathTags, the tags of a deduplicated Bookmark record
+// all the folders where it was found; WithPathAttribute only records the
+// first one.
+func WithDeduplication() FlattenOption {
+	return func(o *flattenOptions) {
+		o.deduplicate = true
+	}
+}
+
+// FlattenWithOptions returns a flat version of this Document, with all
+// Bookmarks attached to the Root Folder, and separators removed.
+//
+// By default, the folders containing the Bookmarks are not recorded, and empty
+// folders are removed.
+func (d *Document) FlattenWithOptions(opts ...FlattenOption) *Document {
+	var o flattenOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	f := flattener{
+		opts: o,
+		root: d.Root.header(),
+		urls: make(map[string]int),
+	}
+
+	f.flatten(&d.Root, Path{})
+
+	return &Document{
+		Title: d.Title,
+		Root:  f.root,
+	}
+}
+
+// A flattener moves the Bookmarks of a Folder tree to a flat Root Folder.
+type flattener struct {
+	opts flattenOptions
+	root Folder
+
+	// index of the Bookmark for each URL, when deduplicating.
+	urls map[string]int
+}
+
+func (f *flattener) flatten(folder *Folder, path Path) {
+	for _, ref := range folder.orderedItems() {
+		switch ref.Kind {
+		case BookmarkKind:
+			f.addBookmark(folder.Bookmarks[ref.Index], path)
+		case FolderKind:
+			subfolder := &folder.Subfolders[ref.Index]
+			subpath := append(slices.Clip(path), subfolder.Name)
+
+			if f.opts.keepEmptyFolders && len(subfolder.Bookmarks) == 0 && len(subfolder.Subfolders) == 0 {
+				emptyFolder := subfolder.header()
+				emptyFolder.Name = FormatPath(subpath)
+
+				f.root.Subfolders = append(f.root.Subfolders, emptyFolder)
+				continue
+			}
+
+			f.flatten(subfolder, subpath)
+		}
+	}
+}
+
+func (f *flattener) addBookmark(bookmark Bookmark, path Path) {
+	var tags []string
+	if f.opts.pathTags && len(path) > 0 {
+		tags = f.pathTags(path)
+	}
+
+	if f.opts.deduplicate {
+		if index, ok := f.urls[bookmark.URL]; ok {
+			existing := &f.root.Bookmarks[index]
+			existing.Tags = mergeTags(existing.Tags, bookmark.Tags, tags)
+			return
+		}
+
+		f.urls[bookmark.URL] = len(f.root.Bookmarks)
+	}
+
+	if len(tags) > 0 || f.opts.deduplicate {
+		bookmark.Tags = mergeTags(nil, bookmark.Tags, tags)
+	}
+
+	if f.opts.pathAttribute != "" && len(path) > 0 {
+		bookmark.Attributes = maps.Clone(bookmark.Attributes)
+		bookmark.setAttribute(f.opts.pathAttribute, FormatPath(path), 1)
+	}
+
+	f.root.Bookmarks = append(f.root.Bookmarks, bookmark)
+}
+
+// pathTags returns the tags recording the given Path.
+func (f *flattener) pathTags(path Path) []string {
+	if f.opts.tagSeparator != "" {
+		tag := sanitizeTag(f.opts.tagCase.apply(strings.Join(path, f.opts.tagSeparator)))
+		if tag == "" {
+			return nil
+		}
+
+		return []string{tag}
+	}
+
+	tags := make([]string, 0, len(path))
+	for _, name := range path {
+		if tag := sanitizeTag(f.opts.tagCase.apply(name)); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
+
+// sanitizeTag replaces the commas separating encoded tags with spaces, and
+// collapses consecutive whitespace.
+func sanitizeTag(tag string) string {
+	return strings.Join(strings.Fields(strings.ReplaceAll(tag, ",", " ")), " ")
+}
+
+// mergeTags returns the sorted and deduplicated union of the given tags,
+// reusing the storage of dst.
+func mergeTags(dst []string, tags ...[]string) []string {
+	for _, t := range tags {
+		dst = append(dst, t...)
+	}
+
+	slices.Sort(dst)
+
+	return slices.Compact(dst)
+}
+
+// appendMissing appends the values that are not already contained in s.
+func appendMissing(s []string, values ...string) []string {
+	for _, value := range values {
+		if !slices.Contains(s, value) {
+			s = append(s, value)
+		}
+	}
+
+	return s
+}
@@ -0,0 +1,259 @@
+// Copyright (c) VirtualTam
+// SPDX-License-Identifier: MIT
+
+package netscape
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDocumentFlattenWithOptions(t *testing.T) {
+	document := Document{
+		Title: "Bookmarks",
+		Root: Folder{
+			Name: "Bookmarks",
+			Bookmarks: []Bookmark{
+				{URL: "https://root.domain.tld", Tags: []string{"root"}},
+			},
+			Subfolders: []Folder{
+				{
+					Name: "Dev",
+					Bookmarks: []Bookmark{
+						{URL: "https://go.dev", Tags: []string{"golang"}},
+					},
+					Subfolders: []Folder{
+						{
+							Name: "Go Tools",
+							Bookmarks: []Bookmark{
+								{
+									URL:        "https://pkg.go.dev",
+									Attributes: map[string]string{"ATTR": "value"},
+								},
+								{URL: "https://go.dev", Tags: []string{"tools"}},
+							},
+						},
+						{
+							Name: "Archive",
+							Subfolders: []Folder{
+								{Name: "2020"},
+							},
+						},
+					},
+				},
+				{Name: "Empty"},
+			},
+		},
+	}
+
+	cases := []struct {
+		tname string
+		opts  []FlattenOption
+		want  Folder
+	}{
+		{
+			tname: "no options",
+			want: Folder{
+				Name: "Bookmarks",
+				Bookmarks: []Bookmark{
+					{URL: "https://root.domain.tld", Tags: []string{"root"}},
+					{URL: "https://go.dev", Tags: []string{"golang"}},
+					{
+						URL:        "https://pkg.go.dev",
+						Attributes: map[string]string{"ATTR": "value"},
+					},
+					{URL: "https://go.dev", Tags: []string{"tools"}},
+				},
+			},
+		},
+		{
+			tname: "path tags",
+			opts:  []FlattenOption{WithPathTags("")},
+			want: Folder{
+				Name: "Bookmarks",
+				Bookmarks: []Bookmark{
+					{URL: "https://root.domain.tld", Tags: []string{"root"}},
+					{URL: "https://go.dev", Tags: []string{"Dev", "golang"}},
+					{
+						URL:        "https://pkg.go.dev",
+						Tags:       []string{"Dev", "Go Tools"},
+						Attributes: map[string]string{"ATTR": "value"},
+					},
+					{URL: "https://go.dev", Tags: []string{"Dev", "Go Tools", "tools"}},
+				},
+			},
+		},
+		{
+			tname: "path tags with separator and case",
+			opts:  []FlattenOption{WithPathTags("/"), WithPathTagCase(TagCaseLower)},
+			want: Folder{
+				Name: "Bookmarks",
+				Bookmarks: []Bookmark{
+					{URL: "https://root.domain.tld", Tags: []string{"root"}},
+					{URL: "https://go.dev", Tags: []string{"dev", "golang"}},
+					{
+						URL:        "https://pkg.go.dev",
+						Tags:       []string{"dev/go tools"},
+						Attributes: map[string]string{"ATTR": "value"},
+					},
+					{URL: "https://go.dev", Tags: []string{"dev/go tools", "tools"}},
+				},
+			},
+		},
+		{
+			tname: "path attribute",
+			opts:  []FlattenOption{WithPathAttribute("FOLDER")},
+			want: Folder{
+				Name: "Bookmarks",
+				Bookmarks: []Bookmark{
+					{URL: "https://root.domain.tld", Tags: []string{"root"}},
+					{
+						URL:        "https://go.dev",
+						Tags:       []string{"golang"},
+						Attributes: map[string]string{"FOLDER": "Dev"},
+					},
+					{
+						URL:        "https://pkg.go.dev",
+						Attributes: map[string]string{"ATTR": "value", "FOLDER": "Dev/Go Tools"},
+					},
+					{
+						URL:        "https://go.dev",
+						Tags:       []string{"tools"},
+						Attributes: map[string]string{"FOLDER": "Dev/Go Tools"},
+					},
+				},
+			},
+		},
+		{
+			tname: "empty folders",
+			opts:  []FlattenOption{WithEmptyFolders()},
+			want: Folder{
+				Name: "Bookmarks",
+				Bookmarks: []Bookmark{
+					{URL: "https://root.domain.tld", Tags: []string{"root"}},
+					{URL: "https://go.dev", Tags: []string{"golang"}},
+					{
+						URL:        "https://pkg.go.dev",
+						Attributes: map[string]string{"ATTR": "value"},
+					},
+					{URL: "https://go.dev", Tags: []string{"tools"}},
+				},
+				Subfolders: []Folder{
+					{Name: "Dev/Archive/2020"},
+					{Name: "Empty"},
+				},
+			},
+		},
+		{
+			tname: "deduplication with path tags and attribute",
+			opts: []FlattenOption{
+				WithDeduplication(),
+				WithPathTags(""),
+				WithPathAttribute("FOLDER"),
+			},
+			want: Folder{
+				Name: "Bookmarks",
+				Bookmarks: []Bookmark{
+					{URL: "https://root.domain.tld", Tags: []string{"root"}},
+					{
+						URL:        "https://go.dev",
+						Tags:       []string{"Dev", "Go Tools", "golang", "tools"},
+						Attributes: map[string]string{"FOLDER": "Dev"},
+					},
+					{
+						URL:        "https://pkg.go.dev",
+						Tags:       []string{"Dev", "Go Tools"},
+						Attributes: map[string]string{"ATTR": "value", "FOLDER": "Dev/Go Tools"},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.tname, func(t *testing.T) {
+			got := document.FlattenWithOptions(tc.opts...)
+
+			if got.Title != document.Title {
+				t.Errorf("want title %q, got %q", document.Title, got.Title)
+			}
+
+			assertFoldersEqual(t, got.Root, tc.want)
+		})
+	}
+
+	t.Run("source document is not modified", func(t *testing.T) {
+		_ = document.FlattenWithOptions(WithDeduplication(), WithPathTags(""), WithPathAttribute("FOLDER"))
+
+		bookmark := document.Root.Subfolders[0].Subfolders[0].Bookmarks[0]
+		if len(bookmark.Tags) != 0 || len(bookmark.Attributes) != 1 {
+			t.Errorf("want bookmark to be left unchanged, got tags %q and attributes %v", bookmark.Tags, bookmark.Attributes)
+		}
+
+		if tags := document.Root.Subfolders[0].Bookmarks[0].Tags; len(tags) != 1 {
+			t.Errorf("want bookmark to be left unchanged, got tags %q", tags)
+		}
+	})
+}
+
+func TestDocumentFlattenWithPathTagsSeparators(t *testing.T) {
+	document := Document{
+		Title: "Bookmarks",
+		Root: Folder{
+			Name: "Bookmarks",
+			Subfolders: []Folder{
+				{
+					Name: "Dev, Ops",
+					Subfolders: []Folder{
+						{
+							Name: "Go,Tools",
+							Bookmarks: []Bookmark{
+								{URL: "https://go.dev", Tags: []string{"zeta", "Dev Ops"}},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	cases := []struct {
+		tname     string
+		separator string
+		want      []string
+	}{
+		{
+			tname: "distinct tags",
+			want:  []string{"Dev Ops", "Go Tools", "zeta"},
+		},
+		{
+			tname:     "comma separator",
+			separator: ",",
+			want:      []string{"Dev Ops", "Dev Ops Go Tools", "zeta"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.tname, func(t *testing.T) {
+			flat := document.FlattenWithOptions(WithPathTags(tc.separator))
+
+			if got := flat.Root.Bookmarks[0].Tags; !slices.Equal(got, tc.want) {
+				t.Errorf("want tags %q, got %q", tc.want, got)
+			}
+
+			data, err := Marshal(flat)
+			if err != nil {
+				t.Fatalf("expected no error, got %q", err)
+			}
+
+			decoded, err := Unmarshal(data)
+			if err != nil {
+				t.Fatalf("expected no error, got %q", err)
+			}
+
+			if got := decoded.Root.Bookmarks[0].Tags; !slices.Equal(got, tc.want) {
+				t.Errorf("want decoded tags %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
@@ -4,4 +4,9 @@ go 1.25
 
 tool golang.org/x/tools/cmd/file2fuzz
 
-require golang.org/x/tools v0.39.0 // indirect
+require golang.org/x/net v0.47.0
+
+require (
+	golang.org/x/text v0.31.0 // indirect
+	golang.org/x/tools v0.39.0 // indirect
+)
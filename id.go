@@ -0,0 +1,206 @@
+// Copyright (c) VirtualTam
+// SPDX-License-Identifier: MIT
+
+package netscape
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"time"
+)
+
+var (
+	ErrBookmarkNotFound = errors.New("bookmark not found")
+)
+
+// idAttribute is the attribute holding the identifier of an item, as exported
+// by some Web browsers and bookmarking services.
+const idAttribute = "ID"
+
+// ID returns the identifier of this Bookmark: the value of its ID attribute
+// if set, or its ContentID.
+func (b *Bookmark) ID() string {
+	if id := b.Attributes[idAttribute]; id != "" {
+		return id
+	}
+
+	return b.ContentID()
+}
+
+// ContentID returns an identifier derived from the normalized URL and the
+// creation date of this Bookmark.
+//
+// The identifier is deterministic, so that the same Bookmark has the same
+// identifier in successive exports of a Web browser, but changes with its URL
+// or creation date. Bookmarks with the same normalized URL and no creation
+// date have the same identifier.
+func (b *Bookmark) ContentID() string {
+	return contentID("bookmark", b.NormalizedURL(), b.CreatedAt)
+}
+
+// ID returns the identifier of this Folder: the value of its ID attribute if
+// set, or its ContentID.
+func (f *Folder) ID() string {
+	if id := f.Attributes[idAttribute]; id != "" {
+		return id
+	}
+
+	return f.ContentID()
+}
+
+// ContentID returns an identifier derived from the creation date of this
+// Folder, or from its name if it has no creation date.
+//
+// The identifier is deterministic, and does not change when the Folder is
+// moved, nor when it is renamed if it has a creation date. Folders created at
+// the same time, or with the same name and no creation date, have the same
+// identifier.
+func (f *Folder) ContentID() string {
+	if f.CreatedAt.IsZero() {
+		return contentID("folder", f.Name, time.Time{})
+	}
+
+	return contentID("folder", "", f.CreatedAt)
+}
+
+// contentID returns the identifier of an item of the given kind, from its key
+// and creation date.
+func contentID(kind, key string, createdAt time.Time) string {
+	h := sha256.New()
+
+	h.Write([]byte(kind))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	h.Write([]byte{0})
+	h.Write([]byte(formatChangeTime(createdAt)))
+
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// FindBookmarkByID returns the first Bookmark with the given ID, in document
+// order, along with the Path of its Folder, or ErrBookmarkNotFound.
+func (d *Document) FindBookmarkByID(id string) (Path, *Bookmark, error) {
+	folder, path, index := d.Root.findBookmarkByID(Path{}, id, nil)
+	if folder == nil {
+		return nil, nil, ErrBookmarkNotFound
+	}
+
+	return path, &folder.Bookmarks[index], nil
+}
+
+// FindFolderByID returns the first Folder with the given ID, in document
+// order, along with its Path, or ErrFolderNotFound.
+func (d *Document) FindFolderByID(id string) (Path, *Folder, error) {
+	folder, path := d.Root.findFolderByID(Path{}, id, nil)
+	if folder == nil {
+		return nil, nil, ErrFolderNotFound
+	}
+
+	return path, folder, nil
+}
+
+// findBookmarkByID returns the Folder containing the first Bookmark with the
+// given ID, along with its Path and the index of the Bookmark, or nil.
+func (f *Folder) findBookmarkByID(path Path, id string, ids *idCache) (*Folder, Path, int) {
+	for _, ref := range f.orderedItems() {
+		switch ref.Kind {
+		case BookmarkKind:
+			if ids.bookmarkID(&f.Bookmarks[ref.Index]) == id {
+				return f, path, ref.Index
+			}
+		case FolderKind:
+			subfolder := &f.Subfolders[ref.Index]
+			if folder, subpath, index := subfolder.findBookmarkByID(append(slices.Clip(path), subfolder.Name), id, ids); folder != nil {
+				return folder, subpath, index
+			}
+		}
+	}
+
+	return nil, nil, -1
+}
+
+// findFolderByID returns the first Folder with the given ID, starting with
+// this Folder located at the given Path, along with its Path, or nil.
+func (f *Folder) findFolderByID(path Path, id string, ids *idCache) (*Folder, Path) {
+	if ids.folderID(f) == id {
+		return f, path
+	}
+
+	for _, ref := range f.orderedItems() {
+		if ref.Kind != FolderKind {
+			continue
+		}
+
+		subfolder := &f.Subfolders[ref.Index]
+		if folder, subpath := subfolder.findFolderByID(append(slices.Clip(path), subfolder.Name), id, ids); folder != nil {
+			return folder, subpath
+		}
+	}
+
+	return nil, nil
+}
+
+// An idCache memoizes the content IDs of items, so that looking up items by
+// ID does not normalize and hash every URL again. A nil idCache computes IDs
+// without memoizing them.
+type idCache struct {
+	bookmarks map[idCacheKey]string
+	folders   map[idCacheKey]string
+}
+
+// An idCacheKey holds the values from which a content ID is derived.
+type idCacheKey struct {
+	key       string
+	createdAt string
+}
+
+func newIDCache() *idCache {
+	return &idCache{
+		bookmarks: make(map[idCacheKey]string),
+		folders:   make(map[idCacheKey]string),
+	}
+}
+
+// bookmarkID returns the ID of bookmark.
+func (c *idCache) bookmarkID(bookmark *Bookmark) string {
+	if c == nil {
+		return bookmark.ID()
+	}
+
+	if id := bookmark.Attributes[idAttribute]; id != "" {
+		return id
+	}
+
+	k := idCacheKey{key: bookmark.URL, createdAt: formatChangeTime(bookmark.CreatedAt)}
+	if id, ok := c.bookmarks[k]; ok {
+		return id
+	}
+
+	id := bookmark.ContentID()
+	c.bookmarks[k] = id
+
+	return id
+}
+
+// folderID returns the ID of folder.
+func (c *idCache) folderID(folder *Folder) string {
+	if c == nil {
+		return folder.ID()
+	}
+
+	if id := folder.Attributes[idAttribute]; id != "" {
+		return id
+	}
+
+	k := idCacheKey{key: folder.Name, createdAt: formatChangeTime(folder.CreatedAt)}
+	if id, ok := c.folders[k]; ok {
+		return id
+	}
+
+	id := folder.ContentID()
+	c.folders[k] = id
+
+	return id
+}
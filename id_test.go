@@ -0,0 +1,131 @@
+// Copyright (c) VirtualTam
+// SPDX-License-Identifier: MIT
+
+package netscape
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestBookmarkID(t *testing.T) {
+	createdAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	bookmark := Bookmark{CreatedAt: createdAt, Title: "Go", URL: "https://go.dev"}
+
+	id := bookmark.ID()
+	if len(id) != 16 {
+		t.Errorf("want 16 hexadecimal digits, got %q", id)
+	}
+
+	same := Bookmark{CreatedAt: createdAt.In(time.FixedZone("UTC+2", 7200)), Title: "The Go Programming Language", URL: "HTTPS://GO.dev/"}
+	if got := same.ID(); got != id {
+		t.Errorf("want the same ID %q for the same normalized URL and creation date, got %q", id, got)
+	}
+
+	for _, other := range []Bookmark{
+		{Title: "Go", URL: "https://go.dev"},
+		{CreatedAt: createdAt, Title: "Go", URL: "https://pkg.go.dev"},
+	} {
+		if got := other.ID(); got == id {
+			t.Errorf("want an ID other than %q for %s created at %s", id, other.URL, other.CreatedAt)
+		}
+	}
+
+	bookmark.Attributes = map[string]string{"ID": "42"}
+
+	if got := bookmark.ID(); got != "42" {
+		t.Errorf("want ID %q, got %q", "42", got)
+	}
+
+	if got := bookmark.ContentID(); got != id {
+		t.Errorf("want content ID %q, got %q", id, got)
+	}
+}
+
+func TestFolderID(t *testing.T) {
+	createdAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	folder := Folder{Name: "Tools"}
+
+	id := folder.ID()
+	if len(id) != 16 {
+		t.Errorf("want 16 hexadecimal digits, got %q", id)
+	}
+
+	if got := (&Folder{Name: "Linters"}).ID(); got == id {
+		t.Errorf("want an ID other than %q for another name", id)
+	}
+
+	bookmark := Bookmark{URL: "Tools"}
+	if got := bookmark.ID(); got == id {
+		t.Errorf("want bookmark and folder IDs to differ, got %q", got)
+	}
+
+	// the ID of a Folder with a creation date does not change on rename
+	dated := Folder{CreatedAt: createdAt, Name: "Tools"}
+	renamed := Folder{CreatedAt: createdAt.In(time.FixedZone("UTC+2", 7200)), Name: "Linters"}
+
+	if got, want := renamed.ID(), dated.ID(); got != want {
+		t.Errorf("want the same ID %q for the same creation date, got %q", want, got)
+	}
+
+	if dated.ID() == id {
+		t.Errorf("want an ID other than %q for a Folder with a creation date", id)
+	}
+
+	folder.Attributes = map[string]string{"ID": "toolbar"}
+
+	if got := folder.ID(); got != "toolbar" {
+		t.Errorf("want ID %q, got %q", "toolbar", got)
+	}
+
+	if got := folder.ContentID(); got != id {
+		t.Errorf("want content ID %q, got %q", id, got)
+	}
+}
+
+func TestDocumentFindByID(t *testing.T) {
+	document := loadTestDocument(t, "diff_old.htm")
+
+	staticcheck := Bookmark{Title: "Staticcheck", URL: "https://staticcheck.dev"}
+
+	path, bookmark, err := document.FindBookmarkByID(staticcheck.ID())
+	if err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+
+	if !slices.Equal(path, Path{"Dev", "Tools"}) || bookmark.Title != staticcheck.Title {
+		t.Errorf("want bookmark %q in %q, got %q in %q", staticcheck.Title, "Dev/Tools", bookmark.Title, path)
+	}
+
+	if _, _, err := document.FindBookmarkByID("unknown"); !errors.Is(err, ErrBookmarkNotFound) {
+		t.Errorf("want error %q, got %q", ErrBookmarkNotFound, err)
+	}
+
+	tools := Folder{Name: "Tools"}
+
+	path, folder, err := document.FindFolderByID(tools.ID())
+	if err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+
+	if !slices.Equal(path, Path{"Dev", "Tools"}) || folder.Name != "Tools" {
+		t.Errorf("want folder %q, got %q at %q", "Dev/Tools", folder.Name, path)
+	}
+
+	path, folder, err = document.FindFolderByID(document.Root.ID())
+	if err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+
+	if len(path) != 0 || folder != &document.Root {
+		t.Errorf("want root folder, got %q at %q", folder.Name, path)
+	}
+
+	if _, _, err := document.FindFolderByID("unknown"); !errors.Is(err, ErrFolderNotFound) {
+		t.Errorf("want error %q, got %q", ErrFolderNotFound, err)
+	}
+}
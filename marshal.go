@@ -15,9 +15,15 @@ import (
 
 // Marshal returns the Netscape Bookmark encoding of d.
 func Marshal(d *Document) ([]byte, error) {
+	return MarshalWithOptions(d)
+}
+
+// MarshalWithOptions returns the Netscape Bookmark encoding of d, using an
+// Encoder configured with opts.
+func MarshalWithOptions(d *Document, opts ...EncoderOption) ([]byte, error) {
 	var buf bytes.Buffer
 
-	if err := NewEncoder(&buf).Encode(d); err != nil {
+	if err := NewEncoder(&buf, opts...).Encode(d); err != nil {
 		return []byte{}, err
 	}
 
@@ -31,6 +37,28 @@ func Unmarshal(b []byte) (*Document, error) {
 	return unmarshal(r)
 }
 
+// UnmarshalWithOptions unmarshals a []byte representation of a Netscape
+// Bookmark file using a Decoder configured with opts, and returns the
+// corresponding Document.
+//
+// If opts include WithMode(Lenient), the input is also parsed in Lenient mode;
+// use UnmarshalLenient to retrieve the corresponding Diagnostics.
+func UnmarshalWithOptions(b []byte, opts ...DecoderOption) (*Document, error) {
+	document, _, err := unmarshalWithOptions(bytes.NewReader(b), opts...)
+	return document, err
+}
+
+// UnmarshalLenient unmarshals a []byte representation of a Netscape Bookmark
+// file in Lenient mode, and returns the corresponding best-effort Document,
+// along with Diagnostics describing the problems found in the input.
+//
+// The Decoder is configured with opts, followed by WithMode(Lenient).
+func UnmarshalLenient(b []byte, opts ...DecoderOption) (*Document, []Diagnostic, error) {
+	opts = append(opts[:len(opts):len(opts)], WithMode(Lenient))
+
+	return unmarshalWithOptions(bytes.NewReader(b), opts...)
+}
+
 // UnmarshalFile unmarshals a Netscape Bookmark file and returns the
 // corresponding Document.
 func UnmarshalFile(filePath string) (d *Document, err error) {
@@ -47,6 +75,12 @@ func UnmarshalFile(filePath string) (d *Document, err error) {
 	return unmarshal(file)
 }
 
+// UnmarshalReader unmarshals a Netscape Bookmark file read from r and returns
+// the corresponding Document.
+func UnmarshalReader(r io.Reader) (*Document, error) {
+	return unmarshal(r)
+}
+
 // UnmarshalString unmarshals a string representation of a Netscape Bookmark
 // file and returns the corresponding Document.
 func UnmarshalString(data string) (*Document, error) {
@@ -54,11 +88,27 @@ func UnmarshalString(data string) (*Document, error) {
 	return unmarshal(r)
 }
 
-func unmarshal(r io.ReadSeeker) (*Document, error) {
-	astFile, err := Parse(r)
+func unmarshal(r io.Reader) (*Document, error) {
+	astFile, err := ParseReader(r)
 	if err != nil {
 		return &Document{}, err
 	}
 
 	return Decode(*astFile)
 }
+
+func unmarshalWithOptions(r io.Reader, opts ...DecoderOption) (*Document, []Diagnostic, error) {
+	decoder := NewDecoder(opts...)
+
+	astFile, diagnostics, err := ParseWithOptions(r, ParseOptions{Mode: decoder.mode})
+	if err != nil {
+		return &Document{}, diagnostics, err
+	}
+
+	document, err := decoder.Decode(*astFile)
+	if err != nil {
+		return &Document{}, diagnostics, err
+	}
+
+	return document, append(diagnostics, decoder.Diagnostics()...), nil
+}
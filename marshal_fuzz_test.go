@@ -11,6 +11,16 @@ import (
 
 func Fuzz(f *testing.F) {
 	f.Fuzz(func(t *testing.T, input []byte) {
+		lenientDocument, _, err := netscape.UnmarshalLenient(input)
+		if err != nil {
+			t.Errorf("lenient: %s", err)
+		}
+
+		_, err = netscape.Marshal(lenientDocument)
+		if err != nil {
+			t.Errorf("lenient: %s", err)
+		}
+
 		document, err := netscape.Unmarshal(input)
 		if err != nil {
 			return
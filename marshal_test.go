@@ -4,6 +4,13 @@
 package netscape
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 )
@@ -90,6 +97,37 @@ delimiter
 `,
 		},
 
+		{
+			tname: "document with special characters",
+			document: Document{
+				Title: "R&D <internal>",
+				Root: Folder{
+					Name:        "R&D <internal>",
+					Description: "Shared & reviewed",
+					Subfolders: []Folder{
+						{
+							Name:        "Tom & Jerry's",
+							Description: "<cartoons>",
+						},
+					},
+				},
+			},
+			want: `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<!-- This is an automatically generated file.
+     It will be read and overwritten.
+     DO NOT EDIT! -->
+<TITLE>R&amp;D &lt;internal&gt;</TITLE>
+<H1>R&amp;D &lt;internal&gt;</H1>
+<DD>Shared &amp; reviewed
+<DL><p>
+    <DT><H3>Tom &amp; Jerry&#39;s</H3>
+    <DD>&lt;cartoons&gt;
+    <DL><p>
+    </DL><p>
+</DL><p>
+`,
+		},
+
 		{
 			tname: "document with private bookmarks and dates",
 			document: Document{
@@ -137,6 +175,168 @@ delimiter
         <DD>Second test
     </DL><p>
 </DL><p>
+`,
+		},
+
+		{
+			tname: "document with interleaved bookmarks and subfolders",
+			document: Document{
+				Title: "Bookmarks",
+				Root: Folder{
+					Name: "Bookmarks",
+					Bookmarks: []Bookmark{
+						{
+							URL:   "https://domain.tld",
+							Title: "Test Domain",
+						},
+						{
+							URL:   "https://test.domain.tld",
+							Title: "Test Domain II",
+						},
+					},
+					Subfolders: []Folder{
+						{
+							Name: "Favorites",
+						},
+					},
+					Order: []ItemRef{
+						{Kind: BookmarkKind, Index: 0},
+						{Kind: FolderKind, Index: 0},
+						{Kind: BookmarkKind, Index: 1},
+					},
+				},
+			},
+			want: `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<!-- This is an automatically generated file.
+     It will be read and overwritten.
+     DO NOT EDIT! -->
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DL><p>
+    <DT><A HREF="https://domain.tld" PRIVATE="0">Test Domain</A>
+    <DT><H3>Favorites</H3>
+    <DL><p>
+    </DL><p>
+    <DT><A HREF="https://test.domain.tld" PRIVATE="0">Test Domain II</A>
+</DL><p>
+`,
+		},
+
+		{
+			tname: "document with browser attributes",
+			document: Document{
+				Title: "Bookmarks",
+				Root: Folder{
+					Name: "Bookmarks",
+					Bookmarks: []Bookmark{
+						{
+							URL:           "https://search.domain.tld/",
+							Title:         "Test Search",
+							LastVisitedAt: time.Date(2022, time.March, 1, 17, 11, 13, 0, time.UTC),
+							Icon: Icon{
+								MediaType: "image/png",
+								Data:      []byte("\x89PNG\r\n\x1a\n"),
+							},
+							IconURI:  "https://search.domain.tld/favicon.png",
+							Keyword:  "search",
+							Charset:  "UTF-8",
+							PostData: "q=%s",
+						},
+					},
+				},
+			},
+			want: `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<!-- This is an automatically generated file.
+     It will be read and overwritten.
+     DO NOT EDIT! -->
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DL><p>
+    <DT><A HREF="https://search.domain.tld/" LAST_VISIT="1646154673" PRIVATE="0" ICON_URI="https://search.domain.tld/favicon.png" ICON="data:image/png;base64,iVBORw0KGgo=" SHORTCUTURL="search" POST_DATA="q=%s" LAST_CHARSET="UTF-8">Test Search</A>
+</DL><p>
+`,
+		},
+
+		{
+			tname: "document with special folders",
+			document: Document{
+				Title: "Signets",
+				Root: Folder{
+					Name: "Signets",
+					Subfolders: []Folder{
+						{
+							Name:   "Favoris",
+							Role:   FolderRoleToolbar,
+							Folded: true,
+						},
+						{
+							Name: "Menu Signets",
+							Role: FolderRoleMenu,
+						},
+						{
+							Name: "Autres favoris",
+							Role: FolderRoleUnfiled,
+						},
+					},
+				},
+			},
+			want: `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<!-- This is an automatically generated file.
+     It will be read and overwritten.
+     DO NOT EDIT! -->
+<TITLE>Signets</TITLE>
+<H1>Signets</H1>
+<DL><p>
+    <DT><H3 PERSONAL_TOOLBAR_FOLDER="true" FOLDED="FOLDED">Favoris</H3>
+    <DL><p>
+    </DL><p>
+    <DT><H3>Menu Signets</H3>
+    <DL><p>
+    </DL><p>
+    <DT><H3 UNFILED_BOOKMARKS_FOLDER="true">Autres favoris</H3>
+    <DL><p>
+    </DL><p>
+</DL><p>
+`,
+		},
+
+		{
+			tname: "document with separators",
+			document: Document{
+				Title: "Bookmarks",
+				Root: Folder{
+					Name: "Bookmarks",
+					Bookmarks: []Bookmark{
+						{
+							URL:   "https://domain.tld",
+							Title: "Test Domain",
+						},
+						{
+							URL:   "https://test.domain.tld",
+							Title: "Test Domain II",
+						},
+					},
+					Separators: []Separator{
+						{},
+					},
+					Order: []ItemRef{
+						{Kind: BookmarkKind, Index: 0},
+						{Kind: SeparatorKind, Index: 0},
+						{Kind: BookmarkKind, Index: 1},
+					},
+				},
+			},
+			want: `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<!-- This is an automatically generated file.
+     It will be read and overwritten.
+     DO NOT EDIT! -->
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DL><p>
+    <DT><A HREF="https://domain.tld" PRIVATE="0">Test Domain</A>
+    <HR>
+    <DT><A HREF="https://test.domain.tld" PRIVATE="0">Test Domain II</A>
+</DL><p>
 `,
 		},
 	}
@@ -157,3 +357,606 @@ delimiter
 		})
 	}
 }
+
+func TestMarshalWithOptions(t *testing.T) {
+	document := Document{
+		Title: "Bookmarks",
+		Root: Folder{
+			Name: "Bookmarks",
+			Subfolders: []Folder{
+				{
+					Name:      "Favorites",
+					CreatedAt: time.Date(2021, time.June, 1, 17, 11, 13, 0, time.UTC),
+					Bookmarks: []Bookmark{
+						{
+							CreatedAt:   time.Date(2022, time.January, 1, 17, 11, 13, 123456000, time.UTC),
+							UpdatedAt:   time.Date(2022, time.January, 1, 17, 11, 13, 123456000, time.UTC),
+							URL:         "https://domain.tld",
+							Title:       "Test Domain",
+							Description: "Multi-line\ndescription",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	cases := []struct {
+		tname string
+		opts  []EncoderOption
+		want  string
+	}{
+		{
+			tname: "default options",
+			want: `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<!-- This is an automatically generated file.
+     It will be read and overwritten.
+     DO NOT EDIT! -->
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DL><p>
+    <DT><H3 ADD_DATE="1622567473">Favorites</H3>
+    <DL><p>
+        <DT><A HREF="https://domain.tld" ADD_DATE="1641057073" LAST_MODIFIED="1641057073" PRIVATE="0">Test Domain</A>
+        <DD>Multi-line
+description
+    </DL><p>
+</DL><p>
+`,
+		},
+		{
+			tname: "indent, custom header and charset",
+			opts: []EncoderOption{
+				WithIndent("\t"),
+				WithHeaderComment("Exported bookmarks"),
+				WithCharsetMeta(),
+			},
+			want: `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<!-- Exported bookmarks -->
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DL><p>
+	<DT><H3 ADD_DATE="1622567473">Favorites</H3>
+	<DL><p>
+		<DT><A HREF="https://domain.tld" ADD_DATE="1641057073" LAST_MODIFIED="1641057073" PRIVATE="0">Test Domain</A>
+		<DD>Multi-line
+description
+	</DL><p>
+</DL><p>
+`,
+		},
+		{
+			tname: "no header, default values and microsecond timestamps",
+			opts: []EncoderOption{
+				WithHeaderComment(""),
+				WithOmitDefaults(),
+				WithTimestampPrecision(time.Microsecond),
+			},
+			want: `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DL><p>
+    <DT><H3 ADD_DATE="1622567473000000">Favorites</H3>
+    <DL><p>
+        <DT><A HREF="https://domain.tld" ADD_DATE="1641057073123456">Test Domain</A>
+        <DD>Multi-line
+description
+    </DL><p>
+</DL><p>
+`,
+		},
+		{
+			tname: "header comment ending the comment early",
+			opts: []EncoderOption{
+				WithHeaderComment("Exported bookmarks --><SCRIPT>---"),
+				WithOmitDefaults(),
+			},
+			want: `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<!-- Exported bookmarks - -><SCRIPT>- - - -->
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DL><p>
+    <DT><H3 ADD_DATE="1622567473">Favorites</H3>
+    <DL><p>
+        <DT><A HREF="https://domain.tld" ADD_DATE="1641057073">Test Domain</A>
+        <DD>Multi-line
+description
+    </DL><p>
+</DL><p>
+`,
+		},
+		{
+			tname: "CRLF line endings",
+			opts: []EncoderOption{
+				WithHeaderComment(""),
+				WithCRLF(),
+			},
+			want: "<!DOCTYPE NETSCAPE-Bookmark-file-1>\r\n" +
+				"<TITLE>Bookmarks</TITLE>\r\n" +
+				"<H1>Bookmarks</H1>\r\n" +
+				"<DL><p>\r\n" +
+				"    <DT><H3 ADD_DATE=\"1622567473\">Favorites</H3>\r\n" +
+				"    <DL><p>\r\n" +
+				"        <DT><A HREF=\"https://domain.tld\" ADD_DATE=\"1641057073\" LAST_MODIFIED=\"1641057073\" PRIVATE=\"0\">Test Domain</A>\r\n" +
+				"        <DD>Multi-line\r\n" +
+				"description\r\n" +
+				"    </DL><p>\r\n" +
+				"</DL><p>\r\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.tname, func(t *testing.T) {
+			m, err := MarshalWithOptions(&document, tc.opts...)
+
+			if err != nil {
+				t.Fatalf("expected no error, got %q", err)
+			}
+
+			got := string(m)
+
+			if got != tc.want {
+				t.Errorf("\nwant:\n%q\n\ngot:\n%q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestMarshalRoundtrip(t *testing.T) {
+	inputFilenames := []string{
+		"firefox_separators.htm",
+		"netscape_basic.htm",
+		"netscape_extended.htm",
+		"netscape_multiline.htm",
+		"netscape_nested.htm",
+		"safari_folded.htm",
+	}
+
+	for _, inputFilename := range inputFilenames {
+		t.Run(inputFilename, func(t *testing.T) {
+			want, err := UnmarshalFile(filepath.Join("testdata", "input", inputFilename))
+			if err != nil {
+				t.Fatalf("failed to unmarshal input file %s: %s", inputFilename, err)
+			}
+
+			m, err := Marshal(want)
+			if err != nil {
+				t.Fatalf("expected no error, got %q", err)
+			}
+
+			got, err := Unmarshal(m)
+			if err != nil {
+				t.Fatalf("failed to unmarshal marshaled data: %s", err)
+			}
+
+			if got.Title != want.Title {
+				t.Errorf("want title %q, got %q", want.Title, got.Title)
+			}
+
+			assertFoldersEqual(t, got.Root, want.Root)
+		})
+	}
+}
+
+func TestMarshalRoundtripFolderRoles(t *testing.T) {
+	input := `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<!-- This is an automatically generated file.
+     It will be read and overwritten.
+     DO NOT EDIT! -->
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks Menu</H1>
+<DL><p>
+    <DT><H3>Favorites</H3>
+    <DL><p>
+        <DT><A HREF="https://domain.tld" PRIVATE="0">Test Domain</A>
+    </DL><p>
+    <DT><H3>Favoris</H3>
+    <DL><p>
+    </DL><p>
+    <DT><H3 UNFILED_BOOKMARKS_FOLDER="true">Other Bookmarks</H3>
+    <DL><p>
+    </DL><p>
+</DL><p>
+`
+
+	document, err := Unmarshal([]byte(input))
+	if err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+
+	m, err := Marshal(document)
+	if err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+
+	got := string(m)
+
+	if got != input {
+		t.Errorf("\nwant:\n%s\n\ngot:\n%s", input, got)
+	}
+}
+
+func TestUnmarshalWithOptions(t *testing.T) {
+	input := `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<TITLE>Pinboard Bookmarks</TITLE>
+<H1>Pinboard Bookmarks</H1>
+<DL><p>
+<DT><A HREF="https://go.dev/" ADD_DATE="2022-03-01 17:11:13" PRIVATE="0" TAGS="golang programming">The Go Programming Language</A>
+</DL><p>
+`
+
+	want := Folder{
+		Name: "Pinboard Bookmarks",
+		Bookmarks: []Bookmark{
+			{
+				CreatedAt: time.Date(2022, time.March, 1, 17, 11, 13, 0, time.UTC),
+				UpdatedAt: time.Date(2022, time.March, 1, 17, 11, 13, 0, time.UTC),
+				Title:     "The Go Programming Language",
+				URL:       "https://go.dev/",
+				Tags:      []string{"golang", "programming"},
+			},
+		},
+	}
+
+	t.Run("default options", func(t *testing.T) {
+		_, err := UnmarshalWithOptions([]byte(input))
+
+		if !errors.Is(err, ErrDateInvalid) {
+			t.Errorf("want error %q, got %q", ErrDateInvalid, err)
+		}
+	})
+
+	t.Run("custom options", func(t *testing.T) {
+		got, err := UnmarshalWithOptions(
+			[]byte(input),
+			WithDateLayouts("2006-01-02 15:04:05"),
+			WithTagSeparator(" "),
+		)
+
+		if err != nil {
+			t.Fatalf("expected no error, got %q", err)
+		}
+
+		assertFoldersEqual(t, got.Root, want)
+	})
+
+	t.Run("lenient", func(t *testing.T) {
+		got, diagnostics, err := UnmarshalLenient(
+			[]byte(input),
+			WithTagSeparator(" "),
+		)
+
+		if err != nil {
+			t.Fatalf("expected no error, got %q", err)
+		}
+
+		if len(diagnostics) != 1 {
+			t.Fatalf("want 1 diagnostic, got %d: %v", len(diagnostics), diagnostics)
+		}
+
+		if !errors.Is(diagnostics[0].Err, ErrDateInvalid) {
+			t.Errorf("want error %q, got %q", ErrDateInvalid, diagnostics[0].Err)
+		}
+
+		if !slices.Equal(got.Root.Bookmarks[0].Tags, want.Bookmarks[0].Tags) {
+			t.Errorf("want tags %q, got %q", want.Bookmarks[0].Tags, got.Root.Bookmarks[0].Tags)
+		}
+	})
+}
+
+// hostileFragments are combined to generate text that must be escaped when
+// marshaling documents.
+var hostileFragments = []string{
+	"R&D", "<internal>", "&amp;", "&nbsp;", "&#34;", `"quoted"`, "'single'",
+	"</DL><p>", "<DT>", "<DD>", "<H3>", "</A>", "<!--", "-->", "]]>",
+	"Fran & Freddie's", "é", "日本語", "🔖", "\t", "\n", "\r\n", " ",
+}
+
+func generateText(r *rand.Rand, trimmed bool) string {
+	var b strings.Builder
+
+	for range r.IntN(5) + 1 {
+		b.WriteString(hostileFragments[r.IntN(len(hostileFragments))])
+	}
+
+	if trimmed {
+		// the parser strips leading and trailing whitespace from descriptions
+		return strings.TrimSpace(b.String())
+	}
+
+	return b.String()
+}
+
+func generateDate(r *rand.Rand) time.Time {
+	return time.Unix(r.Int64N(2_000_000_000), 0).UTC()
+}
+
+func generateBookmark(r *rand.Rand) Bookmark {
+	bookmark := Bookmark{
+		Title:       generateText(r, false),
+		URL:         "https://domain.tld/?q=" + generateText(r, false),
+		Description: generateText(r, true),
+		Private:     r.IntN(2) == 0,
+		Keyword:     generateText(r, false),
+		Attributes: map[string]string{
+			"NOTE": generateText(r, false),
+		},
+	}
+
+	if r.IntN(2) == 0 {
+		bookmark.CreatedAt = generateDate(r)
+		bookmark.UpdatedAt = generateDate(r)
+	}
+
+	for range r.IntN(3) {
+		tag := strings.TrimSpace(strings.ReplaceAll(generateText(r, false), ",", ""))
+		if tag != "" {
+			bookmark.Tags = append(bookmark.Tags, tag)
+		}
+	}
+	slices.Sort(bookmark.Tags)
+
+	return bookmark
+}
+
+func generateFolder(r *rand.Rand, depth int) Folder {
+	folder := Folder{
+		Name:        "Folder " + generateText(r, false),
+		Description: generateText(r, true),
+	}
+
+	var kinds []ItemKind
+
+	for range r.IntN(4) {
+		folder.Bookmarks = append(folder.Bookmarks, generateBookmark(r))
+		kinds = append(kinds, BookmarkKind)
+	}
+
+	for range r.IntN(2) {
+		folder.Separators = append(folder.Separators, Separator{})
+		kinds = append(kinds, SeparatorKind)
+	}
+
+	if depth < 3 {
+		for range r.IntN(3) {
+			folder.Subfolders = append(folder.Subfolders, generateFolder(r, depth+1))
+			kinds = append(kinds, FolderKind)
+		}
+	}
+
+	// items are indexed in the order they appear in the document
+	r.Shuffle(len(kinds), func(i, j int) {
+		kinds[i], kinds[j] = kinds[j], kinds[i]
+	})
+
+	var counts [itemKindCount]int
+	for _, kind := range kinds {
+		folder.Order = append(folder.Order, ItemRef{Kind: kind, Index: counts[kind]})
+		counts[kind]++
+	}
+
+	return folder
+}
+
+func TestMarshalRoundtripProperty(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+
+	for i := range 200 {
+		want := &Document{
+			Title: generateText(r, false),
+			Root:  generateFolder(r, 0),
+		}
+
+		m, err := Marshal(want)
+		if err != nil {
+			t.Fatalf("document %d: expected no error, got %q", i, err)
+		}
+
+		got, err := Unmarshal(m)
+		if err != nil {
+			t.Fatalf("document %d: failed to unmarshal marshaled data: %s\n%s", i, err, m)
+		}
+
+		if got.Title != want.Title {
+			t.Errorf("document %d: want title %q, got %q", i, want.Title, got.Title)
+		}
+
+		assertFoldersEqual(t, got.Root, want.Root)
+
+		if t.Failed() {
+			t.Fatalf("document %d:\n%s", i, m)
+		}
+	}
+}
+
+// streamFolder writes f and its items using the streaming Encoder API.
+func streamFolder(e *Encoder, f *Folder) error {
+	if err := e.StartFolder(f); err != nil {
+		return err
+	}
+
+	for _, ref := range f.orderedItems() {
+		var err error
+
+		switch ref.Kind {
+		case BookmarkKind:
+			err = e.WriteBookmark(&f.Bookmarks[ref.Index])
+		case FolderKind:
+			err = streamFolder(e, &f.Subfolders[ref.Index])
+		case SeparatorKind:
+			err = e.WriteSeparator(&f.Separators[ref.Index])
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return e.EndFolder()
+}
+
+func TestEncoderStream(t *testing.T) {
+	inputFilenames := []string{
+		"firefox_separators.htm",
+		"netscape_extended.htm",
+		"netscape_nested.htm",
+		"safari_folded.htm",
+	}
+
+	documents := map[string]*Document{}
+
+	for _, inputFilename := range inputFilenames {
+		document, err := UnmarshalFile(filepath.Join("testdata", "input", inputFilename))
+		if err != nil {
+			t.Fatalf("failed to unmarshal input file %s: %s", inputFilename, err)
+		}
+
+		documents[inputFilename] = document
+	}
+
+	r := rand.New(rand.NewPCG(3, 4))
+	for i := range 20 {
+		documents[fmt.Sprintf("generated %d", i)] = &Document{
+			Title: generateText(r, false),
+			Root:  generateFolder(r, 0),
+		}
+	}
+
+	for tname, document := range documents {
+		t.Run(tname, func(t *testing.T) {
+			opts := []EncoderOption{WithProfile(ProfileFirefox), WithCRLF()}
+
+			want, err := MarshalWithOptions(document, opts...)
+			if err != nil {
+				t.Fatalf("expected no error, got %q", err)
+			}
+
+			var buf bytes.Buffer
+			e := NewEncoder(&buf, opts...)
+
+			if err := e.StartDocument(document.Title); err != nil {
+				t.Fatalf("expected no error, got %q", err)
+			}
+
+			if err := streamFolder(e, &document.Root); err != nil {
+				t.Fatalf("expected no error, got %q", err)
+			}
+
+			if err := e.Close(); err != nil {
+				t.Fatalf("expected no error, got %q", err)
+			}
+
+			if got := buf.String(); got != string(want) {
+				t.Errorf("want:\n%s\ngot:\n%s", want, got)
+			}
+		})
+	}
+}
+
+func TestEncoderStreamSequence(t *testing.T) {
+	cases := []struct {
+		tname string
+		calls func(e *Encoder) error
+	}{
+		{
+			tname: "folder before document",
+			calls: func(e *Encoder) error {
+				return e.StartFolder(&Folder{Name: "Bookmarks"})
+			},
+		},
+		{
+			tname: "document started twice",
+			calls: func(e *Encoder) error {
+				if err := e.StartDocument("Bookmarks"); err != nil {
+					return err
+				}
+				return e.StartDocument("Bookmarks")
+			},
+		},
+		{
+			tname: "bookmark outside of a folder",
+			calls: func(e *Encoder) error {
+				if err := e.StartDocument("Bookmarks"); err != nil {
+					return err
+				}
+				return e.WriteBookmark(&Bookmark{URL: "https://domain.tld"})
+			},
+		},
+		{
+			tname: "separator outside of a folder",
+			calls: func(e *Encoder) error {
+				if err := e.StartDocument("Bookmarks"); err != nil {
+					return err
+				}
+				return e.WriteSeparator(&Separator{})
+			},
+		},
+		{
+			tname: "unmatched EndFolder",
+			calls: func(e *Encoder) error {
+				if err := e.StartDocument("Bookmarks"); err != nil {
+					return err
+				}
+				return e.EndFolder()
+			},
+		},
+		{
+			tname: "second root folder",
+			calls: func(e *Encoder) error {
+				if err := e.StartDocument("Bookmarks"); err != nil {
+					return err
+				}
+				if err := e.StartFolder(&Folder{Name: "Bookmarks"}); err != nil {
+					return err
+				}
+				if err := e.EndFolder(); err != nil {
+					return err
+				}
+				return e.StartFolder(&Folder{Name: "Bookmarks"})
+			},
+		},
+		{
+			tname: "close with open folders",
+			calls: func(e *Encoder) error {
+				if err := e.StartDocument("Bookmarks"); err != nil {
+					return err
+				}
+				if err := e.StartFolder(&Folder{Name: "Bookmarks"}); err != nil {
+					return err
+				}
+				return e.Close()
+			},
+		},
+		{
+			tname: "close without root folder",
+			calls: func(e *Encoder) error {
+				if err := e.StartDocument("Bookmarks"); err != nil {
+					return err
+				}
+				return e.Close()
+			},
+		},
+		{
+			tname: "encode a streamed document",
+			calls: func(e *Encoder) error {
+				if err := e.StartDocument("Bookmarks"); err != nil {
+					return err
+				}
+				return e.Encode(&Document{})
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.tname, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			err := tc.calls(NewEncoder(&buf))
+
+			if !errors.Is(err, ErrEncoderSequenceInvalid) {
+				t.Fatalf("want error %q, got %q", ErrEncoderSequenceInvalid, err)
+			}
+		})
+	}
+}
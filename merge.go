@@ -0,0 +1,313 @@
+// Copyright (c) VirtualTam
+// SPDX-License-Identifier: MIT
+
+package netscape
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"unicode/utf8"
+)
+
+// A MergePolicy selects which Bookmark is kept when the same Bookmark is found
+// in several Documents.
+type MergePolicy int
+
+const (
+	// MergeKeepFirst keeps the Bookmark found first.
+	MergeKeepFirst MergePolicy = iota
+
+	// MergeNewest keeps the Bookmark with the most recent update date; on
+	// equal dates, the Bookmark found first is kept.
+	MergeNewest
+
+	// MergePreferSource keeps the Bookmark from the Document selected by
+	// MergeOptions.PreferredSource; Bookmarks that are not found in this
+	// Document are merged following MergeKeepFirst.
+	MergePreferSource
+)
+
+// MergeOptions configures how Documents are merged.
+type MergeOptions struct {
+	// Policy selects which Bookmark is kept when the same Bookmark is found
+	// in several Documents.
+	Policy MergePolicy
+
+	// PreferredSource is the index of the Document preferred by
+	// MergePreferSource.
+	PreferredSource int
+
+	// UnionTags adds the tags of the discarded Bookmarks to the kept Bookmark.
+	UnionTags bool
+
+	// LongestDescription keeps the longest description of the same
+	// Bookmarks, regardless of which Bookmark is kept.
+	LongestDescription bool
+
+	// NormalizeURL returns the key used to detect the same Bookmark from its
+	// URL. If nil, URLs are compared as they are.
+	NormalizeURL func(url string) string
+
+	// MatchByID detects the same Bookmark from its ID, as returned by
+	// Bookmark.ID, instead of its URL.
+	MatchByID bool
+}
+
+// A MergeAction identifies the decision taken when merging an item.
+type MergeAction int
+
+const (
+	// MergeFolderAdded indicates that a Folder has been added.
+	MergeFolderAdded MergeAction = iota
+
+	// MergeFolderMerged indicates that the content of a Folder has been
+	// merged into an existing Folder with the same Path.
+	MergeFolderMerged
+
+	// MergeBookmarkAdded indicates that a Bookmark has been added.
+	MergeBookmarkAdded
+
+	// MergeBookmarkKept indicates that the same Bookmark has already been
+	// added, and has been kept.
+	MergeBookmarkKept
+
+	// MergeBookmarkReplaced indicates that the same Bookmark has already
+	// been added, and has been replaced.
+	MergeBookmarkReplaced
+)
+
+var mergeActionNames = map[MergeAction]string{
+	MergeFolderAdded:      "folder added",
+	MergeFolderMerged:     "folder merged",
+	MergeBookmarkAdded:    "bookmark added",
+	MergeBookmarkKept:     "bookmark kept",
+	MergeBookmarkReplaced: "bookmark replaced",
+}
+
+// String returns the string representation for this MergeAction.
+func (a MergeAction) String() string {
+	if name, ok := mergeActionNames[a]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("MergeAction(%d)", int(a))
+}
+
+// A MergeDecision describes how an item of a source Document has been merged.
+type MergeDecision struct {
+	Action MergeAction
+
+	// Index of the source Document containing the item.
+	Source int
+
+	// Path of the merged Folder, or of the Folder containing the merged
+	// Bookmark, in the merged Document.
+	Path Path
+
+	// URL of the merged Bookmark.
+	URL string
+
+	// Description of the decision.
+	Msg string
+}
+
+// String returns the string representation for this MergeDecision.
+func (d MergeDecision) String() string {
+	return fmt.Sprintf("source %d: %s: %s", d.Source, d.Action, d.Msg)
+}
+
+// A MergeReport lists the decisions taken when merging Documents.
+type MergeReport struct {
+	Decisions []MergeDecision
+}
+
+// Merge returns a Document containing the folders and Bookmarks of docs.
+//
+// Folders with the same Path are merged, and their items are added in
+// document order; the separators of a Folder are only kept from the first
+// Document containing it. The title and Root Folder attributes are those of
+// the first Document.
+//
+// Bookmarks with the same URL, the same normalized URL, or the same ID, are
+// merged, even within a single Document: the kept Bookmark is selected by
+// opts, and stays in the Folder where the same Bookmark was found first.
+//
+// Every decision taken is listed in the returned MergeReport, in order. The
+// source Documents are not modified.
+func Merge(opts MergeOptions, docs ...*Document) (*Document, MergeReport) {
+	m := merger{
+		opts:      opts,
+		bookmarks: make(map[string]*mergedBookmark),
+	}
+
+	merged := &Document{}
+
+	for source, doc := range docs {
+		if doc == nil {
+			continue
+		}
+
+		if m.root == nil {
+			merged.Title = doc.Title
+			m.root = newFolderBuilder(cloneHeader(&doc.Root))
+			m.mergeFolder(m.root, &doc.Root, Path{}, source, true)
+			continue
+		}
+
+		m.mergeFolder(m.root, &doc.Root, Path{}, source, false)
+	}
+
+	if m.root != nil {
+		merged.Root = m.root.build()
+	}
+
+	return merged, m.report
+}
+
+// A merger merges Folder trees into a single Folder tree.
+type merger struct {
+	opts   MergeOptions
+	root   *folderBuilder
+	report MergeReport
+
+	// merged Bookmark for each URL key.
+	bookmarks map[string]*mergedBookmark
+}
+
+// A mergedBookmark locates a Bookmark of the merged Folder tree.
+type mergedBookmark struct {
+	folder *folderBuilder
+	index  int
+	path   Path
+
+	// index of the source Document of the kept Bookmark.
+	source int
+}
+
+func (m *merger) mergeFolder(b *folderBuilder, folder *Folder, path Path, source int, created bool) {
+	for _, ref := range folder.orderedItems() {
+		switch ref.Kind {
+		case BookmarkKind:
+			m.mergeBookmark(b, folder.Bookmarks[ref.Index].clone(), path, source)
+		case FolderKind:
+			subfolder := &folder.Subfolders[ref.Index]
+			subpath := append(slices.Clip(path), subfolder.Name)
+
+			builder, subfolderCreated := b.subfolder(cloneHeader(subfolder))
+
+			action := MergeFolderMerged
+			if subfolderCreated {
+				action = MergeFolderAdded
+			}
+
+			m.decide(MergeDecision{
+				Action: action,
+				Source: source,
+				Path:   subpath,
+				Msg:    fmt.Sprintf("%q", FormatPath(subpath)),
+			})
+
+			m.mergeFolder(builder, subfolder, subpath, source, subfolderCreated)
+		case SeparatorKind:
+			if created {
+				b.addSeparator(Separator{Attributes: maps.Clone(folder.Separators[ref.Index].Attributes)})
+			}
+		}
+	}
+}
+
+func (m *merger) mergeBookmark(b *folderBuilder, bookmark Bookmark, path Path, source int) {
+	key := bookmark.URL
+	switch {
+	case m.opts.MatchByID:
+		key = bookmark.ID()
+	case m.opts.NormalizeURL != nil:
+		key = m.opts.NormalizeURL(key)
+	}
+
+	existing, ok := m.bookmarks[key]
+	if !ok {
+		m.bookmarks[key] = &mergedBookmark{
+			folder: b,
+			index:  b.addBookmark(bookmark),
+			path:   path,
+			source: source,
+		}
+
+		m.decide(MergeDecision{
+			Action: MergeBookmarkAdded,
+			Source: source,
+			Path:   path,
+			URL:    bookmark.URL,
+			Msg:    fmt.Sprintf("%s in %q", bookmark.URL, FormatPath(path)),
+		})
+		return
+	}
+
+	current := &existing.folder.f.Bookmarks[existing.index]
+
+	replace, reason := m.replaces(current, existing.source, &bookmark, source)
+
+	kept, discarded := *current, bookmark
+	action := MergeBookmarkKept
+	if replace {
+		kept, discarded = bookmark, *current
+		action = MergeBookmarkReplaced
+		existing.source = source
+	}
+
+	msg := fmt.Sprintf("%s in %q: kept bookmark from source %d (%s)", kept.URL, FormatPath(existing.path), existing.source, reason)
+
+	if m.opts.UnionTags {
+		tags := appendMissing(slices.Clone(kept.Tags), discarded.Tags...)
+		if len(tags) > len(kept.Tags) {
+			msg += "; merged tags"
+		}
+		kept.Tags = tags
+	}
+
+	if m.opts.LongestDescription && utf8.RuneCountInString(discarded.Description) > utf8.RuneCountInString(kept.Description) {
+		kept.Description = discarded.Description
+		msg += "; kept longest description"
+	}
+
+	*current = kept
+
+	m.decide(MergeDecision{
+		Action: action,
+		Source: source,
+		Path:   existing.path,
+		URL:    bookmark.URL,
+		Msg:    msg,
+	})
+}
+
+// replaces returns whether the Bookmark found in the source Document with the
+// given index replaces the current Bookmark, along with the reason.
+func (m *merger) replaces(current *Bookmark, currentSource int, bookmark *Bookmark, source int) (bool, string) {
+	switch m.opts.Policy {
+	case MergeNewest:
+		if bookmark.UpdatedAt.After(current.UpdatedAt) {
+			return true, "newest"
+		}
+
+		if current.UpdatedAt.After(bookmark.UpdatedAt) {
+			return false, "newest"
+		}
+	case MergePreferSource:
+		if source == m.opts.PreferredSource && currentSource != m.opts.PreferredSource {
+			return true, "preferred source"
+		}
+
+		if currentSource == m.opts.PreferredSource {
+			return false, "preferred source"
+		}
+	}
+
+	return false, "first found"
+}
+
+func (m *merger) decide(decision MergeDecision) {
+	m.report.Decisions = append(m.report.Decisions, decision)
+}
@@ -0,0 +1,931 @@
+// Copyright (c) VirtualTam
+// SPDX-License-Identifier: MIT
+
+package netscape
+
+import (
+	"fmt"
+	"maps"
+	"strconv"
+)
+
+// A Side identifies one of the Documents of a three-way merge.
+type Side int
+
+const (
+	// SideBase is the common ancestor of the edited Documents.
+	SideBase Side = iota
+
+	// SideOurs is the first edited Document.
+	SideOurs
+
+	// SideTheirs is the second edited Document.
+	SideTheirs
+)
+
+var sideNames = map[Side]string{
+	SideBase:   "base",
+	SideOurs:   "ours",
+	SideTheirs: "theirs",
+}
+
+// String returns the string representation for this Side.
+func (s Side) String() string {
+	if name, ok := sideNames[s]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("Side(%d)", int(s))
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (s Side) MarshalText() ([]byte, error) {
+	if _, ok := sideNames[s]; !ok {
+		return nil, fmt.Errorf("invalid side %d", int(s))
+	}
+
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (s *Side) UnmarshalText(text []byte) error {
+	for side, name := range sideNames {
+		if name == string(text) {
+			*s = side
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown side %q", text)
+}
+
+// A ConflictKind identifies the type of a Conflict.
+type ConflictKind int
+
+const (
+	// ConflictTitleEdited indicates that the title of the Document has been
+	// edited differently on both sides.
+	ConflictTitleEdited ConflictKind = iota
+
+	// ConflictFolderEdited indicates that a field of a Folder has been edited
+	// differently on both sides.
+	ConflictFolderEdited
+
+	// ConflictFolderMoved indicates that a Folder has been moved to different
+	// Folders on both sides.
+	ConflictFolderMoved
+
+	// ConflictFolderDeleted indicates that a Folder has been deleted on one
+	// side, and modified on the other side, including its content.
+	ConflictFolderDeleted
+
+	// ConflictBookmarkEdited indicates that a field of a Bookmark has been
+	// edited differently on both sides.
+	ConflictBookmarkEdited
+
+	// ConflictBookmarkMoved indicates that a Bookmark has been moved to
+	// different Folders on both sides.
+	ConflictBookmarkMoved
+
+	// ConflictBookmarkDeleted indicates that a Bookmark has been deleted on
+	// one side, and modified or moved on the other side.
+	ConflictBookmarkDeleted
+)
+
+var conflictKindNames = map[ConflictKind]string{
+	ConflictTitleEdited:     "title_edited",
+	ConflictFolderEdited:    "folder_edited",
+	ConflictFolderMoved:     "folder_moved",
+	ConflictFolderDeleted:   "folder_deleted",
+	ConflictBookmarkEdited:  "bookmark_edited",
+	ConflictBookmarkMoved:   "bookmark_moved",
+	ConflictBookmarkDeleted: "bookmark_deleted",
+}
+
+// String returns the string representation for this ConflictKind.
+func (k ConflictKind) String() string {
+	if name, ok := conflictKindNames[k]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("ConflictKind(%d)", int(k))
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (k ConflictKind) MarshalText() ([]byte, error) {
+	if _, ok := conflictKindNames[k]; !ok {
+		return nil, fmt.Errorf("invalid conflict kind %d", int(k))
+	}
+
+	return []byte(k.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (k *ConflictKind) UnmarshalText(text []byte) error {
+	for kind, name := range conflictKindNames {
+		if name == string(text) {
+			*k = kind
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown conflict kind %q", text)
+}
+
+// A Conflict describes an item edited differently on both sides of a
+// three-way merge, and how it has been resolved.
+type Conflict struct {
+	Kind ConflictKind `json:"kind"`
+
+	// Path of the conflicting Folder, or of the Folder containing the
+	// conflicting Bookmark, in the base Document. For Bookmarks added on both
+	// sides, Path is their Folder in ours.
+	Path Path `json:"path"`
+
+	// URL of the conflicting Bookmark.
+	URL string `json:"url,omitempty"`
+
+	// Field edited on both sides, for ConflictTitleEdited,
+	// ConflictFolderEdited and ConflictBookmarkEdited.
+	Field string `json:"field,omitempty"`
+
+	// Values of Field in each Document, formatted as in FieldChange.
+	//
+	// For ConflictFolderMoved and ConflictBookmarkMoved, the Paths of the
+	// Folders containing the item in each Document, e.g. "/Dev/Tools".
+	Base   string `json:"base,omitempty"`
+	Ours   string `json:"ours,omitempty"`
+	Theirs string `json:"theirs,omitempty"`
+
+	// Deleted is the side where the item has been deleted, for
+	// ConflictFolderDeleted and ConflictBookmarkDeleted.
+	Deleted Side `json:"deleted,omitempty"`
+
+	// Resolution is the side whose version is kept in the merged Document.
+	Resolution Side `json:"resolution"`
+}
+
+// String returns the string representation for this Conflict.
+func (c Conflict) String() string {
+	var item string
+
+	switch c.Kind {
+	case ConflictTitleEdited:
+		item = "title"
+	case ConflictFolderEdited, ConflictFolderMoved, ConflictFolderDeleted:
+		item = fmt.Sprintf("folder %q", displayPath(c.Path))
+	default:
+		item = fmt.Sprintf("bookmark %s in %q", c.URL, displayPath(c.Path))
+	}
+
+	var msg string
+
+	switch c.Kind {
+	case ConflictFolderMoved, ConflictBookmarkMoved:
+		msg = fmt.Sprintf("moved to %q (ours) and %q (theirs)", c.Ours, c.Theirs)
+	case ConflictFolderDeleted, ConflictBookmarkDeleted:
+		modified := SideOurs
+		if c.Deleted == SideOurs {
+			modified = SideTheirs
+		}
+		msg = fmt.Sprintf("deleted in %s and modified in %s", c.Deleted, modified)
+	default:
+		msg = fmt.Sprintf("%s: %q -> %q (ours) and %q (theirs)", c.Field, c.Base, c.Ours, c.Theirs)
+	}
+
+	return fmt.Sprintf("%s: %s, kept %s", item, msg, c.Resolution)
+}
+
+// A ConflictResolver returns the side whose version is kept for a Conflict.
+//
+// For ConflictFolderDeleted and ConflictBookmarkDeleted, keeping the side
+// where the item has been deleted deletes the item, and keeping SideBase
+// restores the item as it was in the base Document.
+type ConflictResolver func(conflict Conflict) Side
+
+// ResolveOurs is a ConflictResolver that keeps the version of ours.
+func ResolveOurs(Conflict) Side {
+	return SideOurs
+}
+
+// ResolveTheirs is a ConflictResolver that keeps the version of theirs.
+func ResolveTheirs(Conflict) Side {
+	return SideTheirs
+}
+
+type merge3Options struct {
+	resolver ConflictResolver
+}
+
+// A Merge3Option configures a three-way merge.
+type Merge3Option func(*merge3Options)
+
+// WithConflictResolver sets the ConflictResolver used to resolve conflicts.
+//
+// Defaults to ResolveOurs.
+func WithConflictResolver(resolver ConflictResolver) Merge3Option {
+	return func(o *merge3Options) {
+		o.resolver = resolver
+	}
+}
+
+// Merge3 merges the edits of ours and theirs, two Documents derived from the
+// common base Document, and returns the merged Document along with the
+// Conflicts between both sides.
+//
+// Items are matched as in Diff. Edits of different items, or of different
+// fields of the same item, are merged automatically; the same edit on both
+// sides is applied once. Otherwise, the edits are reported as a Conflict,
+// and the ConflictResolver selects the version that is kept.
+//
+// When a Folder is deleted, its content is deleted as well. Folders and
+// Bookmarks added on both sides are merged if they have the same name, or
+// the same URL within the same Folder. The order of items follows ours,
+// followed by the items from theirs.
+func Merge3(base, ours, theirs *Document, opts ...Merge3Option) (*Document, []Conflict) {
+	options := merge3Options{
+		resolver: ResolveOurs,
+	}
+
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if base == nil {
+		base = &Document{}
+	}
+	if ours == nil {
+		ours = &Document{}
+	}
+	if theirs == nil {
+		theirs = &Document{}
+	}
+
+	m := newMerger3(base, ours, theirs, options.resolver)
+
+	title := m.mergeValue(base.Title, ours.Title, theirs.Title, Conflict{Kind: ConflictTitleEdited, Field: "title"})
+
+	m.mergeFolders()
+	m.mergeBookmarks()
+
+	merged := &Document{
+		Title: title,
+		Root:  m.build(),
+	}
+
+	return merged, m.conflicts
+}
+
+// editedSides lists the sides edited from the base Document.
+var editedSides = []Side{SideOurs, SideTheirs}
+
+// A merge3Folder is a Folder of the merged Document.
+type merge3Folder struct {
+	header Folder
+
+	// index of the Folder in the tree of each side, or -1
+	sides [3]int
+
+	// index of the parent Folder, -1 for the Root Folder
+	parent int
+
+	removed bool
+}
+
+// A merge3Bookmark is a Bookmark of the merged Document.
+type merge3Bookmark struct {
+	bookmark Bookmark
+
+	// index of the Bookmark in the tree of each side, or -1
+	sides [3]int
+
+	// index of the Folder containing the Bookmark
+	folder int
+
+	removed bool
+}
+
+// A merger3 merges Documents edited from a common base Document.
+type merger3 struct {
+	resolver  ConflictResolver
+	conflicts []Conflict
+
+	// tree of each side; bases holds the base tree matched with each edited
+	// side
+	trees [3]*diffTree
+	bases [3]*diffTree
+
+	folders   []merge3Folder
+	bookmarks []merge3Bookmark
+
+	// index of the merged item for each item of the tree of each side
+	folderIndex   [3][]int
+	bookmarkIndex [3][]int
+
+	// index of each item in the tree of each side
+	folderPtrs   [3]map[*Folder]int
+	bookmarkPtrs [3]map[*Bookmark]int
+
+	// covered reports whether the deletion of a base Folder is covered by a
+	// ConflictFolderDeleted, for this Folder or one of its parents
+	covered []bool
+}
+
+func newMerger3(base, ours, theirs *Document, resolver ConflictResolver) *merger3 {
+	oursMatch := matchDocuments(base, ours)
+	theirsMatch := matchDocuments(base, theirs)
+
+	m := &merger3{
+		resolver: resolver,
+		trees:    [3]*diffTree{oursMatch.oldTree, oursMatch.newTree, theirsMatch.newTree},
+		bases:    [3]*diffTree{oursMatch.oldTree, oursMatch.oldTree, theirsMatch.oldTree},
+	}
+
+	for side, tree := range m.trees {
+		m.folderIndex[side] = make([]int, len(tree.folders))
+		m.bookmarkIndex[side] = make([]int, len(tree.bookmarks))
+
+		m.folderPtrs[side] = make(map[*Folder]int, len(tree.folders))
+		for j, folder := range tree.folders {
+			m.folderPtrs[side][folder.folder] = j
+		}
+
+		m.bookmarkPtrs[side] = make(map[*Bookmark]int, len(tree.bookmarks))
+		for j, bookmark := range tree.bookmarks {
+			m.bookmarkPtrs[side][bookmark.bookmark] = j
+		}
+	}
+
+	return m
+}
+
+// resolve records conflict, and returns the side selected by the
+// ConflictResolver.
+func (m *merger3) resolve(conflict *Conflict) Side {
+	side := m.resolver(*conflict)
+	if _, ok := sideNames[side]; !ok {
+		side = SideOurs
+	}
+
+	conflict.Resolution = side
+	m.conflicts = append(m.conflicts, *conflict)
+
+	return side
+}
+
+// mergeValue returns the merged value of a field, from its value on each
+// side.
+func (m *merger3) mergeValue(base, ours, theirs string, conflict Conflict) string {
+	switch {
+	case ours == theirs, theirs == base:
+		return ours
+	case ours == base:
+		return theirs
+	}
+
+	conflict.Base = base
+	conflict.Ours = ours
+	conflict.Theirs = theirs
+
+	switch m.resolve(&conflict) {
+	case SideBase:
+		return base
+	case SideTheirs:
+		return theirs
+	default:
+		return ours
+	}
+}
+
+// mergeLocation returns the index of the merged Folder containing an item,
+// from the index of the merged Folder and the Path of the Folder containing
+// it on each side.
+func (m *merger3) mergeLocation(folders [3]int, paths [3]Path, conflict Conflict) int {
+	base, ours, theirs := folders[SideBase], folders[SideOurs], folders[SideTheirs]
+
+	switch {
+	case ours == theirs, theirs == base:
+		return ours
+	case ours == base:
+		return theirs
+	}
+
+	conflict.Base = displayPath(paths[SideBase])
+	conflict.Ours = displayPath(paths[SideOurs])
+	conflict.Theirs = displayPath(paths[SideTheirs])
+
+	return folders[m.resolve(&conflict)]
+}
+
+// mergeFields sets the fields of merged, a copy of base, that have been
+// edited in ours or theirs.
+func mergeFields[T any](m *merger3, fields itemFields[T], merged, base, ours, theirs *T, conflict Conflict) {
+	type values struct {
+		base, ours, theirs string
+	}
+
+	var names []string
+	edited := make(map[string]*values)
+
+	for _, change := range fields.changes(base, ours) {
+		names = append(names, change.Field)
+		edited[change.Field] = &values{base: change.Old, ours: change.New, theirs: change.Old}
+	}
+
+	for _, change := range fields.changes(base, theirs) {
+		v, ok := edited[change.Field]
+		if !ok {
+			names = append(names, change.Field)
+			v = &values{base: change.Old, ours: change.Old}
+			edited[change.Field] = v
+		}
+
+		v.theirs = change.New
+	}
+
+	for _, name := range names {
+		v := edited[name]
+
+		conflict.Field = name
+		value := m.mergeValue(v.base, v.ours, v.theirs, conflict)
+
+		// values are formatted by fields, and thus valid
+		_ = fields.set(merged, name, value)
+	}
+}
+
+func (m *merger3) mergeFolders() {
+	base := m.trees[SideBase]
+
+	for i, folder := range base.folders {
+		f := merge3Folder{
+			header: cloneHeader(folder.folder),
+			sides:  [3]int{i, -1, -1},
+			parent: folder.parent,
+		}
+
+		m.folderIndex[SideBase][i] = i
+
+		for _, side := range editedSides {
+			if j := m.bases[side].folders[i].match; j >= 0 {
+				f.sides[side] = j
+				m.folderIndex[side][j] = i
+			}
+		}
+
+		m.folders = append(m.folders, f)
+	}
+
+	for _, side := range editedSides {
+		for j, folder := range m.trees[side].folders {
+			if folder.match >= 0 {
+				continue
+			}
+
+			f := merge3Folder{
+				header: cloneHeader(folder.folder),
+				sides:  [3]int{-1, -1, -1},
+			}
+			f.sides[side] = j
+
+			m.folderIndex[side][j] = len(m.folders)
+			m.folders = append(m.folders, f)
+		}
+	}
+
+	// added Folders are located in their parent Folder, which has been
+	// added to the merged Folders above
+	for i := len(base.folders); i < len(m.folders); i++ {
+		side := SideOurs
+		if m.folders[i].sides[SideOurs] < 0 {
+			side = SideTheirs
+		}
+
+		parent := m.trees[side].folders[m.folders[i].sides[side]].parent
+		m.folders[i].parent = m.folderIndex[side][parent]
+	}
+
+	modified := [3][]bool{
+		SideOurs:   m.modifiedFolders(SideOurs),
+		SideTheirs: m.modifiedFolders(SideTheirs),
+	}
+
+	m.covered = make([]bool, len(base.folders))
+
+	for i := range base.folders {
+		m.mergeFolder(i, modified)
+	}
+
+	m.fixCycles()
+
+	// the content of removed Folders is removed as well
+	for i := range m.folders {
+		for p := i; p >= 0; p = m.folders[p].parent {
+			if m.folders[p].removed {
+				m.folders[i].removed = true
+				break
+			}
+		}
+	}
+}
+
+// mergeFolder merges the header and location of the base Folder i.
+func (m *merger3) mergeFolder(i int, modified [3][]bool) {
+	f := &m.folders[i]
+	base := m.trees[SideBase].folders[i]
+
+	switch ours, theirs := f.sides[SideOurs], f.sides[SideTheirs]; {
+	case ours < 0 && theirs < 0:
+		f.removed = true
+
+	case ours < 0 || theirs < 0:
+		deleted, kept := SideOurs, SideTheirs
+		if theirs < 0 {
+			deleted, kept = SideTheirs, SideOurs
+		}
+
+		j := f.sides[kept]
+		side := kept
+
+		switch parent := base.parent; {
+		case m.folders[parent].sides[deleted] < 0 && m.covered[parent]:
+			// the deletion of the parent Folder is in conflict, and determines
+			// whether this Folder is kept
+		case !modified[kept][j]:
+			f.removed = true
+			return
+		default:
+			side = m.resolve(&Conflict{Kind: ConflictFolderDeleted, Path: base.path, Deleted: deleted})
+		}
+
+		m.covered[i] = true
+
+		switch side {
+		case deleted:
+			f.removed = true
+		case kept:
+			folder := m.trees[kept].folders[j]
+			f.header = cloneHeader(folder.folder)
+			f.parent = m.folderIndex[kept][folder.parent]
+		}
+
+	default:
+		ours, theirs := m.trees[SideOurs].folders[ours], m.trees[SideTheirs].folders[theirs]
+
+		conflict := Conflict{Kind: ConflictFolderEdited, Path: base.path}
+
+		mergeFields(m, folderFields, &f.header, base.folder, ours.folder, theirs.folder, conflict)
+
+		conflict.Field = "name"
+		f.header.Name = m.mergeValue(base.folder.Name, ours.folder.Name, theirs.folder.Name, conflict)
+
+		if i == 0 {
+			return
+		}
+
+		f.parent = m.mergeLocation(
+			[3]int{base.parent, m.folderIndex[SideOurs][ours.parent], m.folderIndex[SideTheirs][theirs.parent]},
+			[3]Path{
+				m.trees[SideBase].folders[base.parent].path,
+				m.trees[SideOurs].folders[ours.parent].path,
+				m.trees[SideTheirs].folders[theirs.parent].path,
+			},
+			Conflict{Kind: ConflictFolderMoved, Path: base.path},
+		)
+	}
+}
+
+// modifiedFolders reports whether each Folder of the tree of side has been
+// added, moved or modified from the base Document, including its content.
+func (m *merger3) modifiedFolders(side Side) []bool {
+	base := m.trees[SideBase]
+	tree := m.trees[side]
+
+	modified := make([]bool, len(tree.folders))
+
+	for j, folder := range tree.folders {
+		i := folder.match
+
+		switch {
+		case i < 0:
+			modified[j] = true
+		case j > 0 && tree.folders[folder.parent].match != base.folders[i].parent:
+			modified[j] = true
+		case folder.folder.Name != base.folders[i].folder.Name:
+			modified[j] = true
+		case len(folderFields.changes(base.folders[i].folder, folder.folder)) > 0:
+			modified[j] = true
+		}
+	}
+
+	for _, bookmark := range tree.bookmarks {
+		k := bookmark.match
+
+		switch {
+		case k < 0:
+			modified[bookmark.folder] = true
+		case tree.folders[bookmark.folder].match != base.bookmarks[k].folder:
+			modified[bookmark.folder] = true
+		case len(bookmarkFields.changes(base.bookmarks[k].bookmark, bookmark.bookmark)) > 0:
+			modified[bookmark.folder] = true
+		}
+	}
+
+	// Folders are listed in document order, after their parent
+	for j := len(tree.folders) - 1; j > 0; j-- {
+		if modified[j] {
+			modified[tree.folders[j].parent] = true
+		}
+	}
+
+	return modified
+}
+
+// fixCycles moves the Folders that have been moved into one of their
+// subfolders back to their parent in the base Document, which may happen
+// when Folders have been moved into each other on different sides.
+func (m *merger3) fixCycles() {
+	for {
+		i := m.findCycle()
+		if i < 0 {
+			return
+		}
+
+		m.folders[i].parent = m.trees[SideBase].folders[i].parent
+	}
+}
+
+// findCycle returns the index of a moved base Folder that is its own
+// ancestor, or -1.
+func (m *merger3) findCycle() int {
+	base := m.trees[SideBase]
+
+	for i := range m.folders {
+		seen := make(map[int]bool)
+
+		j := i
+		for j >= 0 && !seen[j] {
+			seen[j] = true
+			j = m.folders[j].parent
+		}
+
+		if j < 0 {
+			continue
+		}
+
+		// j is part of a cycle, which contains at least one moved base Folder
+		for k := m.folders[j].parent; ; k = m.folders[k].parent {
+			if k < len(base.folders) && m.folders[k].parent != base.folders[k].parent {
+				return k
+			}
+
+			if k == j {
+				break
+			}
+		}
+	}
+
+	return -1
+}
+
+func (m *merger3) mergeBookmarks() {
+	base := m.trees[SideBase]
+
+	for k := range base.bookmarks {
+		b := merge3Bookmark{
+			sides: [3]int{k, -1, -1},
+		}
+
+		m.bookmarkIndex[SideBase][k] = k
+
+		for _, side := range editedSides {
+			if j := m.bases[side].bookmarks[k].match; j >= 0 {
+				b.sides[side] = j
+				m.bookmarkIndex[side][j] = k
+			}
+		}
+
+		m.bookmarks = append(m.bookmarks, b)
+	}
+
+	// Bookmarks added on both sides to the same Folder
+	added := make(map[string]int)
+
+	for _, side := range editedSides {
+		for j, bookmark := range m.trees[side].bookmarks {
+			if bookmark.match >= 0 {
+				continue
+			}
+
+			key := bookmark.bookmark.URL + "\x00" + strconv.Itoa(m.folderIndex[side][bookmark.folder])
+
+			if index, ok := added[key]; ok && side == SideTheirs && m.bookmarks[index].sides[SideTheirs] < 0 {
+				m.bookmarks[index].sides[SideTheirs] = j
+				m.bookmarkIndex[side][j] = index
+				continue
+			} else if !ok && side == SideOurs {
+				added[key] = len(m.bookmarks)
+			}
+
+			b := merge3Bookmark{
+				sides: [3]int{-1, -1, -1},
+			}
+			b.sides[side] = j
+
+			m.bookmarkIndex[side][j] = len(m.bookmarks)
+			m.bookmarks = append(m.bookmarks, b)
+		}
+	}
+
+	for index := range m.bookmarks {
+		m.mergeBookmark(index)
+	}
+}
+
+// mergeBookmark merges the fields and location of the Bookmark with the
+// given index.
+func (m *merger3) mergeBookmark(index int) {
+	b := &m.bookmarks[index]
+	ours, theirs := b.sides[SideOurs], b.sides[SideTheirs]
+
+	if b.sides[SideBase] < 0 {
+		if ours >= 0 && theirs >= 0 {
+			// added on both sides
+			ours, theirs := m.trees[SideOurs].bookmarks[ours], m.trees[SideTheirs].bookmarks[theirs]
+
+			conflict := Conflict{
+				Kind: ConflictBookmarkEdited,
+				Path: m.trees[SideOurs].folders[ours.folder].path,
+				URL:  ours.bookmark.URL,
+			}
+
+			mergeFields(m, bookmarkFields, &b.bookmark, &Bookmark{}, ours.bookmark, theirs.bookmark, conflict)
+			b.folder = m.folderIndex[SideOurs][ours.folder]
+
+			return
+		}
+
+		side := SideOurs
+		if ours < 0 {
+			side = SideTheirs
+		}
+
+		bookmark := m.trees[side].bookmarks[b.sides[side]]
+		b.bookmark = bookmark.bookmark.clone()
+		b.folder = m.folderIndex[side][bookmark.folder]
+
+		return
+	}
+
+	base := m.trees[SideBase].bookmarks[b.sides[SideBase]]
+	path := m.trees[SideBase].folders[base.folder].path
+
+	switch {
+	case ours < 0 && theirs < 0:
+		b.removed = true
+
+	case ours < 0 || theirs < 0:
+		deleted, kept := SideOurs, SideTheirs
+		if theirs < 0 {
+			deleted, kept = SideTheirs, SideOurs
+		}
+
+		bookmark := m.trees[kept].bookmarks[b.sides[kept]]
+		folder := m.folderIndex[kept][bookmark.folder]
+
+		side := kept
+
+		switch {
+		case m.folders[base.folder].sides[deleted] < 0 && m.covered[base.folder]:
+			// the deletion of the Folder is in conflict, and determines
+			// whether this Bookmark is kept
+		case folder == base.folder && len(bookmarkFields.changes(base.bookmark, bookmark.bookmark)) == 0:
+			b.removed = true
+			return
+		default:
+			side = m.resolve(&Conflict{Kind: ConflictBookmarkDeleted, Path: path, URL: base.bookmark.URL, Deleted: deleted})
+		}
+
+		switch side {
+		case deleted:
+			b.removed = true
+		case kept:
+			b.bookmark = bookmark.bookmark.clone()
+			b.folder = folder
+		default:
+			b.bookmark = base.bookmark.clone()
+			b.folder = base.folder
+		}
+
+	default:
+		ours, theirs := m.trees[SideOurs].bookmarks[ours], m.trees[SideTheirs].bookmarks[theirs]
+
+		b.bookmark = base.bookmark.clone()
+
+		mergeFields(m, bookmarkFields, &b.bookmark, base.bookmark, ours.bookmark, theirs.bookmark, Conflict{
+			Kind: ConflictBookmarkEdited,
+			Path: path,
+			URL:  base.bookmark.URL,
+		})
+
+		b.folder = m.mergeLocation(
+			[3]int{base.folder, m.folderIndex[SideOurs][ours.folder], m.folderIndex[SideTheirs][theirs.folder]},
+			[3]Path{
+				path,
+				m.trees[SideOurs].folders[ours.folder].path,
+				m.trees[SideTheirs].folders[theirs.folder].path,
+			},
+			Conflict{Kind: ConflictBookmarkMoved, Path: path, URL: base.bookmark.URL},
+		)
+	}
+}
+
+// build returns the merged Root Folder.
+//
+// The items of each merged Folder are ordered as in ours, followed by the
+// items from theirs and base.
+func (m *merger3) build() Folder {
+	items := make([][]ItemRef, len(m.folders))
+	separators := make([][]Separator, len(m.folders))
+
+	emittedFolders := make([]bool, len(m.folders))
+	emittedBookmarks := make([]bool, len(m.bookmarks))
+
+	for i, f := range m.folders {
+		if f.removed {
+			continue
+		}
+
+		first := true
+
+		for _, side := range []Side{SideOurs, SideTheirs, SideBase} {
+			j := f.sides[side]
+			if j < 0 {
+				continue
+			}
+
+			folder := m.trees[side].folders[j].folder
+
+			for _, ref := range folder.orderedItems() {
+				switch ref.Kind {
+				case BookmarkKind:
+					index := m.bookmarkIndex[side][m.bookmarkPtrs[side][&folder.Bookmarks[ref.Index]]]
+					if emittedBookmarks[index] || m.bookmarks[index].removed || m.bookmarks[index].folder != i {
+						continue
+					}
+
+					emittedBookmarks[index] = true
+					items[i] = append(items[i], ItemRef{Kind: BookmarkKind, Index: index})
+
+				case FolderKind:
+					index := m.folderIndex[side][m.folderPtrs[side][&folder.Subfolders[ref.Index]]]
+					if emittedFolders[index] || m.folders[index].removed || m.folders[index].parent != i {
+						continue
+					}
+
+					emittedFolders[index] = true
+					items[i] = append(items[i], ItemRef{Kind: FolderKind, Index: index})
+
+				case SeparatorKind:
+					if !first {
+						continue
+					}
+
+					separators[i] = append(separators[i], Separator{Attributes: maps.Clone(folder.Separators[ref.Index].Attributes)})
+					items[i] = append(items[i], ItemRef{Kind: SeparatorKind, Index: len(separators[i]) - 1})
+				}
+			}
+
+			first = false
+		}
+	}
+
+	root := newFolderBuilder(m.folders[0].header)
+	m.buildFolder(root, 0, items, separators)
+
+	return root.build()
+}
+
+// buildFolder adds the items of the merged Folder i to b; subfolders with the
+// same name are merged.
+func (m *merger3) buildFolder(b *folderBuilder, i int, items [][]ItemRef, separators [][]Separator) {
+	for _, ref := range items[i] {
+		switch ref.Kind {
+		case BookmarkKind:
+			b.addBookmark(m.bookmarks[ref.Index].bookmark)
+		case FolderKind:
+			subfolder, _ := b.subfolder(m.folders[ref.Index].header)
+			m.buildFolder(subfolder, ref.Index, items, separators)
+		case SeparatorKind:
+			b.addSeparator(separators[i][ref.Index])
+		}
+	}
+}
+
+// cloneHeader returns a copy of the attributes of folder, without items.
+func cloneHeader(folder *Folder) Folder {
+	header := folder.header()
+	header.Attributes = maps.Clone(header.Attributes)
+
+	return header
+}
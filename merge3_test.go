@@ -0,0 +1,369 @@
+// Copyright (c) VirtualTam
+// SPDX-License-Identifier: MIT
+
+package netscape
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMerge3(t *testing.T) {
+	base := loadTestDocument(t, "merge3_base.htm")
+
+	ours := loadTestDocument(t, "merge3_base.htm")
+	ours.Root.Subfolders[0].Bookmarks[0].Title = "The Go Programming Language"
+	ours.Root.Subfolders[0].addBookmark(Bookmark{Title: "Ours", URL: "https://ours.domain.tld"})
+	ours.Root.addBookmark(Bookmark{Title: "Both", URL: "https://both.domain.tld"})
+	if err := ours.MoveBookmark(Path{"Dev"}, "https://pkg.go.dev", Path{"Dev", "Tools"}); err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+	if err := ours.RemoveFolder(Path{"News"}); err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+
+	theirs := loadTestDocument(t, "merge3_base.htm")
+	theirs.Title = "My Bookmarks"
+	theirs.Root.Subfolders[0].Bookmarks[0].Tags = []string{"go", "golang"}
+	theirs.Root.Subfolders[0].Description = "Software development"
+	theirs.Root.Subfolders[2].Name = "Attic"
+	theirs.Root.addBookmark(Bookmark{Title: "Theirs", URL: "https://theirs.domain.tld"})
+	theirs.Root.addBookmark(Bookmark{Title: "Both", URL: "https://both.domain.tld"})
+
+	want := &Document{
+		Title: "My Bookmarks",
+		Root: Folder{
+			Name: "Bookmarks",
+			Bookmarks: []Bookmark{
+				{Title: "Root", URL: "https://root.domain.tld"},
+				{Title: "Both", URL: "https://both.domain.tld"},
+				{Title: "Theirs", URL: "https://theirs.domain.tld"},
+			},
+			Subfolders: []Folder{
+				{
+					CreatedAt:   time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+					UpdatedAt:   time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+					Name:        "Dev",
+					Description: "Software development",
+					Bookmarks: []Bookmark{
+						{Title: "The Go Programming Language", URL: "https://go.dev", Tags: []string{"go", "golang"}},
+						{Title: "Ours", URL: "https://ours.domain.tld"},
+					},
+					Subfolders: []Folder{
+						{
+							Name: "Tools",
+							Bookmarks: []Bookmark{
+								{Title: "Staticcheck", URL: "https://staticcheck.dev"},
+								{Title: "Packages", URL: "https://pkg.go.dev"},
+							},
+						},
+					},
+				},
+				{
+					Name: "Attic",
+					Bookmarks: []Bookmark{
+						{Title: "Old", URL: "https://old.domain.tld"},
+					},
+				},
+			},
+		},
+	}
+
+	got, conflicts := Merge3(base, ours, theirs)
+
+	if len(conflicts) != 0 {
+		t.Errorf("want no conflicts, got %v", conflicts)
+	}
+
+	if changeset := Diff(got, want); changeset.Len() != 0 {
+		t.Errorf("want no changes, got:\n%s", changeset.String())
+	}
+
+	if urls := folderURLs(&got.Root); len(urls) != 3 {
+		t.Errorf("want 3 bookmarks in the root folder, got %q", urls)
+	}
+
+	// the edited Documents are left unchanged
+	if changeset := Diff(base, loadTestDocument(t, "merge3_base.htm")); changeset.Len() != 0 {
+		t.Errorf("want base unchanged, got:\n%s", changeset.String())
+	}
+}
+
+func TestMerge3Conflicts(t *testing.T) {
+	cases := []struct {
+		tname  string
+		ours   func(t *testing.T, d *Document)
+		theirs func(t *testing.T, d *Document)
+
+		want Conflict
+
+		// check reports whether the merged Document holds the version of
+		// ours (ResolveOurs) or of theirs (ResolveTheirs)
+		check func(d *Document, resolution Side) bool
+	}{
+		{
+			tname: "title edited",
+			ours:  func(_ *testing.T, d *Document) { d.Title = "Ours" },
+			theirs: func(_ *testing.T, d *Document) {
+				d.Title = "Theirs"
+			},
+			want: Conflict{Kind: ConflictTitleEdited, Field: "title", Base: "Bookmarks", Ours: "Ours", Theirs: "Theirs"},
+			check: func(d *Document, resolution Side) bool {
+				return d.Title == map[Side]string{SideOurs: "Ours", SideTheirs: "Theirs"}[resolution]
+			},
+		},
+		{
+			tname: "bookmark edited",
+			ours: func(_ *testing.T, d *Document) {
+				d.Root.Subfolders[0].Bookmarks[0].Title = "Go!"
+			},
+			theirs: func(_ *testing.T, d *Document) {
+				d.Root.Subfolders[0].Bookmarks[0].Title = "Golang"
+				d.Root.Subfolders[0].Bookmarks[0].Private = true
+			},
+			want: Conflict{
+				Kind:   ConflictBookmarkEdited,
+				Path:   Path{"Dev"},
+				URL:    "https://go.dev",
+				Field:  "title",
+				Base:   "Go",
+				Ours:   "Go!",
+				Theirs: "Golang",
+			},
+			check: func(d *Document, resolution Side) bool {
+				bookmark := d.Root.Subfolders[0].Bookmarks[0]
+				want := map[Side]string{SideOurs: "Go!", SideTheirs: "Golang"}[resolution]
+
+				return bookmark.Title == want && bookmark.Private
+			},
+		},
+		{
+			tname: "bookmark added on both sides",
+			ours: func(_ *testing.T, d *Document) {
+				d.Root.addBookmark(Bookmark{Title: "Ours", URL: "https://both.domain.tld"})
+			},
+			theirs: func(_ *testing.T, d *Document) {
+				d.Root.addBookmark(Bookmark{Title: "Theirs", URL: "https://both.domain.tld"})
+			},
+			want: Conflict{
+				Kind:   ConflictBookmarkEdited,
+				Path:   Path{},
+				URL:    "https://both.domain.tld",
+				Field:  "title",
+				Ours:   "Ours",
+				Theirs: "Theirs",
+			},
+			check: func(d *Document, resolution Side) bool {
+				want := map[Side]string{SideOurs: "Ours", SideTheirs: "Theirs"}[resolution]
+
+				return len(d.Root.Bookmarks) == 2 && d.Root.Bookmarks[1].Title == want
+			},
+		},
+		{
+			tname: "bookmark deleted and modified",
+			ours: func(_ *testing.T, d *Document) {
+				d.Root.Subfolders[0].removeBookmark(1)
+			},
+			theirs: func(_ *testing.T, d *Document) {
+				d.Root.Subfolders[0].Bookmarks[1].Title = "Go Packages"
+			},
+			want: Conflict{
+				Kind:    ConflictBookmarkDeleted,
+				Path:    Path{"Dev"},
+				URL:     "https://pkg.go.dev",
+				Deleted: SideOurs,
+			},
+			check: func(d *Document, resolution Side) bool {
+				folder := &d.Root.Subfolders[0]
+
+				if resolution == SideOurs {
+					return folder.bookmarkIndex("https://pkg.go.dev") < 0
+				}
+
+				index := folder.bookmarkIndex("https://pkg.go.dev")
+
+				return index >= 0 && folder.Bookmarks[index].Title == "Go Packages"
+			},
+		},
+		{
+			tname: "bookmark moved on both sides",
+			ours: func(t *testing.T, d *Document) {
+				if err := d.MoveBookmark(Path{"Dev"}, "https://pkg.go.dev", Path{"Dev", "Tools"}); err != nil {
+					t.Fatalf("expected no error, got %q", err)
+				}
+			},
+			theirs: func(t *testing.T, d *Document) {
+				if err := d.MoveBookmark(Path{"Dev"}, "https://pkg.go.dev", Path{}); err != nil {
+					t.Fatalf("expected no error, got %q", err)
+				}
+			},
+			want: Conflict{
+				Kind:   ConflictBookmarkMoved,
+				Path:   Path{"Dev"},
+				URL:    "https://pkg.go.dev",
+				Base:   "/Dev",
+				Ours:   "/Dev/Tools",
+				Theirs: "/",
+			},
+			check: func(d *Document, resolution Side) bool {
+				inTools := d.Root.Subfolders[0].Subfolders[0].bookmarkIndex("https://pkg.go.dev") >= 0
+				inRoot := d.Root.bookmarkIndex("https://pkg.go.dev") >= 0
+
+				return inTools == (resolution == SideOurs) && inRoot == (resolution == SideTheirs)
+			},
+		},
+		{
+			tname: "folder edited",
+			ours: func(_ *testing.T, d *Document) {
+				d.Root.Subfolders[0].Name = "Development"
+			},
+			theirs: func(_ *testing.T, d *Document) {
+				d.Root.Subfolders[0].Name = "Programming"
+			},
+			want: Conflict{
+				Kind:   ConflictFolderEdited,
+				Path:   Path{"Dev"},
+				Field:  "name",
+				Base:   "Dev",
+				Ours:   "Development",
+				Theirs: "Programming",
+			},
+			check: func(d *Document, resolution Side) bool {
+				want := map[Side]string{SideOurs: "Development", SideTheirs: "Programming"}[resolution]
+
+				return d.Root.Subfolders[0].Name == want && len(d.Root.Subfolders[0].Bookmarks) == 2
+			},
+		},
+		{
+			tname: "folder moved on both sides",
+			ours: func(t *testing.T, d *Document) {
+				if err := d.Move(Path{"Dev", "Tools"}, Path{}); err != nil {
+					t.Fatalf("expected no error, got %q", err)
+				}
+			},
+			theirs: func(t *testing.T, d *Document) {
+				if err := d.Move(Path{"Dev", "Tools"}, Path{"Archive"}); err != nil {
+					t.Fatalf("expected no error, got %q", err)
+				}
+			},
+			want: Conflict{
+				Kind:   ConflictFolderMoved,
+				Path:   Path{"Dev", "Tools"},
+				Base:   "/Dev",
+				Ours:   "/",
+				Theirs: "/Archive",
+			},
+			check: func(d *Document, resolution Side) bool {
+				want := map[Side]Path{SideOurs: {"Tools"}, SideTheirs: {"Archive", "Tools"}}[resolution]
+
+				folder, err := d.FindFolder(want)
+
+				return err == nil && len(folder.Bookmarks) == 1
+			},
+		},
+		{
+			tname: "folder deleted and modified",
+			ours: func(t *testing.T, d *Document) {
+				if err := d.RemoveFolder(Path{"Archive"}); err != nil {
+					t.Fatalf("expected no error, got %q", err)
+				}
+			},
+			theirs: func(_ *testing.T, d *Document) {
+				d.Root.Subfolders[2].addBookmark(Bookmark{Title: "Older", URL: "https://older.domain.tld"})
+			},
+			want: Conflict{
+				Kind:    ConflictFolderDeleted,
+				Path:    Path{"Archive"},
+				Deleted: SideOurs,
+			},
+			check: func(d *Document, resolution Side) bool {
+				folder, err := d.FindFolder(Path{"Archive"})
+
+				if resolution == SideOurs {
+					return err != nil
+				}
+
+				return err == nil && len(folder.Bookmarks) == 2
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.tname, func(t *testing.T) {
+			for _, resolver := range []ConflictResolver{ResolveOurs, ResolveTheirs} {
+				base := loadTestDocument(t, "merge3_base.htm")
+
+				ours := loadTestDocument(t, "merge3_base.htm")
+				tc.ours(t, ours)
+
+				theirs := loadTestDocument(t, "merge3_base.htm")
+				tc.theirs(t, theirs)
+
+				got, conflicts := Merge3(base, ours, theirs, WithConflictResolver(resolver))
+
+				if len(conflicts) != 1 {
+					t.Fatalf("want 1 conflict, got %d: %v", len(conflicts), conflicts)
+				}
+
+				want := tc.want
+				want.Resolution = resolver(want)
+
+				if got := conflicts[0]; got.String() != want.String() || got.Field != want.Field || got.Base != want.Base {
+					t.Errorf("want conflict %q, got %q", want, got)
+				}
+
+				if !tc.check(got, want.Resolution) {
+					t.Errorf("want merged document with the version of %s", want.Resolution)
+				}
+			}
+		})
+	}
+}
+
+func TestMerge3FoldersMovedIntoEachOther(t *testing.T) {
+	base := loadTestDocument(t, "merge3_base.htm")
+
+	ours := loadTestDocument(t, "merge3_base.htm")
+	if err := ours.Move(Path{"Archive"}, Path{"News"}); err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+
+	theirs := loadTestDocument(t, "merge3_base.htm")
+	if err := theirs.Move(Path{"News"}, Path{"Archive"}); err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+
+	got, conflicts := Merge3(base, ours, theirs)
+
+	if len(conflicts) != 0 {
+		t.Errorf("want no conflicts, got %v", conflicts)
+	}
+
+	var names []string
+	for path := range got.All() {
+		names = appendMissing(names, FormatPath(path))
+	}
+
+	if len(names) != 5 {
+		t.Errorf("want bookmarks in 5 folders, got %q", names)
+	}
+}
+
+func TestConflictString(t *testing.T) {
+	conflict := Conflict{
+		Kind:       ConflictBookmarkEdited,
+		Path:       Path{"Dev"},
+		URL:        "https://go.dev",
+		Field:      "title",
+		Base:       "Go",
+		Ours:       "Go!",
+		Theirs:     "Golang",
+		Resolution: SideTheirs,
+	}
+
+	want := `bookmark https://go.dev in "/Dev": title: "Go" -> "Go!" (ours) and "Golang" (theirs), kept theirs`
+
+	if got := conflict.String(); got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
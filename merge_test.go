@@ -0,0 +1,388 @@
+// Copyright (c) VirtualTam
+// SPDX-License-Identifier: MIT
+
+package netscape
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMerge(t *testing.T) {
+	cases := []struct {
+		tname         string
+		opts          MergeOptions
+		want          Folder
+		wantDecisions []string
+	}{
+		{
+			tname: "keep first",
+			want: Folder{
+				Name: "Bookmarks Menu",
+				Bookmarks: []Bookmark{
+					{URL: "https://root.domain.tld"},
+				},
+				Separators: []Separator{{}},
+				Subfolders: []Folder{
+					{
+						Name: "Dev",
+						Bookmarks: []Bookmark{
+							{
+								UpdatedAt:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+								Title:       "Go",
+								URL:         "https://go.dev",
+								Description: "The Go language",
+								Tags:        []string{"golang"},
+							},
+							{
+								UpdatedAt:   time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+								Title:       "The Go Programming Language",
+								URL:         "https://GO.dev",
+								Description: "Go",
+								Tags:        []string{"go", "golang"},
+							},
+							{URL: "https://pkg.go.dev"},
+						},
+						Subfolders: []Folder{
+							{Name: "Tools"},
+						},
+					},
+					{Name: "News"},
+				},
+			},
+			wantDecisions: []string{
+				`source 0: bookmark added: https://root.domain.tld in ""`,
+				`source 0: folder added: "Dev"`,
+				`source 0: bookmark added: https://go.dev in "Dev"`,
+				`source 1: folder merged: "Dev"`,
+				`source 1: bookmark added: https://GO.dev in "Dev"`,
+				`source 1: bookmark added: https://pkg.go.dev in "Dev"`,
+				`source 1: folder added: "Dev/Tools"`,
+				`source 1: folder added: "News"`,
+			},
+		},
+		{
+			tname: "normalized URL, keep first and merge tags",
+			opts: MergeOptions{
+				UnionTags:    true,
+				NormalizeURL: strings.ToLower,
+			},
+			want: Folder{
+				Name: "Bookmarks Menu",
+				Bookmarks: []Bookmark{
+					{URL: "https://root.domain.tld"},
+				},
+				Separators: []Separator{{}},
+				Subfolders: []Folder{
+					{
+						Name: "Dev",
+						Bookmarks: []Bookmark{
+							{
+								UpdatedAt:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+								Title:       "Go",
+								URL:         "https://go.dev",
+								Description: "The Go language",
+								Tags:        []string{"golang", "go"},
+							},
+							{URL: "https://pkg.go.dev"},
+						},
+						Subfolders: []Folder{
+							{Name: "Tools"},
+						},
+					},
+					{Name: "News"},
+				},
+			},
+			wantDecisions: []string{
+				`source 0: bookmark added: https://root.domain.tld in ""`,
+				`source 0: folder added: "Dev"`,
+				`source 0: bookmark added: https://go.dev in "Dev"`,
+				`source 1: folder merged: "Dev"`,
+				`source 1: bookmark kept: https://go.dev in "Dev": kept bookmark from source 0 (first found); merged tags`,
+				`source 1: bookmark added: https://pkg.go.dev in "Dev"`,
+				`source 1: folder added: "Dev/Tools"`,
+				`source 1: folder added: "News"`,
+			},
+		},
+		{
+			tname: "newest with longest description",
+			opts: MergeOptions{
+				Policy:             MergeNewest,
+				LongestDescription: true,
+				NormalizeURL:       strings.ToLower,
+			},
+			want: Folder{
+				Name: "Bookmarks Menu",
+				Bookmarks: []Bookmark{
+					{URL: "https://root.domain.tld"},
+				},
+				Separators: []Separator{{}},
+				Subfolders: []Folder{
+					{
+						Name: "Dev",
+						Bookmarks: []Bookmark{
+							{
+								UpdatedAt:   time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+								Title:       "The Go Programming Language",
+								URL:         "https://GO.dev",
+								Description: "The Go language",
+								Tags:        []string{"go", "golang"},
+							},
+							{URL: "https://pkg.go.dev"},
+						},
+						Subfolders: []Folder{
+							{Name: "Tools"},
+						},
+					},
+					{Name: "News"},
+				},
+			},
+			wantDecisions: []string{
+				`source 0: bookmark added: https://root.domain.tld in ""`,
+				`source 0: folder added: "Dev"`,
+				`source 0: bookmark added: https://go.dev in "Dev"`,
+				`source 1: folder merged: "Dev"`,
+				`source 1: bookmark replaced: https://GO.dev in "Dev": kept bookmark from source 1 (newest); kept longest description`,
+				`source 1: bookmark added: https://pkg.go.dev in "Dev"`,
+				`source 1: folder added: "Dev/Tools"`,
+				`source 1: folder added: "News"`,
+			},
+		},
+		{
+			tname: "prefer source",
+			opts: MergeOptions{
+				Policy:          MergePreferSource,
+				PreferredSource: 0,
+				NormalizeURL:    strings.ToLower,
+			},
+			want: Folder{
+				Name: "Bookmarks Menu",
+				Bookmarks: []Bookmark{
+					{URL: "https://root.domain.tld"},
+				},
+				Separators: []Separator{{}},
+				Subfolders: []Folder{
+					{
+						Name: "Dev",
+						Bookmarks: []Bookmark{
+							{
+								UpdatedAt:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+								Title:       "Go",
+								URL:         "https://go.dev",
+								Description: "The Go language",
+								Tags:        []string{"golang"},
+							},
+							{URL: "https://pkg.go.dev"},
+						},
+						Subfolders: []Folder{
+							{Name: "Tools"},
+						},
+					},
+					{Name: "News"},
+				},
+			},
+			wantDecisions: []string{
+				`source 0: bookmark added: https://root.domain.tld in ""`,
+				`source 0: folder added: "Dev"`,
+				`source 0: bookmark added: https://go.dev in "Dev"`,
+				`source 1: folder merged: "Dev"`,
+				`source 1: bookmark kept: https://go.dev in "Dev": kept bookmark from source 0 (preferred source)`,
+				`source 1: bookmark added: https://pkg.go.dev in "Dev"`,
+				`source 1: folder added: "Dev/Tools"`,
+				`source 1: folder added: "News"`,
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.tname, func(t *testing.T) {
+			docs := []*Document{
+				loadTestDocument(t, "merge_firefox.htm"),
+				loadTestDocument(t, "merge_chrome.htm"),
+			}
+
+			got, report := Merge(tc.opts, docs...)
+
+			if got.Title != "Firefox" {
+				t.Errorf("want title %q, got %q", "Firefox", got.Title)
+			}
+
+			assertFoldersEqual(t, got.Root, tc.want)
+
+			var gotDecisions []string
+			for _, decision := range report.Decisions {
+				gotDecisions = append(gotDecisions, decision.String())
+			}
+
+			if !slices.Equal(gotDecisions, tc.wantDecisions) {
+				t.Errorf("want decisions:\n%s\ngot:\n%s", strings.Join(tc.wantDecisions, "\n"), strings.Join(gotDecisions, "\n"))
+			}
+
+			// source documents are not modified
+			assertFoldersEqual(t, docs[0].Root, loadTestDocument(t, "merge_firefox.htm").Root)
+			assertFoldersEqual(t, docs[1].Root, loadTestDocument(t, "merge_chrome.htm").Root)
+		})
+	}
+}
+
+func TestMergePreferLaterSource(t *testing.T) {
+	docs := []*Document{
+		loadTestDocument(t, "merge_firefox.htm"),
+		loadTestDocument(t, "merge_chrome.htm"),
+	}
+
+	opts := MergeOptions{
+		Policy:          MergePreferSource,
+		PreferredSource: 3,
+		NormalizeURL:    strings.ToLower,
+	}
+
+	got, report := Merge(opts, docs[0], nil, docs[0], docs[1])
+
+	dev, err := got.FindFolder(Path{"Dev"})
+	if err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+
+	want := []Bookmark{
+		{
+			UpdatedAt:   time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			Title:       "The Go Programming Language",
+			URL:         "https://GO.dev",
+			Description: "Go",
+			Tags:        []string{"go", "golang"},
+		},
+		{URL: "https://pkg.go.dev"},
+	}
+
+	if len(dev.Bookmarks) != len(want) {
+		t.Fatalf("want %d bookmarks, got %d", len(want), len(dev.Bookmarks))
+	}
+
+	for i := range want {
+		assertBookmarksEqual(t, dev.Bookmarks[i], want[i])
+	}
+
+	// the nil document is skipped, and the same document merged twice
+	if len(got.Root.Separators) != 1 {
+		t.Errorf("want 1 separator, got %d", len(got.Root.Separators))
+	}
+
+	var kept, replaced int
+	for _, decision := range report.Decisions {
+		switch decision.Action {
+		case MergeBookmarkKept:
+			if decision.Source != 2 {
+				t.Errorf("want kept bookmarks from source 2, got %d", decision.Source)
+			}
+			kept++
+		case MergeBookmarkReplaced:
+			if decision.Source != 3 {
+				t.Errorf("want replaced bookmarks from source 3, got %d", decision.Source)
+			}
+			replaced++
+		}
+	}
+
+	if kept != 2 || replaced != 1 {
+		t.Errorf("want 2 kept and 1 replaced bookmarks, got %d and %d", kept, replaced)
+	}
+}
+
+func TestMergeNoDocuments(t *testing.T) {
+	got, report := Merge(MergeOptions{})
+
+	if got == nil || len(got.Root.Bookmarks) != 0 || len(report.Decisions) != 0 {
+		t.Errorf("want empty document and report, got %v and %v", got, report)
+	}
+}
+
+func TestMergeDoesNotModifySources(t *testing.T) {
+	newDocument := func() *Document {
+		return &Document{
+			Root: Folder{
+				Attributes: map[string]string{"ID": "root"},
+				Bookmarks: []Bookmark{
+					{URL: "https://go.dev", Tags: []string{"go"}, Attributes: map[string]string{"ID": "1"}},
+				},
+				Separators: []Separator{{Attributes: map[string]string{"ID": "2"}}},
+				Subfolders: []Folder{
+					{Name: "Dev", Attributes: map[string]string{"ID": "3"}},
+				},
+				Order: []ItemRef{
+					{Kind: BookmarkKind, Index: 0},
+					{Kind: SeparatorKind, Index: 0},
+					{Kind: FolderKind, Index: 0},
+				},
+			},
+		}
+	}
+
+	first := newDocument()
+	second := newDocument()
+	second.Root.Bookmarks[0].UpdatedAt = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	got, _ := Merge(MergeOptions{Policy: MergeNewest}, first, second)
+
+	got.Root.Attributes["ID"] = "edited"
+	got.Root.Bookmarks[0].Tags[0] = "edited"
+	got.Root.Bookmarks[0].Attributes["ID"] = "edited"
+	got.Root.Separators[0].Attributes["ID"] = "edited"
+	got.Root.Subfolders[0].Attributes["ID"] = "edited"
+
+	for i, doc := range []*Document{first, second} {
+		want := newDocument()
+		want.Root.Bookmarks[0].UpdatedAt = doc.Root.Bookmarks[0].UpdatedAt
+
+		if changeset := Diff(want, doc); changeset.Len() != 0 {
+			t.Errorf("want source %d to be unchanged, got:\n%s", i, changeset)
+		}
+
+		if !slices.Equal(doc.Root.Bookmarks[0].Tags, []string{"go"}) {
+			t.Errorf("want source %d tags %q, got %q", i, []string{"go"}, doc.Root.Bookmarks[0].Tags)
+		}
+
+		if id := doc.Root.Separators[0].Attributes["ID"]; id != "2" {
+			t.Errorf("want source %d separator ID %q, got %q", i, "2", id)
+		}
+	}
+}
+
+func TestMergeMatchByID(t *testing.T) {
+	first := &Document{
+		Root: Folder{
+			Bookmarks: []Bookmark{
+				{Title: "Go", URL: "https://golang.org", Attributes: map[string]string{"ID": "1"}},
+				{Title: "Packages", URL: "https://pkg.go.dev"},
+			},
+		},
+	}
+
+	second := &Document{
+		Root: Folder{
+			Bookmarks: []Bookmark{
+				{Title: "The Go Programming Language", URL: "https://go.dev", Attributes: map[string]string{"ID": "1"}},
+				{Title: "Go Packages", URL: "https://PKG.go.dev/"},
+			},
+		},
+	}
+
+	got, _ := Merge(MergeOptions{MatchByID: true, UnionTags: true}, first, second)
+
+	// Bookmarks without ID attribute are matched by normalized URL and
+	// creation date
+	want := []Bookmark{
+		{Title: "Go", URL: "https://golang.org", Attributes: map[string]string{"ID": "1"}},
+		{Title: "Packages", URL: "https://pkg.go.dev"},
+	}
+
+	if len(got.Root.Bookmarks) != len(want) {
+		t.Fatalf("want %d bookmarks, got %d", len(want), len(got.Root.Bookmarks))
+	}
+
+	for i := range want {
+		assertBookmarksEqual(t, got.Root.Bookmarks[i], want[i])
+	}
+}
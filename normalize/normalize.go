@@ -0,0 +1,318 @@
+// Copyright (c) VirtualTam
+// SPDX-License-Identifier: MIT
+
+// Package normalize provides URL normalization, to compare the URLs of
+// bookmarks that point to the same resource, e.g. "HTTP://Example.com:80/"
+// and "http://example.com".
+package normalize
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+var (
+	ErrURLInvalid = errors.New("invalid URL")
+)
+
+// A Rule is a normalization step applied to URLs; Rules can be combined with
+// the | operator.
+type Rule uint
+
+const (
+	// LowercaseScheme converts the scheme to lower case.
+	LowercaseScheme Rule = 1 << iota
+
+	// LowercaseHost converts the host to lower case.
+	LowercaseHost
+
+	// RemoveDefaultPort removes the port when it is the default port for the
+	// scheme, e.g. 443 for "https".
+	RemoveDefaultPort
+
+	// RemoveTrailingSlash removes the trailing slash of the path, e.g.
+	// "https://example.com/docs/" becomes "https://example.com/docs".
+	RemoveTrailingSlash
+
+	// RemoveFragment removes the fragment.
+	RemoveFragment
+
+	// SortQuery sorts query parameters by name, keeping the order of the
+	// values of a parameter.
+	SortQuery
+
+	// RemoveTrackingParams removes query parameters used to track visitors,
+	// such as "utm_source" or "fbclid".
+	RemoveTrackingParams
+
+	// PunycodeHost converts internationalized domain names to their ASCII
+	// form, following the UTS #46 mapping for lookups, which also converts
+	// them to lower case, e.g. "Bücher.example" becomes
+	// "xn--bcher-kva.example".
+	PunycodeHost
+
+	// UpgradeScheme replaces the "http" scheme with "https".
+	UpgradeScheme
+
+	// DefaultRules are the Rules applied by default, that keep URLs pointing
+	// to the same resource.
+	DefaultRules = LowercaseScheme | LowercaseHost | RemoveDefaultPort | RemoveTrailingSlash |
+		RemoveFragment | SortQuery | RemoveTrackingParams | PunycodeHost
+)
+
+var defaultPorts = map[string]string{
+	"ftp":   "21",
+	"http":  "80",
+	"https": "443",
+	"ws":    "80",
+	"wss":   "443",
+}
+
+// defaultTrackingParams lists the query parameters removed by
+// RemoveTrackingParams; names ending with "*" match any parameter starting
+// with the given prefix.
+var defaultTrackingParams = []string{
+	"utm_*",
+	"fbclid",
+	"gclid",
+	"gclsrc",
+	"dclid",
+	"msclkid",
+	"yclid",
+	"igshid",
+	"mc_cid",
+	"mc_eid",
+	"_hsenc",
+	"_hsmi",
+	"mkt_tok",
+}
+
+// A Normalizer normalizes URLs following a set of Rules.
+type Normalizer struct {
+	rules Rule
+
+	trackingParams   map[string]bool
+	trackingPrefixes []string
+}
+
+// An Option configures a Normalizer.
+type Option func(*Normalizer)
+
+// WithRules sets the Rules applied by the Normalizer.
+//
+// Defaults to DefaultRules.
+func WithRules(rules Rule) Option {
+	return func(n *Normalizer) {
+		n.rules = rules
+	}
+}
+
+// WithTrackingParams adds query parameters removed by RemoveTrackingParams;
+// names ending with "*" match any parameter starting with the given prefix.
+func WithTrackingParams(names ...string) Option {
+	return func(n *Normalizer) {
+		n.addTrackingParams(names...)
+	}
+}
+
+// New initializes and returns a new Normalizer.
+func New(opts ...Option) *Normalizer {
+	n := &Normalizer{
+		rules:          DefaultRules,
+		trackingParams: make(map[string]bool),
+	}
+
+	n.addTrackingParams(defaultTrackingParams...)
+
+	for _, opt := range opts {
+		opt(n)
+	}
+
+	return n
+}
+
+var defaultNormalizer = New()
+
+// Normalize returns the normalized form of rawURL, following DefaultRules.
+func Normalize(rawURL string) (string, error) {
+	return defaultNormalizer.Normalize(rawURL)
+}
+
+// Normalize returns the normalized form of rawURL.
+//
+// URLs without an authority, such as "mailto:" or "javascript:" URLs, only
+// have their scheme normalized.
+func (n *Normalizer) Normalize(rawURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrURLInvalid, err)
+	}
+
+	if n.has(LowercaseScheme) {
+		u.Scheme = strings.ToLower(u.Scheme)
+	}
+
+	if n.has(UpgradeScheme) && strings.EqualFold(u.Scheme, "http") {
+		u.Scheme = "https"
+	}
+
+	if u.Opaque != "" {
+		return u.String(), nil
+	}
+
+	if err := n.normalizeHost(u); err != nil {
+		return "", fmt.Errorf("%w: %q: %w", ErrURLInvalid, rawURL, err)
+	}
+
+	if n.has(RemoveTrailingSlash) {
+		if path := u.EscapedPath(); strings.HasSuffix(path, "/") {
+			u.RawPath = strings.TrimSuffix(path, "/")
+			u.Path = strings.TrimSuffix(u.Path, "/")
+		}
+	}
+
+	if n.has(RemoveTrackingParams) || n.has(SortQuery) {
+		u.RawQuery = n.normalizeQuery(u.RawQuery)
+		if u.RawQuery == "" {
+			u.ForceQuery = false
+		}
+	}
+
+	if n.has(RemoveFragment) {
+		u.Fragment = ""
+		u.RawFragment = ""
+	}
+
+	return u.String(), nil
+}
+
+func (n *Normalizer) has(rule Rule) bool {
+	return n.rules&rule != 0
+}
+
+func (n *Normalizer) addTrackingParams(names ...string) {
+	for _, name := range names {
+		if prefix, ok := strings.CutSuffix(name, "*"); ok {
+			n.trackingPrefixes = append(n.trackingPrefixes, prefix)
+			continue
+		}
+
+		n.trackingParams[name] = true
+	}
+}
+
+func (n *Normalizer) isTrackingParam(name string) bool {
+	if n.trackingParams[name] {
+		return true
+	}
+
+	for _, prefix := range n.trackingPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// normalizeHost normalizes the host and port of u.
+func (n *Normalizer) normalizeHost(u *url.URL) error {
+	if u.Host == "" {
+		return nil
+	}
+
+	hostname := u.Hostname()
+	port := u.Port()
+
+	if n.has(LowercaseHost) {
+		hostname = strings.ToLower(hostname)
+	}
+
+	if n.has(PunycodeHost) && !isASCII(hostname) {
+		ascii, err := idna.Lookup.ToASCII(hostname)
+		if err != nil {
+			return err
+		}
+
+		hostname = ascii
+	}
+
+	if n.has(RemoveDefaultPort) && port != "" && defaultPorts[strings.ToLower(u.Scheme)] == port {
+		port = ""
+	}
+
+	if strings.Contains(hostname, ":") {
+		// IPv6 address
+		hostname = "[" + hostname + "]"
+	}
+
+	u.Host = hostname
+	if port != "" {
+		u.Host += ":" + port
+	}
+
+	return nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
+
+// normalizeQuery removes tracking parameters from rawQuery and sorts the
+// remaining parameters, keeping their original encoding.
+func (n *Normalizer) normalizeQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+
+	type param struct {
+		name string
+		raw  string
+	}
+
+	var params []param
+
+	for raw := range strings.SplitSeq(rawQuery, "&") {
+		if raw == "" {
+			continue
+		}
+
+		rawName, _, _ := strings.Cut(raw, "=")
+
+		name, err := url.QueryUnescape(rawName)
+		if err != nil {
+			name = rawName
+		}
+
+		if n.has(RemoveTrackingParams) && n.isTrackingParam(name) {
+			continue
+		}
+
+		params = append(params, param{name: name, raw: raw})
+	}
+
+	if n.has(SortQuery) {
+		slices.SortStableFunc(params, func(a, b param) int {
+			return strings.Compare(a.name, b.name)
+		})
+	}
+
+	raws := make([]string, 0, len(params))
+	for _, p := range params {
+		raws = append(raws, p.raw)
+	}
+
+	return strings.Join(raws, "&")
+}
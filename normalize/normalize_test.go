@@ -0,0 +1,257 @@
+// Copyright (c) VirtualTam
+// SPDX-License-Identifier: MIT
+
+package normalize
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	cases := []struct {
+		tname   string
+		input   string
+		want    string
+		wantErr error
+	}{
+		// nominal cases
+		{
+			tname: "already normalized",
+			input: "https://example.com/docs?page=2",
+			want:  "https://example.com/docs?page=2",
+		},
+		{
+			tname: "scheme and host case",
+			input: "HTTP://Example.COM/Docs",
+			want:  "http://example.com/Docs",
+		},
+		{
+			tname: "default port",
+			input: "https://example.com:443/",
+			want:  "https://example.com",
+		},
+		{
+			tname: "non-default port",
+			input: "https://example.com:8443/",
+			want:  "https://example.com:8443",
+		},
+		{
+			tname: "IPv6 host with default port",
+			input: "http://[::1]:80/status/",
+			want:  "http://[::1]/status",
+		},
+		{
+			tname: "trailing slash",
+			input: "https://example.com/docs/",
+			want:  "https://example.com/docs",
+		},
+		{
+			tname: "escaped path",
+			input: "https://example.com/a%2Fb/",
+			want:  "https://example.com/a%2Fb",
+		},
+		{
+			tname: "fragment",
+			input: "https://example.com/docs#section",
+			want:  "https://example.com/docs",
+		},
+		{
+			tname: "sorted query",
+			input: "https://example.com/search?q=go&lang=en&q=dev",
+			want:  "https://example.com/search?lang=en&q=go&q=dev",
+		},
+		{
+			tname: "tracking parameters",
+			input: "https://example.com/?utm_source=x&utm_medium=email&id=1&fbclid=abc&gclid=def",
+			want:  "https://example.com?id=1",
+		},
+		{
+			tname: "only tracking parameters",
+			input: "https://example.com/?utm_source=x",
+			want:  "https://example.com",
+		},
+		{
+			tname: "internationalized domain name",
+			input: "https://Bücher.example/",
+			want:  "https://xn--bcher-kva.example",
+		},
+		{
+			tname: "non-ASCII domain name",
+			input: "https://日本語.jp/",
+			want:  "https://xn--wgv71a119e.jp",
+		},
+		{
+			tname: "opaque URL",
+			input: "JavaScript:alert('Hello')",
+			want:  "javascript:alert('Hello')",
+		},
+		{
+			tname: "user info",
+			input: "ftp://user@FTP.example.com:21/pub/",
+			want:  "ftp://user@ftp.example.com/pub",
+		},
+
+		// error cases
+		{
+			tname:   "invalid URL",
+			input:   "https://example.com:port/",
+			wantErr: ErrURLInvalid,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.tname, func(t *testing.T) {
+			got, err := Normalize(tc.input)
+
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("want error %q, got %q", tc.wantErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected no error, got %q", err)
+			}
+
+			if got != tc.want {
+				t.Errorf("want %q, got %q", tc.want, got)
+			}
+
+			// normalization is idempotent
+			again, err := Normalize(got)
+			if err != nil {
+				t.Fatalf("expected no error, got %q", err)
+			}
+
+			if again != got {
+				t.Errorf("want %q once normalized again, got %q", got, again)
+			}
+		})
+	}
+}
+
+func TestNormalizerOptions(t *testing.T) {
+	cases := []struct {
+		tname string
+		opts  []Option
+		input string
+		want  string
+	}{
+		{
+			tname: "no rules",
+			opts:  []Option{WithRules(0)},
+			input: "https://Example.com:443/docs/?utm_source=x#top",
+			want:  "https://Example.com:443/docs/?utm_source=x#top",
+		},
+		{
+			tname: "upgrade scheme",
+			opts:  []Option{WithRules(DefaultRules | UpgradeScheme)},
+			input: "HTTP://Example.com/",
+			want:  "https://example.com",
+		},
+		{
+			tname: "selected rules",
+			opts:  []Option{WithRules(LowercaseHost | RemoveFragment)},
+			input: "https://Example.com/docs/?b=2&a=1#top",
+			want:  "https://example.com/docs/?b=2&a=1",
+		},
+		{
+			tname: "additional tracking parameters",
+			opts:  []Option{WithTrackingParams("ref", "pk_*")},
+			input: "https://example.com/?ref=home&pk_campaign=x&id=1&utm_term=go",
+			want:  "https://example.com?id=1",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.tname, func(t *testing.T) {
+			n := New(tc.opts...)
+
+			got, err := n.Normalize(tc.input)
+			if err != nil {
+				t.Fatalf("expected no error, got %q", err)
+			}
+
+			if got != tc.want {
+				t.Errorf("want %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestNormalizerPunycodeHost(t *testing.T) {
+	cases := []struct {
+		tname   string
+		input   string
+		want    string
+		wantErr error
+	}{
+		{
+			tname: "ASCII host",
+			input: "https://Example.com/",
+			want:  "https://Example.com/",
+		},
+		{
+			tname: "mixed-case label",
+			input: "https://Bücher.example/",
+			want:  "https://xn--bcher-kva.example/",
+		},
+		{
+			tname: "upper case label",
+			input: "https://MÜNCHEN.de/",
+			want:  "https://xn--mnchen-3ya.de/",
+		},
+		{
+			tname: "deviation character",
+			input: "https://faß.de/",
+			want:  "https://xn--fa-hia.de/",
+		},
+		{
+			tname: "full-width characters",
+			input: "https://ＡＢＣ.example/",
+			want:  "https://abc.example/",
+		},
+		{
+			tname: "CJK labels",
+			input: "https://中国.日本語.JP/",
+			want:  "https://xn--fiqs8s.xn--wgv71a119e.jp/",
+		},
+
+		// error cases
+		{
+			tname:   "leading hyphen",
+			input:   "https://-bücher.example/",
+			wantErr: ErrURLInvalid,
+		},
+		{
+			tname:   "disallowed character",
+			input:   "https://bücher_club.example/",
+			wantErr: ErrURLInvalid,
+		},
+	}
+
+	n := New(WithRules(PunycodeHost))
+
+	for _, tc := range cases {
+		t.Run(tc.tname, func(t *testing.T) {
+			got, err := n.Normalize(tc.input)
+
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("want error %q, got %q", tc.wantErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected no error, got %q", err)
+			}
+
+			if got != tc.want {
+				t.Errorf("want %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"unicode"
 )
 
 var (
@@ -29,6 +30,14 @@ type ParseError struct {
 	// Position in the input where the error was raised.
 	Pos int64
 
+	// Line and column in the input where the error was raised.
+	Line   int
+	Column int
+
+	// Excerpt of the input where the error was raised, usually the
+	// corresponding line.
+	Context string
+
 	// Initial error raised while parsing the input.
 	Err error
 }
@@ -43,7 +52,11 @@ func newParseError(msg string, pos int64, inner error) error {
 
 // Error returns the string representation for this error.
 func (e *ParseError) Error() string {
-	return fmt.Sprintf("%s at position %d: %s", e.Msg, e.Pos, e.Err)
+	if e.Line == 0 {
+		return fmt.Sprintf("%s at position %d: %s", e.Msg, e.Pos, e.Err)
+	}
+
+	return fmt.Sprintf("%s at position %d (line %d, column %d): %s", e.Msg, e.Pos, e.Line, e.Column, e.Err)
 }
 
 // Is compares this Error with a target error to satisfy an equality check.
@@ -61,57 +74,393 @@ func (e *ParseError) Unwrap() error {
 	return e.Err
 }
 
+// ParseOptions configures how a Netscape Bookmark document is parsed.
+type ParseOptions struct {
+	// Mode controls how malformed constructs are handled.
+	//
+	// In Lenient mode, empty folder titles are replaced with a placeholder,
+	// orphan </DL> elements are skipped, a root folder is created when the
+	// <H1> element is missing, and parsing stops without error on XML syntax
+	// errors.
+	Mode Mode
+}
+
 // Parse reads a Netscape Bookmark document and processes it token by token to
 // build and return the corresponding AST.
+//
+// Parse is equivalent to ParseReader, and is kept for backward compatibility.
 func Parse(rs io.ReadSeeker) (*FileNode, error) {
-	p := newParser(rs)
+	return ParseReader(rs)
+}
+
+// ParseReader reads a Netscape Bookmark document from r and processes it token
+// by token to build and return the corresponding AST.
+//
+// As opposed to Parse, r does not need to implement io.Seeker, which allows
+// parsing data from HTTP request bodies, pipes or compressed streams without
+// buffering the whole document in memory.
+func ParseReader(r io.Reader) (*FileNode, error) {
+	p := newParser(r, ParseOptions{})
 	return p.parse()
 }
 
+// ParseWithOptions reads a Netscape Bookmark document from r and processes it
+// token by token to build and return the corresponding AST.
+//
+// In Lenient mode, the problems found in the document are returned as
+// Diagnostics, and an error is only returned if no AST could be built.
+func ParseWithOptions(r io.Reader, opts ParseOptions) (*FileNode, []Diagnostic, error) {
+	p := newParser(r, opts)
+
+	file, err := p.parse()
+	if err != nil {
+		return &FileNode{}, p.diagnostics, err
+	}
+
+	return file, p.diagnostics, nil
+}
+
+const (
+	// untitledFolderName is the name given to folders with an empty title
+	// in Lenient mode.
+	untitledFolderName string = "Untitled"
+)
+
 type parser struct {
-	rs          io.ReadSeeker
-	decoder     *xml.Decoder
-	tokenOffset int64
+	rr      *recordingReader
+	decoder *xml.Decoder
+	mode    Mode
+
+	// token to be returned by the next call to nextToken.
+	pending xml.Token
+
+	// whether the parser stopped reading tokens after an unrecoverable
+	// error in Lenient mode.
+	stopped bool
+
+	// position of the last token processed.
+	tokenPos Position
+
+	// position of the start of the last token read.
+	tokenStart Position
+
+	// whether the XML decoder may have read the next token ahead, before
+	// returning the end element it has synthesized for an element that is
+	// not explicitly closed, and the position where this token starts.
+	readAhead      bool
+	readAheadStart Position
+
+	// position in the input where the XML decoder started reading, which
+	// changes when resynchronizing after a syntax error in Lenient mode.
+	base Position
 
 	file            *FileNode
 	currentDepth    int
 	currentFolder   *FolderNode
 	currentBookmark *BookmarkNode
 
-	descriptionBuf []byte
+	diagnostics []Diagnostic
+
+	// listener notified of each node as soon as it has been parsed, in which
+	// case nodes are released from the AST once notified.
+	listener nodeListener
+
+	// whether the current folder or bookmark has not been passed to the
+	// listener yet, as its description may follow.
+	pendingFolder   bool
+	pendingBookmark bool
+
+	// error returned by the listener, that aborts parsing.
+	listenerErr error
+}
+
+// A nodeListener is notified of AST nodes while they are being parsed.
+type nodeListener interface {
+	// folderStart is called once the header and description of folder f
+	// have been parsed.
+	folderStart(f *FolderNode) error
+
+	// folderEnd is called when the closing </DL> element of folder f is
+	// parsed.
+	folderEnd(f *FolderNode) error
+
+	// bookmark is called once bookmark b and its description have been
+	// parsed.
+	bookmark(b *BookmarkNode) error
 }
 
 // newXMLDecoder initializes and returns a xml.Decoder with strict mode disabled,
 // to handle Netscape Bookmark format quirks.
+//
+// The <DD> and <DT> elements are never closed in Netscape Bookmark documents,
+// and are closed automatically, so that the decoder does not nest elements
+// deeper with each bookmark of a folder.
 func newXMLDecoder(reader io.Reader) *xml.Decoder {
 	decoder := xml.NewDecoder(reader)
 
 	decoder.Strict = false
 	decoder.AutoClose = []string{
+		"dd",
+		"dt",
+		"hr",
 		"p",
 	}
+	decoder.Entity = xml.HTMLEntity
 
 	return decoder
 }
 
-func newParser(rs io.ReadSeeker) *parser {
-	decoder := newXMLDecoder(rs)
+func newParser(r io.Reader, opts ParseOptions) *parser {
+	rr := newRecordingReader(r)
+	decoder := newXMLDecoder(rr)
 	file := &FileNode{}
 
 	return &parser{
-		rs:      rs,
+		rr:      rr,
 		decoder: decoder,
+		mode:    opts.Mode,
+		base:    Position{Offset: 0, Line: 1, Column: 1},
 		file:    file,
 	}
 }
 
+// position returns the current position of the parser in the input.
+func (p *parser) position() Position {
+	line, column := p.decoder.InputPos()
+
+	if line == 1 {
+		column += p.base.Column - 1
+	}
+
+	return Position{
+		Offset: p.base.Offset + p.decoder.InputOffset(),
+		Line:   p.base.Line + line - 1,
+		Column: column,
+	}
+}
+
+// newParseError returns a ParseError located at the position of the last
+// token processed.
+func (p *parser) newParseError(msg string, inner error) error {
+	return &ParseError{
+		Msg:     msg,
+		Pos:     p.tokenPos.Offset,
+		Line:    p.tokenPos.Line,
+		Column:  p.tokenPos.Column,
+		Context: p.rr.context(p.tokenPos.Offset),
+		Err:     inner,
+	}
+}
+
+// nextToken returns the next XML token, and records the position where it
+// starts.
+//
+// In Lenient mode, XML syntax errors are reported as Diagnostics, and the
+// parser resumes reading tokens past the malformed markup; if it cannot,
+// io.EOF is returned.
+func (p *parser) nextToken() (xml.Token, error) {
+	if p.pending != nil {
+		tok := p.pending
+		p.pending = nil
+		return tok, nil
+	}
+
+	if p.stopped {
+		return nil, io.EOF
+	}
+
+	tok, start, err := p.token()
+	p.tokenStart = start
+
+	for p.mode == Lenient && err != nil && !errors.Is(err, io.EOF) {
+		if p.rr.offset == p.tokenStart.Offset {
+			// The input has been entirely consumed, and the decoder
+			// reports elements that have not been closed, which is
+			// expected for Netscape Bookmark documents.
+			return nil, io.EOF
+		}
+
+		p.tokenPos = p.position()
+
+		if !p.resync() {
+			p.stopped = true
+			p.recover(SeverityError, "stopped parsing", p.newParseError("failed to read token", err))
+			return nil, io.EOF
+		}
+
+		var syntaxErr *xml.SyntaxError
+		if errors.As(err, &syntaxErr) && strings.HasPrefix(syntaxErr.Msg, "unexpected end element") {
+			err = fmt.Errorf("%w: %w", ErrFolderStructureInvalid, err)
+		}
+
+		p.recover(SeverityWarning, "skipped malformed markup", p.newParseError("failed to read token", err))
+
+		tok, p.tokenStart, err = p.token()
+	}
+
+	return tok, err
+}
+
+// token returns the next XML token read by the decoder, and the position where
+// it starts.
+//
+// When an element is closed automatically, the decoder reads the next token
+// before returning the end element it synthesizes, and returns this token
+// without reading further from the input on the following call; this token
+// then starts where the synthesized end element does.
+func (p *parser) token() (xml.Token, Position, error) {
+	start := p.position()
+
+	tok, err := p.decoder.Token()
+
+	if p.readAhead && p.position().Offset == start.Offset {
+		start = p.readAheadStart
+	}
+
+	_, p.readAhead = tok.(xml.EndElement)
+	p.readAheadStart = start
+
+	return tok, start, err
+}
+
+// resync replaces the XML decoder after it has encountered a syntax error, to
+// resume reading tokens from the current position in the input.
+//
+// It returns false if no progress has been made in the input since the
+// decoder was created, or if the end of the input has been reached.
+func (p *parser) resync() bool {
+	pos := p.position()
+
+	if pos.Offset == p.base.Offset {
+		return false
+	}
+
+	// the decoder may have read one byte past its current position
+	if p.rr.offset > pos.Offset {
+		p.rr.unreadByte()
+	}
+
+	if _, err := p.rr.r.Peek(1); err != nil {
+		return false
+	}
+
+	p.base = pos
+	p.decoder = newXMLDecoder(p.rr)
+	p.readAhead = false
+
+	return true
+}
+
+// recover reports whether the parser can recover from err, in which case a
+// Diagnostic located at the start of the last token read is recorded with the
+// given severity and message.
+func (p *parser) recover(severity Severity, msg string, err error) bool {
+	if p.mode != Lenient {
+		return false
+	}
+
+	p.diagnostics = append(p.diagnostics, newDiagnostic(severity, p.tokenStart, msg, err))
+
+	return true
+}
+
+// notify passes the pending folder or bookmark to the listener, if any.
+func (p *parser) notify() error {
+	pendingFolder, pendingBookmark := p.pendingFolder, p.pendingBookmark
+	p.pendingFolder, p.pendingBookmark = false, false
+
+	if p.listener == nil {
+		return nil
+	}
+
+	if pendingFolder {
+		if err := p.listener.folderStart(p.currentFolder); err != nil {
+			p.listenerErr = err
+			return err
+		}
+	}
+
+	if pendingBookmark {
+		if err := p.listener.bookmark(p.currentBookmark); err != nil {
+			p.listenerErr = err
+			return err
+		}
+
+		p.release(p.currentFolder)
+	}
+
+	return nil
+}
+
+// notifyFolderEnd notifies the listener, if any, that the current folder is
+// closed.
+func (p *parser) notifyFolderEnd() error {
+	if p.listener == nil {
+		return nil
+	}
+
+	if err := p.listener.folderEnd(p.currentFolder); err != nil {
+		p.listenerErr = err
+		return err
+	}
+
+	if p.currentFolder.Parent != nil {
+		p.release(p.currentFolder.Parent)
+	}
+
+	return nil
+}
+
+// release drops the items of folder f that have been passed to the listener,
+// so that memory usage does not depend on the size of the input.
+func (p *parser) release(f *FolderNode) {
+	if p.listener == nil {
+		return
+	}
+
+	f.Bookmarks = f.Bookmarks[:0]
+	f.Separators = f.Separators[:0]
+	f.Subfolders = f.Subfolders[:0]
+	f.Order = f.Order[:0]
+}
+
 func (p *parser) parse() (*FileNode, error) {
+	p.file.Span.Start = p.position()
+
 	if err := p.verifyDoctype(); err != nil {
+		if !p.recover(SeverityWarning, "parsing document without a valid DOCTYPE", err) {
+			return &FileNode{}, err
+		}
+	}
+
+	if err := p.parseDocument(); err != nil {
+		if p.listenerErr != nil {
+			return &FileNode{}, p.listenerErr
+		}
+
+		if !p.recover(SeverityError, "stopped parsing", err) {
+			return &FileNode{}, err
+		}
+	}
+
+	if err := p.notify(); err != nil {
 		return &FileNode{}, err
 	}
 
+	// close the span of the file, and of any folder left open
+	end := p.position()
+	p.file.Span.End = end
+
+	for folder := p.currentFolder; folder != nil; folder = folder.Parent {
+		folder.Span.End = end
+	}
+
+	return p.file, nil
+}
+
+func (p *parser) parseDocument() error {
 	for {
-		tok, err := p.decoder.Token()
+		tok, err := p.nextToken()
 		if tok == nil || errors.Is(err, io.EOF) {
 			break
 		}
@@ -121,30 +470,87 @@ func (p *parser) parse() (*FileNode, error) {
 			switch tokType.Name.Local {
 			case "TITLE", "Title":
 				if err := p.parseTitle(&tokType); err != nil {
-					return &FileNode{}, err
+					return err
 				}
 			case "H1":
 				folder, err := p.parseFolder(&tokType)
 				if err != nil {
-					return &FileNode{}, err
+					return err
 				}
 
 				p.file.Root = folder
 				p.currentFolder = &p.file.Root
+				p.pendingFolder = true
+			case "DD":
+				if p.currentFolder == nil {
+					continue
+				}
+
+				description, _, err := p.parseDescription()
+				if err != nil {
+					return err
+				}
+
+				p.currentFolder.Description = description
+
+				if err := p.notify(); err != nil {
+					return err
+				}
+
+				// the description ends with the first element of the root
+				// folder, usually <DL>, which has already been consumed
+				if err := p.parseBookmarks(); err != nil {
+					return err
+				}
 			case "DL", "DT":
 				if p.currentFolder == nil {
 					// The document must have a <H1>...</H1> root folder.
-					return &FileNode{}, newParseError("failed to parse bookmarks", p.tokenOffset, ErrRootFolderMissing)
+					err := p.newParseError("failed to parse bookmarks", ErrRootFolderMissing)
+					if !p.recover(SeverityWarning, "created a root folder", err) {
+						return err
+					}
+
+					p.file.Root = p.newRootFolder()
+					p.currentFolder = &p.file.Root
+					p.pendingFolder = true
 				}
 
 				if err := p.parseBookmarks(); err != nil {
-					return &FileNode{}, err
+					return err
 				}
 			}
 		}
 	}
 
-	return p.file, nil
+	return nil
+}
+
+// newRootFolder returns a root folder for documents that lack a <H1> element,
+// named after the document title if any.
+func (p *parser) newRootFolder() FolderNode {
+	name := p.file.Title
+	if name == "" {
+		name = untitledFolderName
+	}
+
+	return FolderNode{
+		Span: Span{Start: p.tokenStart},
+		Name: name,
+	}
+}
+
+// reopenRootFolder makes the root folder the current folder again, to attach
+// elements following its closing </DL> element.
+func (p *parser) reopenRootFolder(msg string) error {
+	err := p.newParseError(msg, ErrFolderStructureInvalid)
+	if !p.recover(SeverityWarning, "attached element to the root folder", err) {
+		return err
+	}
+
+	p.currentDepth = 0
+	p.currentFolder = &p.file.Root
+
+	return nil
 }
 
 func (p *parser) parseTitle(start *xml.StartElement) error {
@@ -153,31 +559,39 @@ func (p *parser) parseTitle(start *xml.StartElement) error {
 	}
 
 	if err := p.decoder.DecodeElement(&title, start); err != nil {
-		return newParseError("failed to parse title", p.tokenOffset, err)
+		return p.newParseError("failed to parse title", err)
 	}
 
-	p.tokenOffset = p.decoder.InputOffset()
+	p.tokenPos = p.position()
 	p.file.Title = title.Value
 
 	return nil
 }
 
 func (p *parser) parseFolder(start *xml.StartElement) (FolderNode, error) {
+	startPos := p.tokenStart
+
 	var elt struct {
 		Name string `xml:",chardata"`
 	}
 
 	if err := p.decoder.DecodeElement(&elt, start); err != nil {
-		return FolderNode{}, newParseError("failed to parse folder", p.tokenOffset, err)
+		return FolderNode{}, p.newParseError("failed to parse folder", err)
 	}
 
 	if elt.Name == "" {
-		return FolderNode{}, newParseError("failed to parse folder", p.tokenOffset, ErrFolderTitleEmpty)
+		err := p.newParseError("failed to parse folder", ErrFolderTitleEmpty)
+		if !p.recover(SeverityWarning, fmt.Sprintf("named folder %q", untitledFolderName), err) {
+			return FolderNode{}, err
+		}
+
+		elt.Name = untitledFolderName
 	}
 
-	p.tokenOffset = p.decoder.InputOffset()
+	p.tokenPos = p.position()
 
 	folder := FolderNode{
+		Span: Span{Start: startPos},
 		Name: elt.Name,
 	}
 
@@ -195,7 +609,7 @@ func (p *parser) parseBookmarks() error {
 	var lastElementType string
 
 	for {
-		tok, err := p.decoder.Token()
+		tok, err := p.nextToken()
 		if tok == nil || errors.Is(err, io.EOF) {
 			break
 		}
@@ -204,20 +618,28 @@ func (p *parser) parseBookmarks() error {
 		case xml.StartElement:
 			switch tokType.Name.Local {
 			case "A":
+				if err := p.notify(); err != nil {
+					return err
+				}
+
 				bookmark, err := p.parseBookmark(&tokType)
 				if err != nil {
 					return err
 				}
 
 				if p.currentFolder == nil {
-					return newParseError("failed to parse bookmarks", p.tokenOffset, ErrFolderStructureInvalid)
+					if err := p.reopenRootFolder("failed to parse bookmarks"); err != nil {
+						return err
+					}
 				}
 
 				p.currentFolder.Bookmarks = append(p.currentFolder.Bookmarks, bookmark)
+				p.currentFolder.Order = append(p.currentFolder.Order, ItemRef{Kind: BookmarkKind, Index: len(p.currentFolder.Bookmarks) - 1})
 				p.currentBookmark = &p.currentFolder.Bookmarks[len(p.currentFolder.Bookmarks)-1]
+				p.pendingBookmark = true
 				lastElementType = "A"
 			case "DD":
-				description, err := p.parseDescription()
+				description, end, err := p.parseDescription()
 				if err != nil {
 					return err
 				}
@@ -225,23 +647,55 @@ func (p *parser) parseBookmarks() error {
 				switch lastElementType {
 				case "A":
 					p.currentBookmark.Description = description
+					if description != "" {
+						p.currentBookmark.Span.End = end
+					}
 				case "H3":
 					p.currentFolder.Description = description
 				}
+
+				if err := p.notify(); err != nil {
+					return err
+				}
+			case "HR":
+				if err := p.notify(); err != nil {
+					return err
+				}
+
+				if p.currentFolder == nil {
+					if err := p.reopenRootFolder("failed to parse separator"); err != nil {
+						return err
+					}
+				}
+
+				separator := p.parseSeparator(&tokType)
+
+				p.currentFolder.Separators = append(p.currentFolder.Separators, separator)
+				p.currentFolder.Order = append(p.currentFolder.Order, ItemRef{Kind: SeparatorKind, Index: len(p.currentFolder.Separators) - 1})
+				p.release(p.currentFolder)
+				lastElementType = "HR"
 			case "H3":
+				if err := p.notify(); err != nil {
+					return err
+				}
+
 				folder, err := p.parseFolder(&tokType)
 				if err != nil {
 					return err
 				}
 
 				if p.currentFolder == nil {
-					return newParseError("failed to parse H3 subfolder", p.tokenOffset, ErrFolderStructureInvalid)
+					if err := p.reopenRootFolder("failed to parse H3 subfolder"); err != nil {
+						return err
+					}
 				}
 
 				folder.Parent = p.currentFolder
 				p.currentFolder.Subfolders = append(p.currentFolder.Subfolders, folder)
+				p.currentFolder.Order = append(p.currentFolder.Order, ItemRef{Kind: FolderKind, Index: len(p.currentFolder.Subfolders) - 1})
 				p.currentFolder = &p.currentFolder.Subfolders[len(p.currentFolder.Subfolders)-1]
 				p.currentDepth++
+				p.pendingFolder = true
 
 				lastElementType = "H3"
 			}
@@ -249,10 +703,25 @@ func (p *parser) parseBookmarks() error {
 			switch tokType.Name.Local {
 			case "DL":
 				if p.currentDepth < 0 {
-					return newParseError("failed to parse bookmarks", p.tokenOffset, ErrFolderStructureInvalid)
+					err := p.newParseError("failed to parse bookmarks", ErrFolderStructureInvalid)
+					if !p.recover(SeverityWarning, "skipped orphan </DL> element", err) {
+						return err
+					}
+
+					continue
+				}
+
+				if err := p.notify(); err != nil {
+					return err
 				}
 
 				p.currentDepth--
+				p.currentFolder.Span.End = p.position()
+
+				if err := p.notifyFolderEnd(); err != nil {
+					return err
+				}
+
 				p.currentFolder = p.currentFolder.Parent
 			}
 		}
@@ -262,17 +731,20 @@ func (p *parser) parseBookmarks() error {
 }
 
 func (p *parser) parseBookmark(start *xml.StartElement) (BookmarkNode, error) {
+	startPos := p.tokenStart
+
 	var link struct {
 		Title string `xml:",chardata"`
 	}
 
 	if err := p.decoder.DecodeElement(&link, start); err != nil {
-		return BookmarkNode{}, newParseError("failed to parse bookmark", p.tokenOffset, err)
+		return BookmarkNode{}, p.newParseError("failed to parse bookmark", err)
 	}
 
-	p.tokenOffset = p.decoder.InputOffset()
+	p.tokenPos = p.position()
 
 	bookmark := BookmarkNode{
+		Span:  Span{Start: startPos, End: p.tokenPos},
 		Title: link.Title,
 	}
 
@@ -291,83 +763,102 @@ func (p *parser) parseBookmark(start *xml.StartElement) (BookmarkNode, error) {
 	return bookmark, nil
 }
 
+func (p *parser) parseSeparator(start *xml.StartElement) SeparatorNode {
+	p.tokenPos = p.position()
+
+	separator := SeparatorNode{
+		Span: Span{Start: p.tokenStart, End: p.tokenPos},
+	}
+
+	if len(start.Attr) > 0 {
+		separator.Attributes = make(map[string]string, len(start.Attr))
+		for _, attr := range start.Attr {
+			separator.Attributes[attr.Name.Local] = attr.Value
+		}
+	}
+
+	return separator
+}
+
 // parseDescription returns a string containing all data following a <DD>
-// element, and preceding either a <DT> or </DL> element.
+// element, and preceding either a <DT>, <HR> or </DL> element, as well as the
+// position where this data ends.
 //
 // Leading and trailing whitespace is trimmed from the returned string.
 //
 // A description may contain text and HTML elements.
-func (p *parser) parseDescription() (string, error) {
-	startOffset := p.decoder.InputOffset()
-	endOffset := startOffset
-
-	var rsOffset int64
+func (p *parser) parseDescription() (string, Position, error) {
+	startPos := p.position()
+	endPos := startPos
 
 	// As the description may contain either text or HTML elements, we do not
-	// directly process the stream of XML tokens, and instead look for the start
-	// and end offsets of the description data in the underlying io.ReadSeeker.
+	// directly process the stream of XML tokens, and instead record the raw
+	// data read from the input, to extract the description data between its
+	// start and end offsets.
+	p.rr.startRecording(startPos.Offset)
+
 loop:
 	for {
-		tok, err := p.decoder.Token()
+		tok, tokStart, err := p.token()
 		if err != nil {
-			return "", newParseError("failed to parse description", p.tokenOffset, err)
+			return "", Position{}, p.newParseError("failed to parse description", err)
 		}
 
-		p.tokenOffset = p.decoder.InputOffset()
+		p.tokenPos = p.position()
 
 		switch tokType := tok.(type) {
 		case xml.CharData:
-			endOffset = p.decoder.InputOffset()
+			endPos = p.tokenPos
 		case xml.StartElement:
 			if tokType.Name.Local == "DL" || tokType.Name.Local == "DT" {
 				break loop
 			}
+
+			if tokType.Name.Local == "HR" {
+				// keep the separator to add it to the current folder
+				p.tokenStart = tokStart
+				p.pending = tok
+				break loop
+			}
 		case xml.EndElement:
-			if tokType.Name.Local == "DD" || tokType.Name.Local == "DL" {
+			if tokType.Name.Local == "DD" {
+				// closed automatically, before the description data
+				continue
+			}
+
+			if tokType.Name.Local == "DL" {
+				// keep the end of the folder to close it
+				p.tokenStart = tokStart
+				p.pending = tok
 				break loop
 			}
 
-			endOffset = p.decoder.InputOffset()
+			endPos = p.tokenPos
 		}
 	}
 
-	rsOffset, err := p.rs.Seek(0, io.SeekCurrent)
-	if err != nil {
-		return "", err
-	}
-	// read raw data between start and end offsets
-	dataLen := int(endOffset - startOffset)
+	data := p.rr.stopRecording(startPos.Offset, endPos.Offset)
+	data = bytes.TrimRightFunc(data, unicode.IsSpace)
 
-	// reuse buffer if large enough, otherwise grow it
-	if cap(p.descriptionBuf) >= dataLen {
-		p.descriptionBuf = p.descriptionBuf[:dataLen]
-	} else {
-		p.descriptionBuf = make([]byte, dataLen)
-	}
+	// sanitize data
+	description := strings.TrimLeftFunc(string(data), unicode.IsSpace)
+	return description, advancePosition(startPos, data), nil
+}
 
-	_, err = p.rs.Seek(startOffset, io.SeekStart)
-	if err != nil {
-		return "", fmt.Errorf("description: failed to reset rs position to %d: %w", startOffset, err)
-	}
+// advancePosition returns the position following data, starting at pos.
+func advancePosition(pos Position, data []byte) Position {
+	pos.Offset += int64(len(data))
 
-	nRead, err := p.rs.Read(p.descriptionBuf)
-	if err != nil {
-		return "", fmt.Errorf("description: failed to read data in range [%d:%d]: %w", startOffset, endOffset, err)
+	lastNewline := bytes.LastIndexByte(data, '\n')
+	if lastNewline < 0 {
+		pos.Column += len(data)
+		return pos
 	}
 
-	if nRead != dataLen {
-		return "", fmt.Errorf("description: expected to read %d bytes, read %d", dataLen, nRead)
-	}
+	pos.Line += bytes.Count(data, []byte{'\n'})
+	pos.Column = len(data) - lastNewline
 
-	// reset the io.ReadSeeker position
-	_, err = p.rs.Seek(rsOffset, io.SeekStart)
-	if err != nil {
-		return "", fmt.Errorf("description: failed to reset rs position to %d: %w", startOffset, err)
-	}
-
-	// sanitize data
-	description := strings.TrimSpace(string(p.descriptionBuf))
-	return description, nil
+	return pos
 }
 
 var (
@@ -385,24 +876,29 @@ const (
 
 func (p *parser) verifyDoctype() error {
 	for {
-		tok, err := p.decoder.Token()
+		tok, err := p.nextToken()
 
 		if tok == nil || errors.Is(err, io.EOF) {
 			return ErrDoctypeMissing
 		}
 
-		p.tokenOffset = p.decoder.InputOffset()
+		p.tokenPos = p.position()
 
 		switch tokType := tok.(type) {
 		case xml.CharData:
 			if bytes.Equal(tokType, utf8bom) {
 				continue
 			}
-			return newParseError("unexpected character data", p.tokenOffset, ErrDoctypeInvalid)
+			return p.newParseError("unexpected character data", ErrDoctypeInvalid)
+
+		case xml.StartElement:
+			// keep the element to process it in Lenient mode
+			p.pending = tok
+			return ErrDoctypeMissing
 
 		case xml.Directive:
 			if string(tokType) != xmlDoctypeTokenType {
-				return newParseError("unknown DOCTYPE "+string(tokType), p.tokenOffset, ErrDoctypeInvalid)
+				return p.newParseError("unknown DOCTYPE "+string(tokType), ErrDoctypeInvalid)
 			}
 			return nil
 
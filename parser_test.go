@@ -8,21 +8,23 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+	"testing/iotest"
 )
 
-func TestParse(t *testing.T) {
-	cases := []struct {
-		tname   string
-		input   string
-		want    FileNode
-		wantErr error
-	}{
-		// nominal cases
-		{
-			tname: "flat document",
-			input: `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+// parseCases lists documents along with the expected AST, or parsing error.
+var parseCases = []struct {
+	tname   string
+	input   string
+	want    FileNode
+	wantErr error
+}{
+	// nominal cases
+	{
+		tname: "flat document",
+		input: `<!DOCTYPE NETSCAPE-Bookmark-file-1>
 <!-- This is an automatically generated file.
      It will be read and overwritten.
      DO NOT EDIT! -->
@@ -37,73 +39,111 @@ func TestParse(t *testing.T) {
 <DD>
 </DL><p>
 `,
-			want: FileNode{
-				Title: "Bookmarks",
-				Root: FolderNode{
-					Name: "Bookmarks",
-					Bookmarks: []BookmarkNode{
-						{
-							Href:  "https://domain.tld",
-							Title: "Test Domain",
-						},
-						{
-							Href:        "https://desc.domain.tld",
-							Title:       "Test Domain (with description)",
-							Description: "Look! A short description for this bookmark.",
-						},
-						{
-							Href:  "https://emptydesc.domain.tld",
-							Title: "Test Domain (with empty description)",
-						},
+		want: FileNode{
+			Title: "Bookmarks",
+			Root: FolderNode{
+				Name: "Bookmarks",
+				Bookmarks: []BookmarkNode{
+					{
+						Href:  "https://domain.tld",
+						Title: "Test Domain",
+					},
+					{
+						Href:        "https://desc.domain.tld",
+						Title:       "Test Domain (with description)",
+						Description: "Look! A short description for this bookmark.",
+					},
+					{
+						Href:  "https://emptydesc.domain.tld",
+						Title: "Test Domain (with empty description)",
 					},
 				},
 			},
 		},
-		{
-			tname: "empty document with UTF-8 BOM",
-			input: string(utf8bom) + `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+	},
+	{
+		tname: "document with root folder description and escaped characters",
+		input: `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<TITLE>R&amp;D &lt;internal&gt;</TITLE>
+<H1>R&amp;D&nbsp;bookmarks</H1>
+<DD>Shared &amp; reviewed
+<DL><p>
+<DT><A HREF="https://domain.tld">Test &#34;Domain&#34;</A>
+<DD>First description
+<HR>
+<DT><A HREF="https://test.domain.tld">Test Domain II</A>
+</DL><p>
+`,
+		want: FileNode{
+			Title: "R&D <internal>",
+			Root: FolderNode{
+				Name:        "R&D\u00a0bookmarks",
+				Description: "Shared &amp; reviewed",
+				Bookmarks: []BookmarkNode{
+					{
+						Href:        "https://domain.tld",
+						Title:       `Test "Domain"`,
+						Description: "First description",
+					},
+					{
+						Href:  "https://test.domain.tld",
+						Title: "Test Domain II",
+					},
+				},
+				Separators: []SeparatorNode{{}},
+				Order: []ItemRef{
+					{Kind: BookmarkKind, Index: 0},
+					{Kind: SeparatorKind, Index: 0},
+					{Kind: BookmarkKind, Index: 1},
+				},
+			},
+		},
+	},
+	{
+		tname: "empty document with UTF-8 BOM",
+		input: string(utf8bom) + `<!DOCTYPE NETSCAPE-Bookmark-file-1>
 <TITLE>Bookmarks</TITLE>
 <H1>Bookmarks</H1>
 <DL><p>
 </DL><p>
 `,
-			want: FileNode{
-				Title: "Bookmarks",
-				Root: FolderNode{
-					Name: "Bookmarks",
-				},
+		want: FileNode{
+			Title: "Bookmarks",
+			Root: FolderNode{
+				Name: "Bookmarks",
 			},
 		},
-		{
-			tname: "bookmark with attributes",
-			input: `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+	},
+	{
+		tname: "bookmark with attributes",
+		input: `<!DOCTYPE NETSCAPE-Bookmark-file-1>
 <TITLE>Bookmarks</TITLE>
 <H1>Bookmarks</H1>
 <DL><p>
     <DT><A HREF="https://domain.tld" ADD_DATE="151637044" PRIVATE="1" TAGS="test tags">Test Domain</A>
 </DL><p>
 `,
-			want: FileNode{
-				Title: "Bookmarks",
-				Root: FolderNode{
-					Name: "Bookmarks",
-					Bookmarks: []BookmarkNode{
-						{
-							Href:  "https://domain.tld",
-							Title: "Test Domain",
-							Attributes: map[string]string{
-								"ADD_DATE": "151637044",
-								"PRIVATE":  "1",
-								"TAGS":     "test tags",
-							},
+		want: FileNode{
+			Title: "Bookmarks",
+			Root: FolderNode{
+				Name: "Bookmarks",
+				Bookmarks: []BookmarkNode{
+					{
+						Href:  "https://domain.tld",
+						Title: "Test Domain",
+						Attributes: map[string]string{
+							"ADD_DATE": "151637044",
+							"PRIVATE":  "1",
+							"TAGS":     "test tags",
 						},
 					},
 				},
 			},
 		},
-		{
-			tname: "bookmark with empty description",
-			input: `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+	},
+	{
+		tname: "bookmark with empty description",
+		input: `<!DOCTYPE NETSCAPE-Bookmark-file-1>
 <TITLE>Bookmarks</TITLE>
 <H1>Bookmarks</H1>
 <DL><p>
@@ -115,30 +155,30 @@ func TestParse(t *testing.T) {
 <DD>
 </DL><p>
 `,
-			want: FileNode{
-				Title: "Bookmarks",
-				Root: FolderNode{
-					Name: "Bookmarks",
-					Bookmarks: []BookmarkNode{
-						{
-							Href:  "https://domain.tld",
-							Title: "Test Domain",
-						},
-						{
-							Href:  "https://domain.tld",
-							Title: "Test Domain",
-						},
-						{
-							Href:  "https://emptydesc.domain.tld",
-							Title: "Test Domain (with empty description)",
-						},
+		want: FileNode{
+			Title: "Bookmarks",
+			Root: FolderNode{
+				Name: "Bookmarks",
+				Bookmarks: []BookmarkNode{
+					{
+						Href:  "https://domain.tld",
+						Title: "Test Domain",
+					},
+					{
+						Href:  "https://domain.tld",
+						Title: "Test Domain",
+					},
+					{
+						Href:  "https://emptydesc.domain.tld",
+						Title: "Test Domain (with empty description)",
 					},
 				},
 			},
 		},
-		{
-			tname: "bookmark with multi-line description",
-			input: `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+	},
+	{
+		tname: "bookmark with multi-line description",
+		input: `<!DOCTYPE NETSCAPE-Bookmark-file-1>
 <TITLE>Bookmarks</TITLE>
 <H1>Bookmarks</H1>
 <DL><p>
@@ -152,23 +192,23 @@ func TestParse(t *testing.T) {
 - item 3
 </DL><p>
 `,
-			want: FileNode{
-				Title: "Bookmarks",
-				Root: FolderNode{
-					Name: "Bookmarks",
-					Bookmarks: []BookmarkNode{
-						{
-							Description: "Description:\n\n- item 1\n    - item 1.1\n    - item 1.2\n- item 2\n- item 3",
-							Href:        "https://domain.tld",
-							Title:       "Test Domain",
-						},
+		want: FileNode{
+			Title: "Bookmarks",
+			Root: FolderNode{
+				Name: "Bookmarks",
+				Bookmarks: []BookmarkNode{
+					{
+						Description: "Description:\n\n- item 1\n    - item 1.1\n    - item 1.2\n- item 2\n- item 3",
+						Href:        "https://domain.tld",
+						Title:       "Test Domain",
 					},
 				},
 			},
 		},
-		{
-			tname: "bookmark with description containing HTML markup",
-			input: `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+	},
+	{
+		tname: "bookmark with description containing HTML markup",
+		input: `<!DOCTYPE NETSCAPE-Bookmark-file-1>
 <TITLE>Bookmarks</TITLE>
 <H1>Bookmarks</H1>
 <DL><p>
@@ -177,24 +217,24 @@ func TestParse(t *testing.T) {
 <a href="http://localhost:8080"><img src="http://localhost:8080/splash.png"/></a>
 </DL><p>
 `,
-			want: FileNode{
-				Title: "Bookmarks",
-				Root: FolderNode{
-					Name: "Bookmarks",
-					Bookmarks: []BookmarkNode{
-						{
-							Description: `Markup:
+		want: FileNode{
+			Title: "Bookmarks",
+			Root: FolderNode{
+				Name: "Bookmarks",
+				Bookmarks: []BookmarkNode{
+					{
+						Description: `Markup:
 <a href="http://localhost:8080"><img src="http://localhost:8080/splash.png"/></a>`,
-							Href:  "https://domain.tld",
-							Title: "Test Domain",
-						},
+						Href:  "https://domain.tld",
+						Title: "Test Domain",
 					},
 				},
 			},
 		},
-		{
-			tname: "nested folders",
-			input: `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+	},
+	{
+		tname: "nested folders",
+		input: `<!DOCTYPE NETSCAPE-Bookmark-file-1>
 <TITLE>Bookmarks</TITLE>
 <H1>Level 0</H1>
 <DL><p>
@@ -209,25 +249,25 @@ func TestParse(t *testing.T) {
 	</DL><p>
 </DL><p>
 `,
-			want: FileNode{
-				Title: "Bookmarks",
-				Root: FolderNode{
-					Name: "Level 0",
-					Subfolders: []FolderNode{
-						{
-							Name: "Level 1A",
-							Subfolders: []FolderNode{
-								{Name: "Level 2A"},
-							},
+		want: FileNode{
+			Title: "Bookmarks",
+			Root: FolderNode{
+				Name: "Level 0",
+				Subfolders: []FolderNode{
+					{
+						Name: "Level 1A",
+						Subfolders: []FolderNode{
+							{Name: "Level 2A"},
 						},
-						{Name: "Level 1B"},
 					},
+					{Name: "Level 1B"},
 				},
 			},
 		},
-		{
-			tname: "nested folders with bookmarks",
-			input: `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+	},
+	{
+		tname: "nested folders with bookmarks",
+		input: `<!DOCTYPE NETSCAPE-Bookmark-file-1>
 <TITLE>Bookmarks</TITLE>
 <H1>Level 0</H1>
 <DL><p>
@@ -246,37 +286,37 @@ func TestParse(t *testing.T) {
 	</DL><p>
 </DL><p>
 `,
-			want: FileNode{
-				Title: "Bookmarks",
-				Root: FolderNode{
-					Name: "Level 0",
-					Bookmarks: []BookmarkNode{
-						{Href: "https://l0.domain.tld", Title: "Level 0"},
-						{Href: "https://l0.domain.tld", Title: "Level 0"},
-					},
-					Subfolders: []FolderNode{
-						{
-							Name: "Level 1A",
-							Bookmarks: []BookmarkNode{
-								{Href: "https://l1a.domain.tld", Title: "Level 1A"},
-							},
-							Subfolders: []FolderNode{
-								{
-									Name: "Level 2A",
-									Bookmarks: []BookmarkNode{
-										{Href: "https://l2a.domain.tld", Title: "Level 2A"},
-									},
+		want: FileNode{
+			Title: "Bookmarks",
+			Root: FolderNode{
+				Name: "Level 0",
+				Bookmarks: []BookmarkNode{
+					{Href: "https://l0.domain.tld", Title: "Level 0"},
+					{Href: "https://l0.domain.tld", Title: "Level 0"},
+				},
+				Subfolders: []FolderNode{
+					{
+						Name: "Level 1A",
+						Bookmarks: []BookmarkNode{
+							{Href: "https://l1a.domain.tld", Title: "Level 1A"},
+						},
+						Subfolders: []FolderNode{
+							{
+								Name: "Level 2A",
+								Bookmarks: []BookmarkNode{
+									{Href: "https://l2a.domain.tld", Title: "Level 2A"},
 								},
 							},
 						},
-						{Name: "Level 1B"},
 					},
+					{Name: "Level 1B"},
 				},
 			},
 		},
-		{
-			tname: "nested folder with attributes",
-			input: `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+	},
+	{
+		tname: "nested folder with attributes",
+		input: `<!DOCTYPE NETSCAPE-Bookmark-file-1>
 <TITLE>Bookmarks</TITLE>
 <H1>Bookmarks</H1>
 <DL><p>
@@ -286,27 +326,27 @@ func TestParse(t *testing.T) {
 	</DL><p>
 </DL><p>
 `,
-			want: FileNode{
-				Title: "Bookmarks",
-				Root: FolderNode{
-					Name: "Bookmarks",
-					Subfolders: []FolderNode{
-						{
-							Name:        "Personal toolbar",
-							Description: "Add bookmarks to this folder to see them displayed on the Bookmarks Toolbar",
-							Attributes: map[string]string{
-								"ADD_DATE":                "1460294955",
-								"LAST_MODIFIED":           "1460294956",
-								"PERSONAL_TOOLBAR_FOLDER": "true",
-							},
+		want: FileNode{
+			Title: "Bookmarks",
+			Root: FolderNode{
+				Name: "Bookmarks",
+				Subfolders: []FolderNode{
+					{
+						Name:        "Personal toolbar",
+						Description: "Add bookmarks to this folder to see them displayed on the Bookmarks Toolbar",
+						Attributes: map[string]string{
+							"ADD_DATE":                "1460294955",
+							"LAST_MODIFIED":           "1460294956",
+							"PERSONAL_TOOLBAR_FOLDER": "true",
 						},
 					},
 				},
 			},
 		},
-		{
-			tname: "nested folder with description and bookmarks",
-			input: `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+	},
+	{
+		tname: "nested folder with description and bookmarks",
+		input: `<!DOCTYPE NETSCAPE-Bookmark-file-1>
 <TITLE>Bookmarks</TITLE>
 <H1>Level 0</H1>
 <DL><p>
@@ -317,89 +357,90 @@ func TestParse(t *testing.T) {
     </DL><p>
 </DL><p>
 `,
-			want: FileNode{
-				Title: "Bookmarks",
-				Root: FolderNode{
-					Name: "Level 0",
-					Subfolders: []FolderNode{
-						{
-							Name:        "Level 1",
-							Description: "Folder with description",
-							Bookmarks: []BookmarkNode{
-								{
-									Href:  "https://domain.tld",
-									Title: "Test Domain",
-								},
+		want: FileNode{
+			Title: "Bookmarks",
+			Root: FolderNode{
+				Name: "Level 0",
+				Subfolders: []FolderNode{
+					{
+						Name:        "Level 1",
+						Description: "Folder with description",
+						Bookmarks: []BookmarkNode{
+							{
+								Href:  "https://domain.tld",
+								Title: "Test Domain",
 							},
 						},
 					},
 				},
 			},
 		},
+	},
 
-		// error cases
-		{
-			tname:   "empty document",
-			wantErr: ErrDoctypeMissing,
-		},
-		{
-			tname:   "missing DOCTYPE",
-			input:   `<!-- No DOCTYPE\n  -->\n`,
-			wantErr: ErrDoctypeMissing,
-		},
-		{
-			tname:   "invalid DOCTYPE",
-			input:   `<!DOCTYPE dummy SYSTEM "dummy.dtd">`,
-			wantErr: ErrDoctypeInvalid,
-		},
-		{
-			tname: "incomplete TITLE",
-			input: `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+	// error cases
+	{
+		tname:   "empty document",
+		wantErr: ErrDoctypeMissing,
+	},
+	{
+		tname:   "missing DOCTYPE",
+		input:   `<!-- No DOCTYPE\n  -->\n`,
+		wantErr: ErrDoctypeMissing,
+	},
+	{
+		tname:   "invalid DOCTYPE",
+		input:   `<!DOCTYPE dummy SYSTEM "dummy.dtd">`,
+		wantErr: ErrDoctypeInvalid,
+	},
+	{
+		tname: "incomplete TITLE",
+		input: `<!DOCTYPE NETSCAPE-Bookmark-file-1>
 <TITLE>Bookmarks`,
-			wantErr: newParseError("failed to parse title", 35, &xml.SyntaxError{Msg: "unexpected EOF", Line: 2}),
-		},
-		{
-			tname: "incomplete H1",
-			input: `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+		wantErr: newParseError("failed to parse title", 35, &xml.SyntaxError{Msg: "unexpected EOF", Line: 2}),
+	},
+	{
+		tname: "incomplete H1",
+		input: `<!DOCTYPE NETSCAPE-Bookmark-file-1>
 <TITLE>Bookmarks</TITLE>
 <H1>Bookma`,
-			wantErr: newParseError("failed to parse folder", 60, &xml.SyntaxError{Msg: "unexpected EOF", Line: 2}),
-		},
+		wantErr: newParseError("failed to parse folder", 60, &xml.SyntaxError{Msg: "unexpected EOF", Line: 2}),
+	},
 
-		// error cases detected with fuzzing
-		{
-			tname:   "testdata/fuzz/Fuzz/5e841755a8981807",
-			input:   "<!DOCTYPE NETSCAPE-Bookmark-file-1>0<H1></H1><DL><DL></A>0",
-			wantErr: newParseError("failed to parse folder", 35, ErrFolderTitleEmpty),
-		},
-		{
-			tname:   "testdata/fuzz/Fuzz/8f1a6e2b338c9b72",
-			input:   "<!DOCTYPE NETSCAPE-Bookmark-file-1><H1>0</H1><DL></DL><A></A0>",
-			wantErr: newParseError("failed to parse bookmarks", 62, ErrFolderStructureInvalid),
-		},
-		{
-			tname:   "testdata/fuzz/Fuzz/80aa58f529764f6b",
-			input:   "<!DOCTYPE NETSCAPE-Bookmark-file-1><H1>0</H1><DL><DL></A>0",
-			wantErr: newParseError("failed to parse bookmarks", 45, ErrFolderStructureInvalid),
-		},
-		{
-			tname:   "testdata/fuzz/Fuzz/269c29ee022dd350",
-			input:   "<!DOCTYPE NETSCAPE-Bookmark-file-1><DT><A></A>",
-			wantErr: newParseError("failed to parse bookmarks", 35, ErrRootFolderMissing),
-		},
-		{
-			tname:   "testdata/fuzz/Fuzz/a0db6c3d8126e7b7",
-			input:   "<!DOCTYPE NETSCAPE-Bookmark-file-1><H1></H1><DL><DL></A>",
-			wantErr: newParseError("failed to parse folder", 35, ErrFolderTitleEmpty),
-		},
-		{
-			tname:   "testdata/fuzz/Fuzz/dc4dee8a9080d790",
-			input:   "<!DOCTYPE NETSCAPE-Bookmark-file-1>0<H1></H1><DL><DL></A>0",
-			wantErr: newParseError("failed to parse folder", 35, ErrFolderTitleEmpty),
-		},
-	}
+	// error cases detected with fuzzing
+	{
+		tname:   "testdata/fuzz/Fuzz/5e841755a8981807",
+		input:   "<!DOCTYPE NETSCAPE-Bookmark-file-1>0<H1></H1><DL><DL></A>0",
+		wantErr: newParseError("failed to parse folder", 35, ErrFolderTitleEmpty),
+	},
+	{
+		tname:   "testdata/fuzz/Fuzz/8f1a6e2b338c9b72",
+		input:   "<!DOCTYPE NETSCAPE-Bookmark-file-1><H1>0</H1><DL></DL><A></A0>",
+		wantErr: newParseError("failed to parse bookmarks", 62, ErrFolderStructureInvalid),
+	},
+	{
+		tname:   "testdata/fuzz/Fuzz/80aa58f529764f6b",
+		input:   "<!DOCTYPE NETSCAPE-Bookmark-file-1><H1>0</H1><DL><DL></A>0",
+		wantErr: newParseError("failed to parse bookmarks", 45, ErrFolderStructureInvalid),
+	},
+	{
+		tname:   "testdata/fuzz/Fuzz/269c29ee022dd350",
+		input:   "<!DOCTYPE NETSCAPE-Bookmark-file-1><DT><A></A>",
+		wantErr: newParseError("failed to parse bookmarks", 35, ErrRootFolderMissing),
+	},
+	{
+		tname:   "testdata/fuzz/Fuzz/a0db6c3d8126e7b7",
+		input:   "<!DOCTYPE NETSCAPE-Bookmark-file-1><H1></H1><DL><DL></A>",
+		wantErr: newParseError("failed to parse folder", 35, ErrFolderTitleEmpty),
+	},
+	{
+		tname:   "testdata/fuzz/Fuzz/dc4dee8a9080d790",
+		input:   "<!DOCTYPE NETSCAPE-Bookmark-file-1>0<H1></H1><DL><DL></A>0",
+		wantErr: newParseError("failed to parse folder", 35, ErrFolderTitleEmpty),
+	},
+}
 
-	for _, tc := range cases {
+func TestParse(t *testing.T) {
+	for _, tc := range parseCases {
 		t.Run(tc.tname, func(t *testing.T) {
 			r := strings.NewReader(tc.input)
 
@@ -434,6 +475,92 @@ func TestParseFile(t *testing.T) {
 		inputFilename string
 		want          FileNode
 	}{
+		{
+			tname:         "Firefox (separators)",
+			inputFilename: "firefox_separators.htm",
+			want: FileNode{
+				Title: "Bookmarks",
+				Root: FolderNode{
+					Name: "Bookmarks Menu",
+					Bookmarks: []BookmarkNode{
+						{
+							Href:  "https://www.mozilla.org/en-US/firefox/central/",
+							Title: "Getting Started",
+							Attributes: map[string]string{
+								"ADD_DATE":      "1646154673",
+								"LAST_MODIFIED": "1646154673",
+								"ICON_URI":      "https://www.mozilla.org/favicon.ico",
+							},
+						},
+					},
+					Separators: []SeparatorNode{{}, {}},
+					Order: []ItemRef{
+						{Kind: BookmarkKind, Index: 0},
+						{Kind: SeparatorKind, Index: 0},
+						{Kind: FolderKind, Index: 0},
+						{Kind: SeparatorKind, Index: 1},
+						{Kind: FolderKind, Index: 1},
+					},
+					Subfolders: []FolderNode{
+						{
+							Name:        "Bookmarks Toolbar",
+							Description: "Add bookmarks to this folder to see them displayed on the Bookmarks Toolbar",
+							Attributes: map[string]string{
+								"ADD_DATE":                "1646154600",
+								"LAST_MODIFIED":           "1646154700",
+								"PERSONAL_TOOLBAR_FOLDER": "true",
+							},
+							Bookmarks: []BookmarkNode{
+								{
+									Href:  "https://go.dev/",
+									Title: "The Go Programming Language",
+									Attributes: map[string]string{
+										"ADD_DATE":      "1646154680",
+										"LAST_MODIFIED": "1646154680",
+										"ICON":          "data:image/png;base64,iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNk+M9QDwADhgGAWjR9awAAAABJRU5ErkJggg==",
+									},
+								},
+								{
+									Href:  "https://pkg.go.dev/search?q=%s",
+									Title: "Go Packages",
+									Attributes: map[string]string{
+										"ADD_DATE":      "1646154690",
+										"LAST_MODIFIED": "1646154690",
+										"LAST_VISIT":    "1646155690",
+										"SHORTCUTURL":   "gopkg",
+										"LAST_CHARSET":  "UTF-8",
+									},
+								},
+							},
+							Separators: []SeparatorNode{{}},
+							Order: []ItemRef{
+								{Kind: BookmarkKind, Index: 0},
+								{Kind: SeparatorKind, Index: 0},
+								{Kind: BookmarkKind, Index: 1},
+							},
+						},
+						{
+							Name: "Other Bookmarks",
+							Attributes: map[string]string{
+								"ADD_DATE":                 "1646154600",
+								"LAST_MODIFIED":            "1646154700",
+								"UNFILED_BOOKMARKS_FOLDER": "true",
+							},
+							Bookmarks: []BookmarkNode{
+								{
+									Href:  "https://www.rust-lang.org/",
+									Title: "Rust Programming Language",
+									Attributes: map[string]string{
+										"ADD_DATE":      "1646154695",
+										"LAST_MODIFIED": "1646154695",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
 		{
 			tname:         "Netscape (basic)",
 			inputFilename: "netscape_basic.htm",
@@ -560,6 +687,13 @@ func TestParseFile(t *testing.T) {
 							},
 						},
 					},
+					Order: []ItemRef{
+						{Kind: BookmarkKind, Index: 0},
+						{Kind: FolderKind, Index: 0},
+						{Kind: FolderKind, Index: 1},
+						{Kind: FolderKind, Index: 2},
+						{Kind: BookmarkKind, Index: 1},
+					},
 					Subfolders: []FolderNode{
 						{
 							Name: "Folder1, the first,folder to encounter",
@@ -790,7 +924,339 @@ func assertFolderNodesEqual(t *testing.T, got FolderNode, want FolderNode) {
 		t.Fatalf("want %d subfolders for folder %q, got %d", len(want.Subfolders), want.Name, len(got.Subfolders))
 	}
 
+	if len(got.Separators) != len(want.Separators) {
+		t.Fatalf("want %d separators in folder %q, got %d", len(want.Separators), want.Name, len(got.Separators))
+	}
+
+	if want.Order != nil && !slices.Equal(got.Order, want.Order) {
+		t.Errorf("want folder %q order %v, got %v", want.Name, want.Order, got.Order)
+	}
+
 	for index, wantSubfolder := range want.Subfolders {
 		assertFolderNodesEqual(t, got.Subfolders[index], wantSubfolder)
 	}
 }
+
+func TestParseReader(t *testing.T) {
+	for _, tc := range parseCases {
+		t.Run(tc.tname, func(t *testing.T) {
+			// iotest.OneByteReader hides the io.Seeker interface, and returns
+			// data one byte at a time to exercise buffering.
+			r := iotest.OneByteReader(strings.NewReader(tc.input))
+
+			got, err := ParseReader(r)
+
+			if tc.wantErr != nil {
+				if err == nil {
+					t.Error("expected an error, got none")
+				} else if !errors.Is(err, tc.wantErr) {
+					t.Errorf("want error %q, got %q", tc.wantErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected no error, got %q", err)
+			}
+
+			if got.Title != tc.want.Title {
+				t.Errorf("want title %q, got %q", tc.want.Title, got.Title)
+			}
+
+			assertFolderNodesEqual(t, got.Root, tc.want.Root)
+		})
+	}
+}
+
+func TestParseSpans(t *testing.T) {
+	input := `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DL><p>
+    <DT><A HREF="https://domain.tld">Test Domain</A>
+    <DD>Description
+    <DT><H3>Folder</H3>
+    <DL><p>
+        <DT><A HREF="https://sub.domain.tld">Sub</A>
+    </DL><p>
+</DL><p>
+`
+
+	got, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+
+	cases := []struct {
+		tname string
+		got   Span
+		want  Span
+	}{
+		{
+			tname: "file",
+			got:   got.Span,
+			want: Span{
+				Start: Position{Offset: 0, Line: 1, Column: 1},
+				End:   Position{Offset: 272, Line: 12, Column: 1},
+			},
+		},
+		{
+			tname: "root folder",
+			got:   got.Root.Span,
+			want: Span{
+				Start: Position{Offset: 61, Line: 3, Column: 1},
+				End:   Position{Offset: 268, Line: 11, Column: 6},
+			},
+		},
+		{
+			tname: "bookmark with description",
+			got:   got.Root.Bookmarks[0].Span,
+			want: Span{
+				Start: Position{Offset: 96, Line: 5, Column: 9},
+				End:   Position{Offset: 160, Line: 6, Column: 20},
+			},
+		},
+		{
+			tname: "subfolder",
+			got:   got.Root.Subfolders[0].Span,
+			want: Span{
+				Start: Position{Offset: 169, Line: 7, Column: 9},
+				End:   Position{Offset: 259, Line: 10, Column: 10},
+			},
+		},
+		{
+			tname: "nested bookmark",
+			got:   got.Root.Subfolders[0].Bookmarks[0].Span,
+			want: Span{
+				Start: Position{Offset: 209, Line: 9, Column: 13},
+				End:   Position{Offset: 249, Line: 9, Column: 53},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.tname, func(t *testing.T) {
+			if tc.got != tc.want {
+				t.Errorf("want span %#v, got %#v", tc.want, tc.got)
+			}
+		})
+	}
+}
+
+func TestParseErrorPosition(t *testing.T) {
+	input := `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<TITLE>Bookmarks</TITLE>
+<H1>Bookma`
+
+	_, err := Parse(strings.NewReader(input))
+
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("want a ParseError, got %q", err)
+	}
+
+	if parseErr.Line != 2 {
+		t.Errorf("want line 2, got %d", parseErr.Line)
+	}
+
+	if parseErr.Column != 25 {
+		t.Errorf("want column 25, got %d", parseErr.Column)
+	}
+
+	wantContext := "<TITLE>Bookmarks</TITLE>"
+	if parseErr.Context != wantContext {
+		t.Errorf("want context %q, got %q", wantContext, parseErr.Context)
+	}
+}
+
+func TestParseLenient(t *testing.T) {
+	type wantDiagnostic struct {
+		severity Severity
+		err      error
+	}
+
+	cases := []struct {
+		tname           string
+		input           string
+		want            FileNode
+		wantDiagnostics []wantDiagnostic
+	}{
+		{
+			tname: "valid document",
+			input: `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DL><p>
+    <DT><A HREF="https://domain.tld">Test Domain</A>
+</DL><p>
+`,
+			want: FileNode{
+				Title: "Bookmarks",
+				Root: FolderNode{
+					Name: "Bookmarks",
+					Bookmarks: []BookmarkNode{
+						{Href: "https://domain.tld", Title: "Test Domain"},
+					},
+				},
+			},
+		},
+		{
+			tname: "missing DOCTYPE",
+			input: `<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DL><p>
+    <DT><A HREF="https://domain.tld">Test Domain</A>
+</DL><p>
+`,
+			want: FileNode{
+				Title: "Bookmarks",
+				Root: FolderNode{
+					Name: "Bookmarks",
+					Bookmarks: []BookmarkNode{
+						{Href: "https://domain.tld", Title: "Test Domain"},
+					},
+				},
+			},
+			wantDiagnostics: []wantDiagnostic{
+				{severity: SeverityWarning, err: ErrDoctypeMissing},
+			},
+		},
+		{
+			tname: "empty folder title",
+			input: `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DL><p>
+    <DT><H3></H3>
+    <DL><p>
+        <DT><A HREF="https://domain.tld">Test Domain</A>
+    </DL><p>
+</DL><p>
+`,
+			want: FileNode{
+				Title: "Bookmarks",
+				Root: FolderNode{
+					Name: "Bookmarks",
+					Subfolders: []FolderNode{
+						{
+							Name: "Untitled",
+							Bookmarks: []BookmarkNode{
+								{Href: "https://domain.tld", Title: "Test Domain"},
+							},
+						},
+					},
+				},
+			},
+			wantDiagnostics: []wantDiagnostic{
+				{severity: SeverityWarning, err: ErrFolderTitleEmpty},
+			},
+		},
+		{
+			tname: "orphan closing DL",
+			input: `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DL><p>
+    <DT><A HREF="https://domain.tld">Test Domain</A>
+</DL><p>
+</DL><p>
+<DT><A HREF="https://orphan.domain.tld">Orphan</A>
+`,
+			want: FileNode{
+				Title: "Bookmarks",
+				Root: FolderNode{
+					Name: "Bookmarks",
+					Bookmarks: []BookmarkNode{
+						{Href: "https://domain.tld", Title: "Test Domain"},
+						{Href: "https://orphan.domain.tld", Title: "Orphan"},
+					},
+				},
+			},
+			wantDiagnostics: []wantDiagnostic{
+				{severity: SeverityWarning, err: ErrFolderStructureInvalid},
+				{severity: SeverityWarning, err: ErrFolderStructureInvalid},
+			},
+		},
+		{
+			tname: "missing root folder",
+			input: `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<TITLE>Bookmarks</TITLE>
+<DL><p>
+    <DT><A HREF="https://domain.tld">Test Domain</A>
+</DL><p>
+`,
+			want: FileNode{
+				Title: "Bookmarks",
+				Root: FolderNode{
+					Name: "Bookmarks",
+					Bookmarks: []BookmarkNode{
+						{Href: "https://domain.tld", Title: "Test Domain"},
+					},
+				},
+			},
+			wantDiagnostics: []wantDiagnostic{
+				{severity: SeverityWarning, err: ErrRootFolderMissing},
+			},
+		},
+		{
+			tname: "truncated document",
+			input: `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DL><p>
+    <DT><A HREF="https://domain.tld">Test Domain</A>
+    <DT><A HREF="https://trunc`,
+			want: FileNode{
+				Title: "Bookmarks",
+				Root: FolderNode{
+					Name: "Bookmarks",
+					Bookmarks: []BookmarkNode{
+						{Href: "https://domain.tld", Title: "Test Domain"},
+					},
+				},
+			},
+			wantDiagnostics: []wantDiagnostic{
+				{severity: SeverityError, err: &xml.SyntaxError{}},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.tname, func(t *testing.T) {
+			r := strings.NewReader(tc.input)
+
+			got, diagnostics, err := ParseWithOptions(r, ParseOptions{Mode: Lenient})
+			if err != nil {
+				t.Fatalf("expected no error, got %q", err)
+			}
+
+			if len(diagnostics) != len(tc.wantDiagnostics) {
+				t.Fatalf("want %d diagnostics, got %d: %v", len(tc.wantDiagnostics), len(diagnostics), diagnostics)
+			}
+
+			for index, want := range tc.wantDiagnostics {
+				diagnostic := diagnostics[index]
+
+				if diagnostic.Severity != want.severity {
+					t.Errorf("want diagnostic %d severity %q, got %q", index, want.severity, diagnostic.Severity)
+				}
+
+				var syntaxErr *xml.SyntaxError
+				if errors.As(want.err, &syntaxErr) {
+					if !errors.As(diagnostic.Err, &syntaxErr) {
+						t.Errorf("want diagnostic %d error %q, got %q", index, want.err, diagnostic.Err)
+					}
+				} else if !errors.Is(diagnostic.Err, want.err) {
+					t.Errorf("want diagnostic %d error %q, got %q", index, want.err, diagnostic.Err)
+				}
+			}
+
+			if got.Title != tc.want.Title {
+				t.Errorf("want title %q, got %q", tc.want.Title, got.Title)
+			}
+
+			assertFolderNodesEqual(t, got.Root, tc.want.Root)
+		})
+	}
+}
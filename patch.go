@@ -0,0 +1,776 @@
+// Copyright (c) VirtualTam
+// SPDX-License-Identifier: MIT
+
+package netscape
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+var (
+	ErrPatchConflict = errors.New("patch conflict")
+	ErrPatchInvalid  = errors.New("invalid patch")
+)
+
+// An OperationKind identifies the type of an Operation.
+type OperationKind int
+
+const (
+	// OpAddBookmark adds Bookmark to the Folder with the given Path.
+	OpAddBookmark OperationKind = iota
+
+	// OpRemoveBookmark removes the Bookmark with the given URL from the
+	// Folder with the given Path.
+	OpRemoveBookmark
+
+	// OpMoveBookmark moves the Bookmark with the given URL from the Folder
+	// with the given Path to the Folder with the Path To.
+	OpMoveBookmark
+
+	// OpUpdateBookmark updates the Fields of the Bookmark with the given URL,
+	// in the Folder with the given Path.
+	OpUpdateBookmark
+
+	// OpAddFolder adds a Folder with the given Path, using the attributes of
+	// Folder.
+	OpAddFolder
+
+	// OpRemoveFolder removes the empty Folder with the given Path.
+	OpRemoveFolder
+
+	// OpMoveFolder moves the Folder with the given Path to the Folder with the
+	// Path To.
+	OpMoveFolder
+
+	// OpUpdateFolder updates the Fields of the Folder with the given Path,
+	// including its "name".
+	OpUpdateFolder
+
+	// OpUpdateTitle updates the "title" field of the Document.
+	OpUpdateTitle
+)
+
+var operationKindNames = map[OperationKind]string{
+	OpAddBookmark:    "add_bookmark",
+	OpRemoveBookmark: "remove_bookmark",
+	OpMoveBookmark:   "move_bookmark",
+	OpUpdateBookmark: "update_bookmark",
+	OpAddFolder:      "add_folder",
+	OpRemoveFolder:   "remove_folder",
+	OpMoveFolder:     "move_folder",
+	OpUpdateFolder:   "update_folder",
+	OpUpdateTitle:    "update_title",
+}
+
+// String returns the string representation for this OperationKind.
+func (k OperationKind) String() string {
+	if name, ok := operationKindNames[k]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("OperationKind(%d)", int(k))
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (k OperationKind) MarshalText() ([]byte, error) {
+	if _, ok := operationKindNames[k]; !ok {
+		return nil, fmt.Errorf("invalid operation kind %d", int(k))
+	}
+
+	return []byte(k.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (k *OperationKind) UnmarshalText(text []byte) error {
+	for kind, name := range operationKindNames {
+		if name == string(text) {
+			*k = kind
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown operation kind %q", text)
+}
+
+// An Operation is an edit of a Document.
+//
+// Bookmarks are addressed by the Path of their Folder and their URL, or by
+// their ID; if several Bookmarks match, the first one is selected. Folders are
+// addressed by their Path, or by their ID.
+type Operation struct {
+	Op OperationKind `json:"op"`
+
+	// Path of the target Folder, or of the Folder containing the target
+	// Bookmark.
+	Path Path `json:"path"`
+
+	// URL of the target Bookmark.
+	URL string `json:"url,omitempty"`
+
+	// ID of the target Bookmark or Folder, as returned by Bookmark.ID and
+	// Folder.ID; if set, the target is looked up by ID instead of Path and
+	// URL. Operations addressed by ID should still set Path and URL, which
+	// address the items in their Inverse.
+	ID string `json:"id,omitempty"`
+
+	// To is the Path of the destination Folder of moved items.
+	To Path `json:"to,omitempty"`
+
+	// Bookmark is the added Bookmark, or the expected state of the removed
+	// Bookmark.
+	Bookmark *Bookmark `json:"bookmark,omitempty"`
+
+	// Folder holds the attributes of the added Folder, or the expected state
+	// of the removed Folder, without items.
+	Folder *Folder `json:"folder,omitempty"`
+
+	// Fields lists the updated fields, along with their expected and new
+	// values.
+	Fields []FieldChange `json:"fields,omitempty"`
+}
+
+// A Patch is a sequence of Operations, applied in order.
+type Patch struct {
+	Operations []Operation `json:"operations"`
+}
+
+// A PatchError is returned when an Operation of a Patch cannot be applied.
+type PatchError struct {
+	// Index of the Operation in the Patch.
+	Index int
+
+	Op Operation
+
+	// Err describes why the Operation cannot be applied, and wraps
+	// ErrPatchConflict when the Document does not match the Operation.
+	Err error
+}
+
+// Error returns the string representation for this error.
+func (e *PatchError) Error() string {
+	return fmt.Sprintf("operation %d (%s %q): %s", e.Index, e.Op.Op, FormatPath(e.Op.Path), e.Err)
+}
+
+// Unwrap returns the inner error wrapped by this Error.
+func (e *PatchError) Unwrap() error {
+	return e.Err
+}
+
+// Apply applies the Operations of patch to this Document.
+//
+// Each Operation is checked against the current state of the Document: the
+// target must exist and match the expected state recorded in the Operation,
+// e.g. the Old value of the updated fields, and added items must not already
+// exist. Otherwise, Apply returns a *PatchError wrapping ErrPatchConflict.
+//
+// Patches are applied atomically: if an Operation fails, this Document is
+// left unchanged.
+func (d *Document) Apply(patch *Patch) error {
+	patched := Document{
+		Title: d.Title,
+		Root:  d.Root.clone(),
+	}
+
+	ids := newIDCache()
+
+	for i, op := range patch.Operations {
+		if err := patched.apply(op, ids); err != nil {
+			return &PatchError{Index: i, Op: op, Err: err}
+		}
+	}
+
+	*d = patched
+
+	return nil
+}
+
+func (d *Document) apply(op Operation, ids *idCache) error {
+	if op.ID != "" && (op.Op == OpRemoveFolder || op.Op == OpMoveFolder || op.Op == OpUpdateFolder) {
+		folder, path := d.Root.findFolderByID(Path{}, op.ID, ids)
+		if folder == nil {
+			return fmt.Errorf("%w: %w: %s", ErrPatchConflict, ErrFolderNotFound, op.ID)
+		}
+
+		op.Path = path
+	}
+
+	switch op.Op {
+	case OpAddBookmark:
+		return d.addBookmark(op)
+	case OpRemoveBookmark:
+		return d.removeBookmark(op, ids)
+	case OpMoveBookmark:
+		return d.moveBookmark(op, ids)
+	case OpUpdateBookmark:
+		return d.updateBookmark(op, ids)
+	case OpAddFolder:
+		return d.addFolder(op)
+	case OpRemoveFolder:
+		return d.removeFolder(op)
+	case OpMoveFolder:
+		return d.moveFolder(op)
+	case OpUpdateFolder:
+		return d.updateFolder(op)
+	case OpUpdateTitle:
+		return d.updateTitle(op)
+	default:
+		return fmt.Errorf("%w: unknown operation %d", ErrPatchInvalid, int(op.Op))
+	}
+}
+
+// findPatchFolder returns the Folder with the given Path, or an error wrapping
+// ErrPatchConflict.
+func (d *Document) findPatchFolder(path Path) (*Folder, error) {
+	folder, err := d.FindFolder(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrPatchConflict, err)
+	}
+
+	return folder, nil
+}
+
+// findPatchBookmark returns the Folder containing the Bookmark targeted by op,
+// along with its index.
+//
+// Bookmarks targeted by ID are first looked up in the Folder at op.Path, as
+// Bookmarks with the same URL and creation date share the same ContentID.
+func (d *Document) findPatchBookmark(op Operation, ids *idCache) (*Folder, int, error) {
+	if op.ID != "" {
+		if folder, err := d.FindFolder(op.Path); err == nil {
+			if index := folder.bookmarkIndexByID(op.ID, ids); index >= 0 {
+				return folder, index, nil
+			}
+		}
+
+		folder, _, index := d.Root.findBookmarkByID(Path{}, op.ID, ids)
+		if folder == nil {
+			return nil, 0, fmt.Errorf("%w: %w: %s", ErrPatchConflict, ErrBookmarkNotFound, op.ID)
+		}
+
+		return folder, index, nil
+	}
+
+	folder, err := d.findPatchFolder(op.Path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	index := folder.bookmarkIndex(op.URL)
+	if index < 0 {
+		return nil, 0, fmt.Errorf("%w: bookmark %s not found in %q", ErrPatchConflict, op.URL, FormatPath(op.Path))
+	}
+
+	return folder, index, nil
+}
+
+func (d *Document) addBookmark(op Operation) error {
+	if op.Bookmark == nil {
+		return fmt.Errorf("%w: missing bookmark", ErrPatchInvalid)
+	}
+
+	folder, err := d.findPatchFolder(op.Path)
+	if err != nil {
+		return err
+	}
+
+	if folder.bookmarkIndex(op.Bookmark.URL) >= 0 {
+		return fmt.Errorf("%w: bookmark %s already exists in %q", ErrPatchConflict, op.Bookmark.URL, FormatPath(op.Path))
+	}
+
+	folder.addBookmark(op.Bookmark.clone())
+
+	return nil
+}
+
+func (d *Document) removeBookmark(op Operation, ids *idCache) error {
+	folder, index, err := d.findPatchBookmark(op, ids)
+	if err != nil {
+		return err
+	}
+
+	if op.Bookmark != nil {
+		if changes := bookmarkFields.changes(op.Bookmark, &folder.Bookmarks[index]); len(changes) > 0 {
+			return fmt.Errorf("%w: bookmark %s has been modified: %s", ErrPatchConflict, folder.Bookmarks[index].URL, changes[0].Field)
+		}
+	}
+
+	folder.removeBookmark(index)
+
+	return nil
+}
+
+func (d *Document) moveBookmark(op Operation, ids *idCache) error {
+	folder, index, err := d.findPatchBookmark(op, ids)
+	if err != nil {
+		return err
+	}
+
+	target, err := d.findPatchFolder(op.To)
+	if err != nil {
+		return err
+	}
+
+	if target == folder {
+		return nil
+	}
+
+	bookmark := folder.Bookmarks[index]
+
+	if target.bookmarkIndex(bookmark.URL) >= 0 {
+		return fmt.Errorf("%w: bookmark %s already exists in %q", ErrPatchConflict, bookmark.URL, FormatPath(op.To))
+	}
+
+	folder.removeBookmark(index)
+	target.addBookmark(bookmark)
+
+	return nil
+}
+
+func (d *Document) updateBookmark(op Operation, ids *idCache) error {
+	folder, index, err := d.findPatchBookmark(op, ids)
+	if err != nil {
+		return err
+	}
+
+	return applyFieldChanges(bookmarkFields, &folder.Bookmarks[index], op.Fields)
+}
+
+func (d *Document) addFolder(op Operation) error {
+	if len(op.Path) == 0 {
+		return fmt.Errorf("%w: cannot add the root folder", ErrPatchInvalid)
+	}
+
+	parent, err := d.findPatchFolder(op.Path[:len(op.Path)-1])
+	if err != nil {
+		return err
+	}
+
+	name := op.Path[len(op.Path)-1]
+	if parent.subfolderIndex(name) >= 0 {
+		return fmt.Errorf("%w: folder %q already exists", ErrPatchConflict, FormatPath(op.Path))
+	}
+
+	var folder Folder
+	if op.Folder != nil {
+		folder = op.Folder.header()
+		folder.Attributes = maps.Clone(folder.Attributes)
+	}
+	folder.Name = name
+
+	parent.addSubfolder(folder)
+
+	return nil
+}
+
+func (d *Document) removeFolder(op Operation) error {
+	if len(op.Path) == 0 {
+		return fmt.Errorf("%w: cannot remove the root folder", ErrPatchInvalid)
+	}
+
+	folder, err := d.findPatchFolder(op.Path)
+	if err != nil {
+		return err
+	}
+
+	if len(folder.Bookmarks) > 0 || len(folder.Subfolders) > 0 {
+		return fmt.Errorf("%w: folder %q is not empty", ErrPatchConflict, FormatPath(op.Path))
+	}
+
+	if op.Folder != nil {
+		if changes := folderFields.changes(op.Folder, folder); len(changes) > 0 {
+			return fmt.Errorf("%w: folder %q has been modified: %s", ErrPatchConflict, FormatPath(op.Path), changes[0].Field)
+		}
+	}
+
+	return d.RemoveFolder(op.Path)
+}
+
+func (d *Document) moveFolder(op Operation) error {
+	if len(op.Path) == 0 {
+		return fmt.Errorf("%w: cannot move the root folder", ErrPatchInvalid)
+	}
+
+	if _, err := d.findPatchFolder(op.Path); err != nil {
+		return err
+	}
+
+	target, err := d.findPatchFolder(op.To)
+	if err != nil {
+		return err
+	}
+
+	if target.subfolderIndex(op.Path[len(op.Path)-1]) >= 0 {
+		return fmt.Errorf("%w: folder %q already exists in %q", ErrPatchConflict, op.Path[len(op.Path)-1], FormatPath(op.To))
+	}
+
+	return d.Move(op.Path, op.To)
+}
+
+func (d *Document) updateFolder(op Operation) error {
+	folder, err := d.findPatchFolder(op.Path)
+	if err != nil {
+		return err
+	}
+
+	fields := op.Fields
+
+	if index := slices.IndexFunc(fields, func(c FieldChange) bool { return c.Field == "name" }); index >= 0 {
+		change := fields[index]
+
+		if change.Old != folder.Name {
+			return fmt.Errorf("%w: field %q is %q, expected %q", ErrPatchConflict, change.Field, folder.Name, change.Old)
+		}
+
+		if change.New == "" {
+			return fmt.Errorf("%w: empty folder name", ErrPatchInvalid)
+		}
+
+		if len(op.Path) > 0 {
+			parent, _ := d.FindFolder(op.Path[:len(op.Path)-1])
+			if change.New != change.Old && parent.subfolderIndex(change.New) >= 0 {
+				return fmt.Errorf("%w: folder %q already exists", ErrPatchConflict, change.New)
+			}
+		}
+
+		fields = slices.Delete(slices.Clone(fields), index, index+1)
+
+		updated := *folder
+		if err := applyFieldChanges(folderFields, &updated, fields); err != nil {
+			return err
+		}
+
+		updated.Name = change.New
+		*folder = updated
+
+		return nil
+	}
+
+	return applyFieldChanges(folderFields, folder, fields)
+}
+
+func (d *Document) updateTitle(op Operation) error {
+	for _, change := range op.Fields {
+		if change.Field != "title" {
+			return fmt.Errorf("%w: unknown field %q", ErrPatchInvalid, change.Field)
+		}
+
+		if change.Old != d.Title {
+			return fmt.Errorf("%w: field %q is %q, expected %q", ErrPatchConflict, change.Field, d.Title, change.Old)
+		}
+
+		d.Title = change.New
+	}
+
+	return nil
+}
+
+// applyFieldChanges checks that the fields of item have the Old value of each
+// change, then sets their New value.
+func applyFieldChanges[T any](fields itemFields[T], item *T, changes []FieldChange) error {
+	for _, change := range changes {
+		value, err := fields.get(item, change.Field)
+		if err != nil {
+			return err
+		}
+
+		if value != change.Old {
+			return fmt.Errorf("%w: field %q is %q, expected %q", ErrPatchConflict, change.Field, value, change.Old)
+		}
+	}
+
+	for _, change := range changes {
+		if err := fields.set(item, change.Field, change.New); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Inverse returns the Patch that reverts the Operations of this Patch, in
+// reverse order.
+//
+// Reverted removals add the removed items after the existing items of their
+// Folder, so that the order of items may differ from the original Document.
+func (p *Patch) Inverse() Patch {
+	inverse := Patch{
+		Operations: make([]Operation, 0, len(p.Operations)),
+	}
+
+	for _, op := range slices.Backward(p.Operations) {
+		inverse.Operations = append(inverse.Operations, op.inverse())
+	}
+
+	return inverse
+}
+
+// inverse returns the Operation that reverts this Operation.
+func (op Operation) inverse() Operation {
+	switch op.Op {
+	case OpAddBookmark:
+		inverse := Operation{Op: OpRemoveBookmark, Path: op.Path, Bookmark: op.Bookmark}
+		if op.Bookmark != nil {
+			inverse.URL = op.Bookmark.URL
+		}
+		return inverse
+	case OpRemoveBookmark:
+		return Operation{Op: OpAddBookmark, Path: op.Path, Bookmark: op.Bookmark}
+	case OpMoveBookmark:
+		return Operation{Op: OpMoveBookmark, Path: op.To, URL: op.URL, To: op.Path}
+	case OpUpdateBookmark:
+		inverse := Operation{Op: OpUpdateBookmark, Path: op.Path, URL: op.URL, Fields: invertFieldChanges(op.Fields)}
+		for _, change := range op.Fields {
+			if change.Field == "url" {
+				inverse.URL = change.New
+			}
+		}
+		return inverse
+	case OpAddFolder:
+		return Operation{Op: OpRemoveFolder, Path: op.Path, Folder: op.Folder}
+	case OpRemoveFolder:
+		return Operation{Op: OpAddFolder, Path: op.Path, Folder: op.Folder}
+	case OpMoveFolder:
+		if len(op.Path) == 0 {
+			return op
+		}
+		return Operation{
+			Op:   OpMoveFolder,
+			Path: append(slices.Clone(op.To), op.Path[len(op.Path)-1]),
+			To:   op.Path[:len(op.Path)-1],
+		}
+	case OpUpdateFolder:
+		inverse := Operation{Op: OpUpdateFolder, Path: op.Path, Fields: invertFieldChanges(op.Fields)}
+		for _, change := range op.Fields {
+			if change.Field == "name" && len(op.Path) > 0 {
+				inverse.Path = append(slices.Clone(op.Path[:len(op.Path)-1]), change.New)
+			}
+		}
+		return inverse
+	case OpUpdateTitle:
+		return Operation{Op: OpUpdateTitle, Fields: invertFieldChanges(op.Fields)}
+	default:
+		return op
+	}
+}
+
+func invertFieldChanges(changes []FieldChange) []FieldChange {
+	inverse := make([]FieldChange, 0, len(changes))
+	for _, change := range changes {
+		inverse = append(inverse, FieldChange{Field: change.Field, Old: change.New, New: change.Old})
+	}
+
+	return inverse
+}
+
+// Patch returns the Patch that applies the changes of this Changeset to the
+// old Document to obtain the new Document, except for the order of items.
+//
+// Operations are ordered so that the Paths they refer to are valid when they
+// are applied: Folders are added, moved and renamed first, parents first,
+// followed by the Bookmark operations, then by the removal of Folders,
+// children first.
+//
+// Operations on existing Bookmarks are addressed by their ID in the old
+// Document, so that Bookmarks sharing the same URL are told apart.
+func (c Changeset) Patch() Patch {
+	var (
+		patch    Patch
+		rewrites pathRewrites
+	)
+
+	// folders are added and moved after their new parent
+	structural := slices.Clone(c.Folders)
+	slices.SortStableFunc(structural, func(a, b FolderChange) int {
+		return len(a.NewPath) - len(b.NewPath)
+	})
+
+	for _, change := range structural {
+		switch change.Kind {
+		case ChangeAdded:
+			patch.add(Operation{Op: OpAddFolder, Path: change.NewPath, Folder: change.New})
+		case ChangeMoved, ChangeRenamed:
+			if len(change.NewPath) == 0 {
+				// renamed Root Folder
+				patch.add(Operation{Op: OpUpdateFolder, Path: Path{}, Fields: change.Fields})
+				continue
+			}
+
+			current := rewrites.apply(change.OldPath)
+			if slices.Equal(current, change.NewPath) {
+				// already moved and renamed
+				continue
+			}
+
+			parent := change.NewPath[:len(change.NewPath)-1]
+			path := current
+
+			if !slices.Equal(current[:len(current)-1], parent) {
+				patch.add(Operation{Op: OpMoveFolder, Path: current, To: parent})
+				path = append(slices.Clone(parent), current[len(current)-1])
+			}
+
+			if oldName, newName := path[len(path)-1], change.NewPath[len(change.NewPath)-1]; oldName != newName {
+				patch.add(Operation{
+					Op:     OpUpdateFolder,
+					Path:   path,
+					Fields: []FieldChange{{Field: "name", Old: oldName, New: newName}},
+				})
+			}
+
+			rewrites = append(rewrites, pathRewrite{from: current, to: change.NewPath})
+		}
+	}
+
+	for _, change := range c.Folders {
+		if change.Kind == ChangeModified {
+			patch.add(Operation{Op: OpUpdateFolder, Path: change.NewPath, Fields: change.Fields})
+		}
+	}
+
+	for _, kind := range []ChangeKind{ChangeRemoved, ChangeMoved, ChangeModified, ChangeAdded} {
+		for _, change := range c.Bookmarks {
+			if change.Kind != kind {
+				continue
+			}
+
+			switch change.Kind {
+			case ChangeRemoved:
+				patch.add(Operation{Op: OpRemoveBookmark, Path: rewrites.apply(change.OldPath), URL: change.Old.URL, ID: change.ID, Bookmark: change.Old})
+			case ChangeMoved:
+				patch.add(Operation{Op: OpMoveBookmark, Path: rewrites.apply(change.OldPath), URL: change.Old.URL, ID: change.ID, To: change.NewPath})
+			case ChangeModified:
+				patch.add(Operation{Op: OpUpdateBookmark, Path: change.NewPath, URL: change.Old.URL, ID: change.ID, Fields: change.Fields})
+			case ChangeAdded:
+				patch.add(Operation{Op: OpAddBookmark, Path: change.NewPath, Bookmark: change.New})
+			}
+		}
+	}
+
+	// folders are removed after their children
+	removed := slices.Clone(c.Folders)
+	slices.SortStableFunc(removed, func(a, b FolderChange) int {
+		return len(b.OldPath) - len(a.OldPath)
+	})
+
+	for _, change := range removed {
+		if change.Kind == ChangeRemoved {
+			patch.add(Operation{Op: OpRemoveFolder, Path: rewrites.apply(change.OldPath), Folder: change.Old})
+		}
+	}
+
+	if c.Title != nil {
+		patch.add(Operation{Op: OpUpdateTitle, Fields: []FieldChange{*c.Title}})
+	}
+
+	return patch
+}
+
+func (p *Patch) add(op Operation) {
+	p.Operations = append(p.Operations, op)
+}
+
+// A pathRewrite records that the Folder with the Path from has been moved to
+// the Path to.
+type pathRewrite struct {
+	from Path
+	to   Path
+}
+
+type pathRewrites []pathRewrite
+
+// apply returns the current Path of the Folder with the given Path, once the
+// rewrites have been applied.
+func (r pathRewrites) apply(path Path) Path {
+	for _, rewrite := range r {
+		if len(path) >= len(rewrite.from) && slices.Equal(path[:len(rewrite.from)], rewrite.from) {
+			path = append(slices.Clone(rewrite.to), path[len(rewrite.from):]...)
+		}
+	}
+
+	return path
+}
+
+// bookmarkIndex returns the index of the first Bookmark with the given URL,
+// following Order, or -1 if there is none.
+func (f *Folder) bookmarkIndex(url string) int {
+	for _, ref := range f.orderedItems() {
+		if ref.Kind == BookmarkKind && f.Bookmarks[ref.Index].URL == url {
+			return ref.Index
+		}
+	}
+
+	return -1
+}
+
+// bookmarkIndexByID returns the index of the first Bookmark with the given ID,
+// following Order, or -1 if there is none.
+func (f *Folder) bookmarkIndexByID(id string, ids *idCache) int {
+	for _, ref := range f.orderedItems() {
+		if ref.Kind == BookmarkKind && ids.bookmarkID(&f.Bookmarks[ref.Index]) == id {
+			return ref.Index
+		}
+	}
+
+	return -1
+}
+
+// addBookmark adds bookmark after the existing items.
+func (f *Folder) addBookmark(bookmark Bookmark) {
+	f.Order = f.orderedItems()
+
+	f.Bookmarks = append(f.Bookmarks, bookmark)
+	f.Order = append(f.Order, ItemRef{Kind: BookmarkKind, Index: len(f.Bookmarks) - 1})
+}
+
+// removeBookmark removes the Bookmark with the given index, and updates Order
+// accordingly.
+func (f *Folder) removeBookmark(index int) {
+	f.Bookmarks = slices.Delete(f.Bookmarks, index, index+1)
+	f.removeItemRef(BookmarkKind, index)
+}
+
+// clone returns a deep copy of this Bookmark.
+func (b *Bookmark) clone() Bookmark {
+	bookmark := *b
+	bookmark.Tags = slices.Clone(b.Tags)
+	bookmark.Icon.Data = slices.Clone(b.Icon.Data)
+	bookmark.Attributes = maps.Clone(b.Attributes)
+
+	return bookmark
+}
+
+// clone returns a deep copy of this Folder.
+func (f *Folder) clone() Folder {
+	folder := *f
+	folder.Attributes = maps.Clone(f.Attributes)
+	folder.Order = slices.Clone(f.Order)
+
+	folder.Bookmarks = nil
+	if f.Bookmarks != nil {
+		folder.Bookmarks = make([]Bookmark, 0, len(f.Bookmarks))
+	}
+	for i := range f.Bookmarks {
+		folder.Bookmarks = append(folder.Bookmarks, f.Bookmarks[i].clone())
+	}
+
+	folder.Separators = nil
+	if f.Separators != nil {
+		folder.Separators = make([]Separator, 0, len(f.Separators))
+	}
+	for _, separator := range f.Separators {
+		folder.Separators = append(folder.Separators, Separator{Attributes: maps.Clone(separator.Attributes)})
+	}
+
+	folder.Subfolders = nil
+	if f.Subfolders != nil {
+		folder.Subfolders = make([]Folder, 0, len(f.Subfolders))
+	}
+	for i := range f.Subfolders {
+		folder.Subfolders = append(folder.Subfolders, f.Subfolders[i].clone())
+	}
+
+	return folder
+}
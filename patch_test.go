@@ -0,0 +1,373 @@
+// Copyright (c) VirtualTam
+// SPDX-License-Identifier: MIT
+
+package netscape
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApplyChangesetPatch(t *testing.T) {
+	oldDoc := loadTestDocument(t, "diff_old.htm")
+	newDoc := loadTestDocument(t, "diff_new.htm")
+
+	changeset := Diff(oldDoc, newDoc)
+	patch := changeset.Patch()
+
+	patched := loadTestDocument(t, "diff_old.htm")
+
+	if err := patched.Apply(&patch); err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+
+	if changeset := Diff(patched, newDoc); changeset.Len() != 0 {
+		t.Errorf("want no changes once patched, got:\n%s", changeset.String())
+	}
+
+	inverse := patch.Inverse()
+
+	if err := patched.Apply(&inverse); err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+
+	if changeset := Diff(patched, oldDoc); changeset.Len() != 0 {
+		t.Errorf("want no changes once reverted, got:\n%s", changeset.String())
+	}
+}
+
+func TestApplyChangesetPatchDuplicateURLs(t *testing.T) {
+	first := Bookmark{URL: "https://a.tld", Title: "First", CreatedAt: time.Date(2023, time.March, 1, 12, 0, 0, 0, time.UTC)}
+	second := Bookmark{URL: "https://a.tld", Title: "Second", CreatedAt: time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)}
+	other := Bookmark{URL: "https://b.tld", Title: "Other"}
+
+	editedSecond := second
+	editedSecond.Title = "Second, edited"
+
+	newDocument := func(bookmarks ...Bookmark) *Document {
+		return &Document{
+			Root: Folder{
+				Subfolders: []Folder{{Name: "Dev", Bookmarks: bookmarks}},
+			},
+		}
+	}
+
+	cases := []struct {
+		tname  string
+		newDoc *Document
+	}{
+		{
+			tname:  "remove second",
+			newDoc: newDocument(first, other),
+		},
+		{
+			tname:  "remove first",
+			newDoc: newDocument(second, other),
+		},
+		{
+			tname:  "modify second",
+			newDoc: newDocument(first, editedSecond, other),
+		},
+		{
+			tname: "move second",
+			newDoc: &Document{
+				Root: Folder{
+					Bookmarks:  []Bookmark{second},
+					Subfolders: []Folder{{Name: "Dev", Bookmarks: []Bookmark{first, other}}},
+				},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.tname, func(t *testing.T) {
+			oldDoc := newDocument(first, second, other)
+
+			patch := Diff(oldDoc, tc.newDoc).Patch()
+
+			if err := oldDoc.Apply(&patch); err != nil {
+				t.Fatalf("expected no error, got %q", err)
+			}
+
+			if changeset := Diff(oldDoc, tc.newDoc); changeset.Len() != 0 {
+				t.Errorf("want no changes once patched, got:\n%s", changeset.String())
+			}
+		})
+	}
+}
+
+func TestApplyPatchFromEmptyDocument(t *testing.T) {
+	newDoc := loadTestDocument(t, "netscape_extended.htm")
+
+	changeset := Diff(nil, newDoc)
+	patch := changeset.Patch()
+
+	patched := &Document{}
+
+	if err := patched.Apply(&patch); err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+
+	if changeset := Diff(patched, newDoc); changeset.Len() != 0 {
+		t.Errorf("want no changes once patched, got:\n%s", changeset.String())
+	}
+}
+
+func TestApplyPatchConflict(t *testing.T) {
+	cases := []struct {
+		tname     string
+		operation Operation
+		wantErr   error
+	}{
+		{
+			tname:     "bookmark folder not found",
+			operation: Operation{Op: OpRemoveBookmark, Path: Path{"Unknown"}, URL: "https://go.dev"},
+			wantErr:   ErrPatchConflict,
+		},
+		{
+			tname:     "bookmark not found",
+			operation: Operation{Op: OpUpdateBookmark, Path: Path{"Dev"}, URL: "https://unknown.domain.tld"},
+			wantErr:   ErrPatchConflict,
+		},
+		{
+			tname:     "bookmark already exists",
+			operation: Operation{Op: OpAddBookmark, Path: Path{"Dev"}, Bookmark: &Bookmark{URL: "https://go.dev"}},
+			wantErr:   ErrPatchConflict,
+		},
+		{
+			tname:     "destination folder not found",
+			operation: Operation{Op: OpMoveBookmark, Path: Path{"Dev"}, URL: "https://go.dev", To: Path{"Unknown"}},
+			wantErr:   ErrPatchConflict,
+		},
+		{
+			tname: "field value mismatch",
+			operation: Operation{
+				Op:     OpUpdateBookmark,
+				Path:   Path{"Dev"},
+				URL:    "https://go.dev",
+				Fields: []FieldChange{{Field: "title", Old: "Golang", New: "Go!"}},
+			},
+			wantErr: ErrPatchConflict,
+		},
+		{
+			tname: "removed bookmark modified",
+			operation: Operation{
+				Op:       OpRemoveBookmark,
+				Path:     Path{"Dev"},
+				URL:      "https://go.dev",
+				Bookmark: &Bookmark{Title: "Golang", URL: "https://go.dev", Tags: []string{"golang"}},
+			},
+			wantErr: ErrPatchConflict,
+		},
+		{
+			tname:     "folder already exists",
+			operation: Operation{Op: OpAddFolder, Path: Path{"Dev"}},
+			wantErr:   ErrPatchConflict,
+		},
+		{
+			tname:     "folder not empty",
+			operation: Operation{Op: OpRemoveFolder, Path: Path{"Dev", "Tools"}},
+			wantErr:   ErrPatchConflict,
+		},
+		{
+			tname: "folder name conflict",
+			operation: Operation{
+				Op:     OpUpdateFolder,
+				Path:   Path{"Dev"},
+				Fields: []FieldChange{{Field: "name", Old: "Dev", New: "Old"}},
+			},
+			wantErr: ErrPatchConflict,
+		},
+		{
+			tname:     "title mismatch",
+			operation: Operation{Op: OpUpdateTitle, Fields: []FieldChange{{Field: "title", Old: "Favorites", New: "Links"}}},
+			wantErr:   ErrPatchConflict,
+		},
+		{
+			tname: "unknown field",
+			operation: Operation{
+				Op:     OpUpdateBookmark,
+				Path:   Path{"Dev"},
+				URL:    "https://go.dev",
+				Fields: []FieldChange{{Field: "color", New: "blue"}},
+			},
+			wantErr: ErrPatchInvalid,
+		},
+		{
+			tname:     "unknown operation",
+			operation: Operation{Op: OperationKind(42)},
+			wantErr:   ErrPatchInvalid,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.tname, func(t *testing.T) {
+			document := loadTestDocument(t, "diff_old.htm")
+			want := loadTestDocument(t, "diff_old.htm")
+
+			patch := Patch{
+				Operations: []Operation{
+					{
+						Op:     OpUpdateTitle,
+						Fields: []FieldChange{{Field: "title", Old: "Bookmarks", New: "Patched"}},
+					},
+					tc.operation,
+				},
+			}
+
+			err := document.Apply(&patch)
+
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("want error %q, got %q", tc.wantErr, err)
+			}
+
+			var patchErr *PatchError
+			if !errors.As(err, &patchErr) || patchErr.Index != 1 {
+				t.Errorf("want PatchError for operation 1, got %q", err)
+			}
+
+			// the Document is left unchanged
+			if changeset := Diff(document, want); changeset.Len() != 0 {
+				t.Errorf("want no changes, got:\n%s", changeset.String())
+			}
+		})
+	}
+}
+
+func TestApplyPatchByID(t *testing.T) {
+	document := loadTestDocument(t, "diff_old.htm")
+
+	packages := Bookmark{Title: "Packages", URL: "https://pkg.go.dev"}
+	tools := Folder{Name: "Tools"}
+
+	patch := Patch{
+		Operations: []Operation{
+			{
+				Op:     OpUpdateBookmark,
+				ID:     packages.ID(),
+				Fields: []FieldChange{{Field: "title", Old: "Packages", New: "Go Packages"}},
+			},
+			{
+				Op: OpMoveBookmark,
+				ID: packages.ID(),
+				To: Path{"Archive"},
+			},
+			{
+				Op:     OpUpdateFolder,
+				ID:     tools.ID(),
+				Fields: []FieldChange{{Field: "name", Old: "Tools", New: "Linters"}},
+			},
+		},
+	}
+
+	if err := document.Apply(&patch); err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+
+	path, bookmark, err := document.FindBookmarkByID(packages.ID())
+	if err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+
+	if !slices.Equal(path, Path{"Archive"}) || bookmark.Title != "Go Packages" {
+		t.Errorf("want bookmark %q in %q, got %q in %q", "Go Packages", "Archive", bookmark.Title, path)
+	}
+
+	if _, err := document.FindFolder(Path{"Dev", "Linters"}); err != nil {
+		t.Errorf("expected no error, got %q", err)
+	}
+
+	// the ID of a Folder without creation date derives from its name
+	patch = Patch{Operations: []Operation{{Op: OpRemoveFolder, ID: tools.ID()}}}
+
+	if err := document.Apply(&patch); !errors.Is(err, ErrPatchConflict) {
+		t.Errorf("want error %q, got %q", ErrPatchConflict, err)
+	}
+
+	// conflicts report the URL of the Bookmark found by ID
+	patch = Patch{Operations: []Operation{{Op: OpRemoveBookmark, ID: packages.ID(), Bookmark: &packages}}}
+
+	err = document.Apply(&patch)
+	if !errors.Is(err, ErrPatchConflict) {
+		t.Fatalf("want error %q, got %q", ErrPatchConflict, err)
+	}
+
+	if !strings.Contains(err.Error(), "bookmark "+packages.URL+" has been modified") {
+		t.Errorf("want error mentioning %s, got %q", packages.URL, err)
+	}
+}
+
+func TestApplyPatchTagsWithCommas(t *testing.T) {
+	oldDoc := &Document{
+		Root: Folder{
+			Bookmarks: []Bookmark{
+				{URL: "https://go.dev", Tags: []string{"go"}},
+			},
+		},
+	}
+	newDoc := &Document{
+		Root: Folder{
+			Bookmarks: []Bookmark{
+				{URL: "https://go.dev", Tags: []string{"dev, ops", "go"}},
+			},
+		},
+	}
+
+	changeset := Diff(oldDoc, newDoc)
+
+	want := []FieldChange{{Field: "tags", Old: `["go"]`, New: `["dev, ops","go"]`}}
+	if got := changeset.Bookmarks[0].Fields; !slices.Equal(got, want) {
+		t.Errorf("want field changes %v, got %v", want, got)
+	}
+
+	patch := changeset.Patch()
+	if err := oldDoc.Apply(&patch); err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+
+	if got := oldDoc.Root.Bookmarks[0].Tags; !slices.Equal(got, newDoc.Root.Bookmarks[0].Tags) {
+		t.Errorf("want tags %q, got %q", newDoc.Root.Bookmarks[0].Tags, got)
+	}
+}
+
+func TestPatchJSON(t *testing.T) {
+	oldDoc := loadTestDocument(t, "diff_old.htm")
+	newDoc := loadTestDocument(t, "diff_new.htm")
+
+	changeset := Diff(oldDoc, newDoc)
+	patch := changeset.Patch()
+
+	data, err := json.Marshal(&patch)
+	if err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+
+	var got Patch
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("failed to unmarshal JSON patch: %s", err)
+	}
+
+	if len(got.Operations) != len(patch.Operations) {
+		t.Fatalf("want %d operations, got %d", len(patch.Operations), len(got.Operations))
+	}
+
+	for i, op := range patch.Operations {
+		if got.Operations[i].Op != op.Op {
+			t.Errorf("want operation %d of kind %s, got %s", i, op.Op, got.Operations[i].Op)
+		}
+	}
+
+	patched := loadTestDocument(t, "diff_old.htm")
+
+	if err := patched.Apply(&got); err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+
+	if changeset := Diff(patched, newDoc); changeset.Len() != 0 {
+		t.Errorf("want no changes once patched, got:\n%s", changeset.String())
+	}
+}
@@ -0,0 +1,377 @@
+// Copyright (c) VirtualTam
+// SPDX-License-Identifier: MIT
+
+package netscape
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrPathInvalid    = errors.New("invalid path")
+	ErrFolderNotFound = errors.New("folder not found")
+)
+
+// A Path identifies a Folder by the names of the folders leading to it from the
+// Root Folder, which has an empty Path.
+//
+// The string representation of a Path, as returned by FormatPath, joins
+// folder names with "/", escaping
+// "/" and "\" characters in names with a backslash: the Path of a folder
+// named "A/B" in a folder named "Misc" is written "Misc/A\/B".
+type Path []string
+
+const (
+	pathSeparator byte = '/'
+	pathEscape    byte = '\\'
+)
+
+// ParsePath returns the Path corresponding to the given string representation.
+//
+// Leading and trailing separators are ignored, so that "", "/" and "/Misc/"
+// are valid paths. Empty folder names and invalid escape sequences return
+// ErrPathInvalid.
+func ParsePath(s string) (Path, error) {
+	s = strings.TrimPrefix(s, string(pathSeparator))
+
+	path := Path{}
+	if s == "" {
+		return path, nil
+	}
+
+	var name strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case pathEscape:
+			if i+1 == len(s) || (s[i+1] != pathEscape && s[i+1] != pathSeparator) {
+				return Path{}, fmt.Errorf("%w: invalid escape sequence at position %d in %q", ErrPathInvalid, i, s)
+			}
+
+			i++
+			name.WriteByte(s[i])
+		case pathSeparator:
+			if name.Len() == 0 {
+				return Path{}, fmt.Errorf("%w: empty folder name in %q", ErrPathInvalid, s)
+			}
+
+			path = append(path, name.String())
+			name.Reset()
+
+			if i == len(s)-1 {
+				// trailing separator
+				return path, nil
+			}
+		default:
+			name.WriteByte(s[i])
+		}
+	}
+
+	if name.Len() == 0 {
+		return Path{}, fmt.Errorf("%w: empty folder name in %q", ErrPathInvalid, s)
+	}
+
+	return append(path, name.String()), nil
+}
+
+// FormatPath returns the string representation of path, that can be parsed
+// with ParsePath.
+func FormatPath(path Path) string {
+	var b strings.Builder
+
+	for index, name := range path {
+		if index > 0 {
+			b.WriteByte(pathSeparator)
+		}
+
+		for i := 0; i < len(name); i++ {
+			if name[i] == pathSeparator || name[i] == pathEscape {
+				b.WriteByte(pathEscape)
+			}
+			b.WriteByte(name[i])
+		}
+	}
+
+	return b.String()
+}
+
+// FindFolder returns the Folder with the given Path, or ErrFolderNotFound.
+func (d *Document) FindFolder(path Path) (*Folder, error) {
+	return d.Root.FindFolder(path)
+}
+
+// EnsureFolder returns the Folder with the given Path, creating it and its
+// missing parent folders as needed.
+func (d *Document) EnsureFolder(path Path, opts ...EnsureFolderOption) (*Folder, error) {
+	return d.Root.EnsureFolder(path, opts...)
+}
+
+// Move moves the Folder with the Path src into the existing Folder with the
+// Path dst.
+func (d *Document) Move(src, dst Path) error {
+	return d.Root.Move(src, dst)
+}
+
+// MoveBookmark moves the first Bookmark with the given URL from the Folder
+// with the Path src into the existing Folder with the Path dst.
+func (d *Document) MoveBookmark(src Path, url string, dst Path) error {
+	return d.Root.MoveBookmark(src, url, dst)
+}
+
+// RenameFolder renames the Folder with the given Path.
+func (d *Document) RenameFolder(path Path, name string) error {
+	return d.Root.RenameFolder(path, name)
+}
+
+// RemoveFolder removes the Folder with the given Path, along with its content.
+func (d *Document) RemoveFolder(path Path) error {
+	return d.Root.RemoveFolder(path)
+}
+
+// FindFolder returns the Folder with the given Path, relative to this Folder,
+// or ErrFolderNotFound.
+//
+// If several Subfolders have the same name, the first one is selected.
+func (f *Folder) FindFolder(path Path) (*Folder, error) {
+	folder := f
+
+	for depth, name := range path {
+		index := folder.subfolderIndex(name)
+		if index < 0 {
+			return nil, fmt.Errorf("%w: %q", ErrFolderNotFound, FormatPath(path[:depth+1]))
+		}
+
+		folder = &folder.Subfolders[index]
+	}
+
+	return folder, nil
+}
+
+type ensureFolderOptions struct {
+	clock func() time.Time
+}
+
+// An EnsureFolderOption configures how EnsureFolder creates folders.
+type EnsureFolderOption func(*ensureFolderOptions)
+
+// WithFolderClock sets the function used to get the current time, to which the
+// creation and update dates of created folders are set.
+//
+// Defaults to time.Now.
+func WithFolderClock(clock func() time.Time) EnsureFolderOption {
+	return func(o *ensureFolderOptions) {
+		o.clock = clock
+	}
+}
+
+// EnsureFolder returns the Folder with the given Path, relative to this
+// Folder, creating it and its missing parent folders as needed.
+//
+// Created folders are added after the existing items of their parent, and
+// their creation and update dates are set to the current time.
+//
+// As Subfolders may be reallocated, previously returned Folder pointers may
+// no longer reference folders of this Folder once EnsureFolder returns.
+func (f *Folder) EnsureFolder(path Path, opts ...EnsureFolderOption) (*Folder, error) {
+	if slices.Contains(path, "") {
+		return nil, fmt.Errorf("%w: empty folder name in %q", ErrPathInvalid, FormatPath(path))
+	}
+
+	options := ensureFolderOptions{
+		clock: time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	now := options.clock().UTC()
+	folder := f
+
+	for _, name := range path {
+		index := folder.subfolderIndex(name)
+		if index < 0 {
+			index = folder.addSubfolder(Folder{
+				CreatedAt: now,
+				UpdatedAt: now,
+				Name:      name,
+			})
+		}
+
+		folder = &folder.Subfolders[index]
+	}
+
+	return folder, nil
+}
+
+// Move moves the Folder with the Path src into the existing Folder with the
+// Path dst, both relative to this Folder.
+//
+// The moved Folder is added after the existing items of dst. It cannot be
+// moved into itself or one of its Subfolders.
+func (f *Folder) Move(src, dst Path) error {
+	if len(src) == 0 {
+		return fmt.Errorf("%w: cannot move the root folder", ErrPathInvalid)
+	}
+
+	if len(dst) >= len(src) && slices.Equal(dst[:len(src)], src) {
+		return fmt.Errorf("%w: cannot move %q into itself", ErrPathInvalid, FormatPath(src))
+	}
+
+	parent, index, err := f.findSubfolder(src)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.FindFolder(dst); err != nil {
+		return err
+	}
+
+	moved := parent.Subfolders[index]
+	parent.removeSubfolder(index)
+
+	// dst may have been shifted by the removal
+	target, err := f.FindFolder(dst)
+	if err != nil {
+		return err
+	}
+
+	target.addSubfolder(moved)
+
+	return nil
+}
+
+// MoveBookmark moves the first Bookmark with the given URL, following Order,
+// from the Folder with the Path src into the existing Folder with the Path dst,
+// both relative to this Folder.
+//
+// The moved Bookmark is added after the existing items of dst.
+func (f *Folder) MoveBookmark(src Path, url string, dst Path) error {
+	source, err := f.FindFolder(src)
+	if err != nil {
+		return err
+	}
+
+	index := source.bookmarkIndex(url)
+	if index < 0 {
+		return fmt.Errorf("%w: %s in %q", ErrBookmarkNotFound, url, FormatPath(src))
+	}
+
+	target, err := f.FindFolder(dst)
+	if err != nil {
+		return err
+	}
+
+	moved := source.Bookmarks[index]
+	source.removeBookmark(index)
+	target.addBookmark(moved)
+
+	return nil
+}
+
+// RenameFolder renames the Folder with the given Path, relative to this
+// Folder.
+func (f *Folder) RenameFolder(path Path, name string) error {
+	if name == "" {
+		return fmt.Errorf("%w: empty folder name", ErrPathInvalid)
+	}
+
+	folder, err := f.FindFolder(path)
+	if err != nil {
+		return err
+	}
+
+	folder.Name = name
+
+	return nil
+}
+
+// RemoveFolder removes the Folder with the given Path, relative to this
+// Folder, along with its content.
+func (f *Folder) RemoveFolder(path Path) error {
+	if len(path) == 0 {
+		return fmt.Errorf("%w: cannot remove the root folder", ErrPathInvalid)
+	}
+
+	parent, index, err := f.findSubfolder(path)
+	if err != nil {
+		return err
+	}
+
+	parent.removeSubfolder(index)
+
+	return nil
+}
+
+// findSubfolder returns the parent of the Folder with the given non-empty
+// Path, and the index of the Folder in its Subfolders.
+func (f *Folder) findSubfolder(path Path) (*Folder, int, error) {
+	parent, err := f.FindFolder(path[:len(path)-1])
+	if err != nil {
+		return nil, 0, err
+	}
+
+	index := parent.subfolderIndex(path[len(path)-1])
+	if index < 0 {
+		return nil, 0, fmt.Errorf("%w: %q", ErrFolderNotFound, FormatPath(path))
+	}
+
+	return parent, index, nil
+}
+
+// subfolderIndex returns the index of the first Subfolder with the given
+// name, following Order, or -1 if there is none.
+func (f *Folder) subfolderIndex(name string) int {
+	for _, ref := range f.orderedItems() {
+		if ref.Kind == FolderKind && f.Subfolders[ref.Index].Name == name {
+			return ref.Index
+		}
+	}
+
+	return -1
+}
+
+// addSubfolder adds folder after the existing items, and returns its index.
+func (f *Folder) addSubfolder(folder Folder) int {
+	f.Order = f.orderedItems()
+
+	f.Subfolders = append(f.Subfolders, folder)
+	index := len(f.Subfolders) - 1
+
+	f.Order = append(f.Order, ItemRef{Kind: FolderKind, Index: index})
+
+	return index
+}
+
+// removeSubfolder removes the Subfolder with the given index, and updates
+// Order accordingly.
+func (f *Folder) removeSubfolder(index int) {
+	f.Subfolders = slices.Delete(f.Subfolders, index, index+1)
+	f.removeItemRef(FolderKind, index)
+}
+
+// removeItemRef removes the reference to the removed item of the given kind
+// and index from Order, and shifts the following references.
+func (f *Folder) removeItemRef(kind ItemKind, index int) {
+	if f.Order == nil {
+		return
+	}
+
+	order := f.Order[:0]
+	for _, ref := range f.Order {
+		if ref.Kind == kind {
+			if ref.Index == index {
+				continue
+			}
+			if ref.Index > index {
+				ref.Index--
+			}
+		}
+		order = append(order, ref)
+	}
+	f.Order = order
+}
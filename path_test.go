@@ -0,0 +1,505 @@
+// Copyright (c) VirtualTam
+// SPDX-License-Identifier: MIT
+
+package netscape
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParsePath(t *testing.T) {
+	cases := []struct {
+		tname   string
+		input   string
+		want    Path
+		wantErr error
+	}{
+		// nominal cases
+		{
+			tname: "empty",
+			input: "",
+			want:  Path{},
+		},
+		{
+			tname: "root",
+			input: "/",
+			want:  Path{},
+		},
+		{
+			tname: "single folder",
+			input: "Work",
+			want:  Path{"Work"},
+		},
+		{
+			tname: "nested folders",
+			input: "Work/Projects/Go",
+			want:  Path{"Work", "Projects", "Go"},
+		},
+		{
+			tname: "leading and trailing separators",
+			input: "/Work/Projects/",
+			want:  Path{"Work", "Projects"},
+		},
+		{
+			tname: "folder names with commas and spaces",
+			input: "Folder1, the first,folder to encounter/ Sub folder ",
+			want:  Path{"Folder1, the first,folder to encounter", " Sub folder "},
+		},
+		{
+			tname: "escaped separators",
+			input: `Misc/A\/B/C\/`,
+			want:  Path{"Misc", "A/B", "C/"},
+		},
+		{
+			tname: "escaped escape character before a trailing separator",
+			input: `Work\\/`,
+			want:  Path{`Work\`},
+		},
+		{
+			tname: "escaped escape characters",
+			input: `C:\\Users\\/Docs`,
+			want:  Path{`C:\Users\`, "Docs"},
+		},
+
+		// error cases
+		{
+			tname:   "empty folder name",
+			input:   "Work//Go",
+			wantErr: ErrPathInvalid,
+		},
+		{
+			tname:   "invalid escape sequence",
+			input:   `Work\n`,
+			wantErr: ErrPathInvalid,
+		},
+		{
+			tname:   "trailing escape character",
+			input:   `Work\`,
+			wantErr: ErrPathInvalid,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.tname, func(t *testing.T) {
+			got, err := ParsePath(tc.input)
+
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("want error %q, got %q", tc.wantErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected no error, got %q", err)
+			}
+
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("want path %q, got %q", []string(tc.want), []string(got))
+			}
+
+			// the string representation is parsed to the same Path
+			roundtrip, err := ParsePath(FormatPath(got))
+			if err != nil {
+				t.Fatalf("failed to parse %q: %s", FormatPath(got), err)
+			}
+
+			if !slices.Equal(roundtrip, got) {
+				t.Errorf("want path %q after roundtrip, got %q", []string(got), []string(roundtrip))
+			}
+		})
+	}
+}
+
+func TestFormatPath(t *testing.T) {
+	cases := []struct {
+		tname string
+		path  Path
+		want  string
+	}{
+		{
+			tname: "root",
+			path:  Path{},
+			want:  "",
+		},
+		{
+			tname: "nested folders",
+			path:  Path{"Work", "Projects", "Go"},
+			want:  "Work/Projects/Go",
+		},
+		{
+			tname: "special characters",
+			path:  Path{"A/B", `C:\Users`},
+			want:  `A\/B/C:\\Users`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.tname, func(t *testing.T) {
+			if got := FormatPath(tc.path); got != tc.want {
+				t.Errorf("want %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func folderNames(folders []Folder) []string {
+	names := make([]string, 0, len(folders))
+	for _, folder := range folders {
+		names = append(names, folder.Name)
+	}
+
+	return names
+}
+
+func TestDocumentFindFolder(t *testing.T) {
+	document := loadTestDocument(t, "netscape_nested.htm")
+
+	cases := []struct {
+		tname   string
+		path    string
+		want    string
+		wantErr error
+	}{
+		{
+			tname: "root",
+			path:  "",
+			want:  "Bookmarks",
+		},
+		{
+			tname: "folder name with commas",
+			path:  "Folder1, the first,folder to encounter",
+			want:  "Folder1, the first,folder to encounter",
+		},
+		{
+			tname: "nested folder",
+			path:  "Folder3/Folder3-1",
+			want:  "Folder3-1",
+		},
+		{
+			tname:   "not found",
+			path:    "Folder3/Folder3-2",
+			wantErr: ErrFolderNotFound,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.tname, func(t *testing.T) {
+			path, err := ParsePath(tc.path)
+			if err != nil {
+				t.Fatalf("failed to parse path: %s", err)
+			}
+
+			got, err := document.FindFolder(path)
+
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("want error %q, got %q", tc.wantErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected no error, got %q", err)
+			}
+
+			if got.Name != tc.want {
+				t.Errorf("want folder %q, got %q", tc.want, got.Name)
+			}
+		})
+	}
+}
+
+func TestDocumentEnsureFolder(t *testing.T) {
+	document := loadTestDocument(t, "netscape_nested.htm")
+
+	now := time.Date(2024, time.March, 2, 14, 30, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	got, err := document.EnsureFolder(Path{"Folder3", "Work", "Projects/Go"}, WithFolderClock(clock))
+	if err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+
+	if got.Name != "Projects/Go" {
+		t.Errorf("want folder %q, got %q", "Projects/Go", got.Name)
+	}
+
+	if !got.CreatedAt.Equal(now) || !got.UpdatedAt.Equal(now) {
+		t.Errorf("want creation and update dates %s, got %s and %s", now, got.CreatedAt, got.UpdatedAt)
+	}
+
+	folder3, err := document.FindFolder(Path{"Folder3"})
+	if err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+
+	if want := []string{"Folder3-1", "Work"}; !slices.Equal(folderNames(folder3.Subfolders), want) {
+		t.Errorf("want subfolders %q, got %q", want, folderNames(folder3.Subfolders))
+	}
+
+	// existing folders are returned as is
+	again, err := document.EnsureFolder(Path{"Folder3", "Work", "Projects/Go"})
+	if err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+
+	if again.Name != "Projects/Go" || len(folder3.Subfolders) != 2 {
+		t.Errorf("want existing folder to be returned")
+	}
+
+	if _, err := document.EnsureFolder(Path{"Folder3", ""}); !errors.Is(err, ErrPathInvalid) {
+		t.Errorf("want error %q, got %q", ErrPathInvalid, err)
+	}
+}
+
+func TestDocumentMove(t *testing.T) {
+	cases := []struct {
+		tname   string
+		src     Path
+		dst     Path
+		want    map[string][]string
+		wantErr error
+	}{
+		{
+			tname: "move to sibling",
+			src:   Path{"Folder2"},
+			dst:   Path{"Folder3", "Folder3-1"},
+			want: map[string][]string{
+				"":                  {"Folder1, the first,folder to encounter", "Folder3"},
+				"Folder3/Folder3-1": {"Folder2"},
+			},
+		},
+		{
+			tname: "move to root",
+			src:   Path{"Folder3", "Folder3-1"},
+			dst:   Path{},
+			want: map[string][]string{
+				"":        {"Folder1, the first,folder to encounter", "Folder2", "Folder3", "Folder3-1"},
+				"Folder3": {},
+			},
+		},
+		{
+			tname:   "move into itself",
+			src:     Path{"Folder3"},
+			dst:     Path{"Folder3", "Folder3-1"},
+			wantErr: ErrPathInvalid,
+		},
+		{
+			tname:   "move root",
+			src:     Path{},
+			dst:     Path{"Folder3"},
+			wantErr: ErrPathInvalid,
+		},
+		{
+			tname:   "source not found",
+			src:     Path{"Folder4"},
+			dst:     Path{"Folder3"},
+			wantErr: ErrFolderNotFound,
+		},
+		{
+			tname:   "destination not found",
+			src:     Path{"Folder2"},
+			dst:     Path{"Folder4"},
+			wantErr: ErrFolderNotFound,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.tname, func(t *testing.T) {
+			document := loadTestDocument(t, "netscape_nested.htm")
+
+			err := document.Move(tc.src, tc.dst)
+
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("want error %q, got %q", tc.wantErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected no error, got %q", err)
+			}
+
+			for pathStr, want := range tc.want {
+				path, _ := ParsePath(pathStr)
+
+				folder, err := document.FindFolder(path)
+				if err != nil {
+					t.Fatalf("expected no error, got %q", err)
+				}
+
+				if got := folderNames(folder.Subfolders); !slices.Equal(got, want) {
+					t.Errorf("want %q subfolders %q, got %q", pathStr, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestDocumentMoveKeepsOrder(t *testing.T) {
+	document := loadTestDocument(t, "netscape_nested.htm")
+
+	if err := document.Move(Path{"Folder2"}, Path{}); err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+
+	var got []string
+	for _, item := range document.Root.Items() {
+		switch item := item.(type) {
+		case *Bookmark:
+			got = append(got, item.Title)
+		case *Folder:
+			got = append(got, item.Name)
+		}
+	}
+
+	want := []string{"Nested 1", "Folder1, the first,folder to encounter", "Folder3", "Nested 2", "Folder2"}
+
+	if !slices.Equal(got, want) {
+		t.Errorf("want items %q, got %q", want, got)
+	}
+}
+
+func TestDocumentMoveBookmark(t *testing.T) {
+	cases := []struct {
+		tname   string
+		src     Path
+		url     string
+		dst     Path
+		want    map[string][]string
+		wantErr error
+	}{
+		{
+			tname: "move to nested folder",
+			src:   Path{},
+			url:   "http://nest.ed/2",
+			dst:   Path{"Folder3", "Folder3-1"},
+			want: map[string][]string{
+				"":                  {"Nested 1"},
+				"Folder3/Folder3-1": {"Nested 3-1", "Nested 3-2", "Nested 2"},
+			},
+		},
+		{
+			tname: "move to root",
+			src:   Path{"Folder2"},
+			url:   "http://nest.ed/2-1",
+			dst:   Path{},
+			want: map[string][]string{
+				"":        {"Nested 1", "Nested 2", "Nested 2-1"},
+				"Folder2": {"Nested 2-2"},
+			},
+		},
+		{
+			tname:   "bookmark not found",
+			src:     Path{"Folder2"},
+			url:     "http://nest.ed/1",
+			dst:     Path{},
+			wantErr: ErrBookmarkNotFound,
+		},
+		{
+			tname:   "source not found",
+			src:     Path{"Folder4"},
+			url:     "http://nest.ed/1",
+			dst:     Path{},
+			wantErr: ErrFolderNotFound,
+		},
+		{
+			tname:   "destination not found",
+			src:     Path{},
+			url:     "http://nest.ed/1",
+			dst:     Path{"Folder4"},
+			wantErr: ErrFolderNotFound,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.tname, func(t *testing.T) {
+			document := loadTestDocument(t, "netscape_nested.htm")
+
+			err := document.MoveBookmark(tc.src, tc.url, tc.dst)
+
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("want error %q, got %q", tc.wantErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected no error, got %q", err)
+			}
+
+			for pathStr, want := range tc.want {
+				path, _ := ParsePath(pathStr)
+
+				folder, err := document.FindFolder(path)
+				if err != nil {
+					t.Fatalf("expected no error, got %q", err)
+				}
+
+				var got []string
+				for _, item := range folder.Items() {
+					if bookmark, ok := item.(*Bookmark); ok {
+						got = append(got, bookmark.Title)
+					}
+				}
+
+				if !slices.Equal(got, want) {
+					t.Errorf("want %q bookmarks %q, got %q", pathStr, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestDocumentRenameFolder(t *testing.T) {
+	document := loadTestDocument(t, "netscape_nested.htm")
+
+	if err := document.RenameFolder(Path{"Folder3", "Folder3-1"}, "Reference"); err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+
+	if _, err := document.FindFolder(Path{"Folder3", "Reference"}); err != nil {
+		t.Errorf("want renamed folder, got %q", err)
+	}
+
+	if err := document.RenameFolder(Path{"Folder3", "Folder3-1"}, "Reference"); !errors.Is(err, ErrFolderNotFound) {
+		t.Errorf("want error %q, got %q", ErrFolderNotFound, err)
+	}
+
+	if err := document.RenameFolder(Path{"Folder3"}, ""); !errors.Is(err, ErrPathInvalid) {
+		t.Errorf("want error %q, got %q", ErrPathInvalid, err)
+	}
+}
+
+func TestDocumentRemoveFolder(t *testing.T) {
+	document := loadTestDocument(t, "netscape_nested.htm")
+
+	if err := document.RemoveFolder(Path{"Folder2"}); err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+
+	want := []string{"Folder1, the first,folder to encounter", "Folder3"}
+	if got := folderNames(document.Root.Subfolders); !slices.Equal(got, want) {
+		t.Errorf("want subfolders %q, got %q", want, got)
+	}
+
+	for _, ref := range document.Root.Order {
+		if ref.Kind == FolderKind && ref.Index >= len(document.Root.Subfolders) {
+			t.Errorf("want Order to reference existing subfolders, got %v", document.Root.Order)
+		}
+	}
+
+	if err := document.RemoveFolder(Path{"Folder2"}); !errors.Is(err, ErrFolderNotFound) {
+		t.Errorf("want error %q, got %q", ErrFolderNotFound, err)
+	}
+
+	if err := document.RemoveFolder(Path{}); !errors.Is(err, ErrPathInvalid) {
+		t.Errorf("want error %q, got %q", ErrPathInvalid, err)
+	}
+}
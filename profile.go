@@ -0,0 +1,250 @@
+// Copyright (c) VirtualTam
+// SPDX-License-Identifier: MIT
+
+package netscape
+
+import (
+	"encoding/xml"
+	"fmt"
+	"slices"
+)
+
+// A Profile identifies the Netscape Bookmark dialect expected by a Web browser
+// or bookmarking service when importing bookmarks.
+type Profile int
+
+const (
+	// ProfileNetscape writes all typed fields and Attributes.
+	ProfileNetscape Profile = iota
+
+	// ProfileFirefox targets Mozilla Firefox.
+	ProfileFirefox
+
+	// ProfileChromium targets Chromium-based browsers, such as Google Chrome
+	// and Microsoft Edge.
+	ProfileChromium
+
+	// ProfileSafari targets Apple Safari.
+	ProfileSafari
+
+	// ProfileShaarli targets the Shaarli bookmarking service.
+	ProfileShaarli
+
+	// ProfilePinboard targets the Pinboard bookmarking service.
+	ProfilePinboard
+
+	// ProfilePocket targets the Pocket read-it-later service.
+	ProfilePocket
+)
+
+var profileNames = map[Profile]string{
+	ProfileNetscape: "netscape",
+	ProfileFirefox:  "firefox",
+	ProfileChromium: "chromium",
+	ProfileSafari:   "safari",
+	ProfileShaarli:  "shaarli",
+	ProfilePinboard: "pinboard",
+	ProfilePocket:   "pocket",
+}
+
+// String returns the string representation for this Profile.
+func (p Profile) String() string {
+	if name, ok := profileNames[p]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("Profile(%d)", int(p))
+}
+
+// ParseProfile returns the Profile corresponding to the given name.
+func ParseProfile(name string) (Profile, error) {
+	for profile, profileName := range profileNames {
+		if profileName == name {
+			return profile, nil
+		}
+	}
+
+	return ProfileNetscape, fmt.Errorf("unknown profile %q", name)
+}
+
+// A fieldSet is a set of elements and attributes written by the Encoder.
+type fieldSet uint
+
+const (
+	fieldAddDate fieldSet = 1 << iota
+	fieldLastModified
+	fieldLastVisit
+	fieldPrivate
+	fieldTags
+	fieldIcon
+	fieldIconURI
+	fieldKeyword
+	fieldPostData
+	fieldCharset
+	fieldToolbarFolder
+	fieldUnfiledFolder
+	fieldFolded
+	fieldFolderLastModified
+	fieldDescription
+	fieldSeparator
+
+	allFields fieldSet = 1<<iota - 1
+)
+
+func (s fieldSet) has(f fieldSet) bool {
+	return s&f != 0
+}
+
+// A profileSpec describes how a Profile configures the Encoder.
+type profileSpec struct {
+	fields fieldSet
+
+	// Attributes written in addition to typed fields; nil means all of
+	// them.
+	attributes []string
+
+	// Whether LAST_MODIFIED defaults to the creation date.
+	lastModifiedFallback bool
+
+	// Names given to typed attributes, when they differ from the Netscape
+	// ones.
+	attributeNames map[string]string
+
+	opts []EncoderOption
+}
+
+const (
+	firefoxContentSecurityPolicy string = `<meta http-equiv="Content-Security-Policy"
+      content="default-src 'self'; script-src 'none'; img-src data: *; object-src 'none'"></meta>`
+)
+
+var profileSpecs = map[Profile]profileSpec{
+	ProfileNetscape: {
+		fields: allFields,
+	},
+	ProfileFirefox: {
+		fields: fieldAddDate | fieldLastModified | fieldTags |
+			fieldIcon | fieldIconURI | fieldKeyword | fieldPostData | fieldCharset |
+			fieldToolbarFolder | fieldUnfiledFolder | fieldFolderLastModified |
+			fieldDescription | fieldSeparator,
+		attributes:           []string{},
+		lastModifiedFallback: true,
+		opts: []EncoderOption{
+			WithCharsetMeta(),
+			withExtraHeader(firefoxContentSecurityPolicy),
+		},
+	},
+	ProfileChromium: {
+		fields:     fieldAddDate | fieldIcon | fieldToolbarFolder | fieldFolderLastModified,
+		attributes: []string{},
+		opts: []EncoderOption{
+			WithCharsetMeta(),
+		},
+	},
+	ProfileSafari: {
+		fields:     fieldFolded,
+		attributes: []string{},
+		opts: []EncoderOption{
+			WithHeaderComment(""),
+			WithCharsetMeta(),
+			WithIndent("\t"),
+		},
+	},
+	ProfileShaarli: {
+		fields:     fieldAddDate | fieldLastModified | fieldPrivate | fieldTags | fieldDescription,
+		attributes: []string{},
+		opts: []EncoderOption{
+			WithCharsetMeta(),
+		},
+	},
+	ProfilePinboard: {
+		fields:     fieldAddDate | fieldPrivate | fieldTags | fieldDescription,
+		attributes: []string{toReadAttr},
+		opts: []EncoderOption{
+			WithHeaderComment(""),
+			WithCharsetMeta(),
+		},
+	},
+	ProfilePocket: {
+		fields:     fieldAddDate | fieldTags,
+		attributes: []string{},
+		attributeNames: map[string]string{
+			createdAtAttr: "time_added",
+			tagsAttr:      "tags",
+		},
+		opts: []EncoderOption{
+			WithHeaderComment(""),
+			WithCharsetMeta(),
+		},
+	},
+}
+
+const (
+	// toReadAttr marks bookmarks that have not been read yet, for
+	// read-it-later services.
+	toReadAttr string = "TOREAD"
+)
+
+// WithProfile configures the Encoder to write the typed fields and Attributes
+// supported by the given Profile, using the same conventions as the
+// corresponding exports.
+//
+// WithProfile resets the indent, header comment and META elements to those of
+// the Profile; options passed after WithProfile take precedence.
+func WithProfile(profile Profile) EncoderOption {
+	return func(e *Encoder) {
+		spec, ok := profileSpecs[profile]
+		if !ok {
+			spec = profileSpecs[ProfileNetscape]
+		}
+
+		e.p.fields = spec.fields
+		e.p.attributes = spec.attributes
+		e.p.lastModifiedFallback = spec.lastModifiedFallback
+		e.p.attributeNames = spec.attributeNames
+
+		e.p.indent = defaultIndent
+		e.p.headerComment = defaultHeaderComment
+		e.p.charsetMeta = false
+		e.p.extraHeader = ""
+
+		for _, opt := range spec.opts {
+			opt(e)
+		}
+	}
+}
+
+// withExtraHeader sets additional markup written before the document title.
+func withExtraHeader(header string) EncoderOption {
+	return func(e *Encoder) {
+		e.p.extraHeader = header
+	}
+}
+
+// renameAttributes clears the typed attributes renamed by the Profile, and
+// returns them under their new name, followed by attrs.
+func (p *printer) renameAttributes(typed []typedAttr, attrs []xml.Attr) []xml.Attr {
+	if len(p.attributeNames) == 0 {
+		return attrs
+	}
+
+	var renamed []xml.Attr
+
+	for _, attr := range typed {
+		name, ok := p.attributeNames[attr.name]
+		if !ok || *attr.value == "" {
+			continue
+		}
+
+		renamed = append(renamed, xml.Attr{Name: xml.Name{Local: name}, Value: *attr.value})
+		*attr.value = ""
+	}
+
+	return append(renamed, attrs...)
+}
+
+// writesAttribute returns whether the Encoder writes the given arbitrary
+// attribute.
+func (p *printer) writesAttribute(name string) bool {
+	return p.attributes == nil || slices.Contains(p.attributes, name)
+}
@@ -0,0 +1,146 @@
+// Copyright (c) VirtualTam
+// SPDX-License-Identifier: MIT
+
+package netscape
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// update rewrites the golden files with the output of the tests.
+var update = flag.Bool("update", false, "update golden files")
+
+func TestMarshalProfiles(t *testing.T) {
+	createdAt := time.Date(2022, time.March, 1, 17, 11, 13, 0, time.UTC)
+	updatedAt := time.Date(2022, time.March, 1, 22, 9, 46, 0, time.UTC)
+
+	document := Document{
+		Title: "Bookmarks",
+		Root: Folder{
+			Name: "Bookmarks Menu",
+			Role: FolderRoleMenu,
+			Bookmarks: []Bookmark{
+				{
+					CreatedAt:     createdAt,
+					UpdatedAt:     updatedAt,
+					LastVisitedAt: updatedAt,
+					Title:         "The Go Programming Language",
+					URL:           "https://go.dev/",
+					Description:   "Build simple, secure, scalable systems with Go",
+					Tags:          []string{"golang", "programming"},
+					Icon: Icon{
+						MediaType: "image/png",
+						Data:      []byte("\x89PNG\r\n\x1a\n"),
+					},
+					IconURI: "https://go.dev/images/favicon-gopher.png",
+					Attributes: map[string]string{
+						"TOREAD": "1",
+					},
+				},
+				{
+					CreatedAt: createdAt,
+					Title:     "Go Packages",
+					URL:       "https://pkg.go.dev/search?q=%s",
+					Private:   true,
+					Keyword:   "gopkg",
+					Charset:   "UTF-8",
+					Attributes: map[string]string{
+						"FEED": "false",
+					},
+				},
+			},
+			Separators: []Separator{{}},
+			Subfolders: []Folder{
+				{
+					CreatedAt:   createdAt,
+					UpdatedAt:   updatedAt,
+					Name:        "Bookmarks Toolbar",
+					Description: "Add bookmarks to this folder to see them displayed on the Bookmarks Toolbar",
+					Role:        FolderRoleToolbar,
+					Bookmarks: []Bookmark{
+						{
+							CreatedAt: createdAt,
+							Title:     "Rust Programming Language",
+							URL:       "https://www.rust-lang.org/",
+							Tags:      []string{"programming", "rust"},
+						},
+					},
+				},
+				{
+					CreatedAt: createdAt,
+					Name:      "Other Bookmarks",
+					Role:      FolderRoleUnfiled,
+					Folded:    true,
+				},
+			},
+			Order: []ItemRef{
+				{Kind: BookmarkKind, Index: 0},
+				{Kind: SeparatorKind, Index: 0},
+				{Kind: BookmarkKind, Index: 1},
+				{Kind: FolderKind, Index: 0},
+				{Kind: FolderKind, Index: 1},
+			},
+		},
+	}
+
+	profiles := []Profile{
+		ProfileFirefox,
+		ProfileChromium,
+		ProfileSafari,
+		ProfileShaarli,
+		ProfilePinboard,
+		ProfilePocket,
+	}
+
+	for _, profile := range profiles {
+		t.Run(profile.String(), func(t *testing.T) {
+			goldenFilename := filepath.Join("testdata", "golden", profile.String()+".htm")
+
+			got, err := MarshalWithOptions(&document, WithProfile(profile))
+			if err != nil {
+				t.Fatalf("expected no error, got %q", err)
+			}
+
+			if *update {
+				if err := os.WriteFile(goldenFilename, got, 0o644); err != nil {
+					t.Fatalf("failed to write golden file %s: %s", goldenFilename, err)
+				}
+			}
+
+			want, err := os.ReadFile(goldenFilename)
+			if err != nil {
+				t.Fatalf("failed to read golden file %s: %s", goldenFilename, err)
+			}
+
+			if !bytes.Equal(got, want) {
+				t.Errorf("want:\n%s\ngot:\n%s", want, got)
+			}
+
+			if _, err := Unmarshal(got); err != nil {
+				t.Errorf("failed to unmarshal marshaled data: %s", err)
+			}
+		})
+	}
+}
+
+func TestParseProfile(t *testing.T) {
+	for profile, name := range profileNames {
+		got, err := ParseProfile(name)
+		if err != nil {
+			t.Fatalf("expected no error, got %q", err)
+		}
+
+		if got != profile {
+			t.Errorf("want profile %q, got %q", profile, got)
+		}
+	}
+
+	if _, err := ParseProfile("mosaic"); err == nil {
+		t.Error("expected an error, got none")
+	}
+}
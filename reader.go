@@ -0,0 +1,136 @@
+// Copyright (c) VirtualTam
+// SPDX-License-Identifier: MIT
+
+package netscape
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// contextSize is the minimum number of bytes kept to provide context when
+// reporting parsing errors.
+const contextSize = 256
+
+// A recordingReader wraps an io.Reader and can record the bytes it reads,
+// so that raw input data can be retrieved once it has been consumed by the
+// XML tokenizer, without seeking back into the underlying stream.
+//
+// The recordingReader implements io.ByteReader, so that the xml.Decoder reads
+// from it byte by byte, and does not perform its own buffering.
+type recordingReader struct {
+	r *bufio.Reader
+
+	// offset of the next byte to be read.
+	offset int64
+
+	// last byte read, kept as the xml.Decoder may read one byte ahead of the
+	// offset it reports.
+	last byte
+
+	recording bool
+	buf       []byte
+	bufOffset int64
+
+	// recent data read from the input.
+	recent []byte
+}
+
+func newRecordingReader(r io.Reader) *recordingReader {
+	return &recordingReader{
+		r: bufio.NewReader(r),
+	}
+}
+
+// ReadByte reads and returns the next byte from the input.
+func (rr *recordingReader) ReadByte() (byte, error) {
+	b, err := rr.r.ReadByte()
+	if err != nil {
+		return b, err
+	}
+
+	rr.offset++
+	rr.last = b
+
+	if rr.recording {
+		rr.buf = append(rr.buf, b)
+	}
+
+	if len(rr.recent) == 2*contextSize {
+		rr.recent = rr.recent[:copy(rr.recent, rr.recent[contextSize:])]
+	}
+	rr.recent = append(rr.recent, b)
+
+	return b, nil
+}
+
+// Read reads up to len(p) bytes into p.
+func (rr *recordingReader) Read(p []byte) (int, error) {
+	for i := range p {
+		b, err := rr.ReadByte()
+		if err != nil {
+			return i, err
+		}
+		p[i] = b
+	}
+
+	return len(p), nil
+}
+
+// unreadByte unreads the last byte read.
+func (rr *recordingReader) unreadByte() {
+	if err := rr.r.UnreadByte(); err != nil {
+		return
+	}
+
+	rr.offset--
+	rr.recent = rr.recent[:len(rr.recent)-1]
+
+	if rr.recording {
+		rr.buf = rr.buf[:len(rr.buf)-1]
+	}
+}
+
+// startRecording starts recording input data from the given offset, which
+// must be equal to the current offset, or to the offset of the last byte read.
+func (rr *recordingReader) startRecording(offset int64) {
+	rr.recording = true
+	rr.buf = rr.buf[:0]
+	rr.bufOffset = rr.offset
+
+	if offset < rr.offset {
+		rr.buf = append(rr.buf, rr.last)
+		rr.bufOffset = offset
+	}
+}
+
+// stopRecording stops recording input data and returns the data recorded in
+// the [start:end] offset range.
+//
+// The returned slice is only valid until the next call to startRecording.
+func (rr *recordingReader) stopRecording(start, end int64) []byte {
+	rr.recording = false
+
+	return rr.buf[start-rr.bufOffset : end-rr.bufOffset]
+}
+
+// context returns the line containing the given offset, provided it is still
+// available in the window of recently read data.
+//
+// If the offset precedes this window, the first available line is returned.
+func (rr *recordingReader) context(offset int64) string {
+	recentOffset := rr.offset - int64(len(rr.recent))
+	index := int(min(max(offset, recentOffset), rr.offset) - recentOffset)
+
+	lineStart := bytes.LastIndexByte(rr.recent[:index], '\n') + 1
+
+	lineEnd := bytes.IndexByte(rr.recent[index:], '\n')
+	if lineEnd < 0 {
+		lineEnd = len(rr.recent)
+	} else {
+		lineEnd += index
+	}
+
+	return string(bytes.TrimRight(rr.recent[lineStart:lineEnd], "\r"))
+}
@@ -0,0 +1,239 @@
+// Copyright (c) VirtualTam
+// SPDX-License-Identifier: MIT
+
+package netscape
+
+import (
+	"errors"
+	"io"
+	"iter"
+	"slices"
+)
+
+// A StreamHandler is notified of the folders and bookmarks of a Netscape
+// Bookmark document while it is decoded by a StreamDecoder.
+//
+// Folders are identified by their Path. Paths are only valid until the method
+// returns, and must be cloned to be retained.
+//
+// If a method returns an error, decoding stops and StreamDecoder.Decode
+// returns this error.
+type StreamHandler interface {
+	// OnFolderStart is called when a folder starts, with its path, name,
+	// description and attributes; its bookmarks and subfolders are not set.
+	OnFolderStart(path Path, folder *Folder) error
+
+	// OnFolderEnd is called once all the bookmarks and subfolders of a
+	// folder have been processed.
+	OnFolderEnd(path Path, folder *Folder) error
+
+	// OnBookmark is called for each bookmark, with the path of the folder
+	// containing it.
+	OnBookmark(path Path, bookmark *Bookmark) error
+}
+
+// StreamHandlerFuncs implements StreamHandler with optional functions; nil
+// functions are not called.
+type StreamHandlerFuncs struct {
+	FolderStart func(path Path, folder *Folder) error
+	FolderEnd   func(path Path, folder *Folder) error
+	Bookmark    func(path Path, bookmark *Bookmark) error
+}
+
+// OnFolderStart calls h.FolderStart, if set.
+func (h StreamHandlerFuncs) OnFolderStart(path Path, folder *Folder) error {
+	if h.FolderStart == nil {
+		return nil
+	}
+
+	return h.FolderStart(path, folder)
+}
+
+// OnFolderEnd calls h.FolderEnd, if set.
+func (h StreamHandlerFuncs) OnFolderEnd(path Path, folder *Folder) error {
+	if h.FolderEnd == nil {
+		return nil
+	}
+
+	return h.FolderEnd(path, folder)
+}
+
+// OnBookmark calls h.Bookmark, if set.
+func (h StreamHandlerFuncs) OnBookmark(path Path, bookmark *Bookmark) error {
+	if h.Bookmark == nil {
+		return nil
+	}
+
+	return h.Bookmark(path, bookmark)
+}
+
+// A StreamDecoder reads a Netscape Bookmark document from an input stream, and
+// notifies a StreamHandler of each folder and bookmark as soon as it has been
+// decoded.
+//
+// As opposed to Unmarshal, neither the AST nor the Document are built in
+// memory, so that memory usage does not depend on the size of the input.
+type StreamDecoder struct {
+	r       io.Reader
+	decoder *Decoder
+	handler StreamHandler
+
+	title       string
+	diagnostics []Diagnostic
+
+	// path and decoded headers of the open folders, starting with the Root
+	// Folder.
+	path    Path
+	folders []*Folder
+
+	roles assignedRoles
+}
+
+// NewStreamDecoder returns a new StreamDecoder that reads from r, and decodes
+// folders and bookmarks with a Decoder configured with opts.
+//
+// When WithFolderRoleGuessing is enabled, top-level folders are only assigned
+// a role guessed from their name if no previous folder has been marked with
+// this role; as opposed to Decoder, role attributes found later in the
+// document are not taken into account.
+func NewStreamDecoder(r io.Reader, opts ...DecoderOption) *StreamDecoder {
+	return &StreamDecoder{
+		r:       r,
+		decoder: NewDecoder(opts...),
+		roles:   make(assignedRoles),
+	}
+}
+
+// Decode reads the document and notifies h of its folders and bookmarks, in
+// document order.
+//
+// Folders left open at the end of the input are closed, as Netscape Bookmark
+// documents do not always close the Root Folder.
+func (sd *StreamDecoder) Decode(h StreamHandler) error {
+	sd.handler = h
+
+	p := newParser(sd.r, ParseOptions{Mode: sd.decoder.mode})
+	p.listener = sd
+
+	file, err := p.parse()
+	sd.title = file.Title
+	sd.diagnostics = append(p.diagnostics, sd.decoder.Diagnostics()...)
+
+	if err != nil {
+		return err
+	}
+
+	for len(sd.folders) > 0 {
+		if err := sd.endFolder(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Title returns the title of the document, once decoded.
+func (sd *StreamDecoder) Title() string {
+	return sd.title
+}
+
+// Diagnostics returns the problems found by the StreamDecoder in Lenient
+// mode.
+func (sd *StreamDecoder) Diagnostics() []Diagnostic {
+	return sd.diagnostics
+}
+
+func (sd *StreamDecoder) folderStart(f *FolderNode) error {
+	folder, err := sd.decoder.decodeFolderHeader(*f)
+	if err != nil {
+		return err
+	}
+
+	depth := len(sd.folders)
+	if depth > 0 {
+		sd.path = append(sd.path, folder.Name)
+	}
+
+	if sd.decoder.guessRoles {
+		sd.roles.guess(&folder, depth)
+	}
+	sd.folders = append(sd.folders, &folder)
+
+	return sd.handler.OnFolderStart(slices.Clip(sd.path), &folder)
+}
+
+func (sd *StreamDecoder) folderEnd(_ *FolderNode) error {
+	// The Root Folder is closed once the whole document has been read, as
+	// elements may still be attached to it in Lenient mode.
+	if len(sd.folders) <= 1 {
+		return nil
+	}
+
+	return sd.endFolder()
+}
+
+// endFolder closes the innermost open folder.
+func (sd *StreamDecoder) endFolder() error {
+	folder := sd.folders[len(sd.folders)-1]
+
+	if err := sd.handler.OnFolderEnd(slices.Clip(sd.path), folder); err != nil {
+		return err
+	}
+
+	sd.folders = sd.folders[:len(sd.folders)-1]
+	if len(sd.path) > 0 {
+		sd.path = sd.path[:len(sd.path)-1]
+	}
+
+	return nil
+}
+
+func (sd *StreamDecoder) bookmark(b *BookmarkNode) error {
+	bookmark, err := sd.decoder.decodeBookmark(*b)
+	if err != nil {
+		return err
+	}
+
+	return sd.handler.OnBookmark(slices.Clip(sd.path), &bookmark)
+}
+
+// An Entry is a Bookmark yielded by Bookmarks, along with the Path of the
+// Folder containing it.
+type Entry struct {
+	Path     Path
+	Bookmark Bookmark
+}
+
+// errStopIteration is returned by the StreamHandler of Bookmarks to stop
+// decoding once the iteration has been stopped.
+var errStopIteration = errors.New("iteration stopped")
+
+// Bookmarks returns an iterator over the Bookmarks read from r, that are
+// decoded by a StreamDecoder configured with opts and yielded as soon as they
+// have been parsed.
+//
+// If an error occurs, it is yielded with an empty Entry and the iteration
+// ends. Breaking out of the iteration stops reading from r.
+func Bookmarks(r io.Reader, opts ...DecoderOption) iter.Seq2[Entry, error] {
+	return func(yield func(Entry, error) bool) {
+		handler := StreamHandlerFuncs{
+			Bookmark: func(path Path, bookmark *Bookmark) error {
+				entry := Entry{
+					Path:     slices.Clone(path),
+					Bookmark: *bookmark,
+				}
+
+				if !yield(entry, nil) {
+					return errStopIteration
+				}
+
+				return nil
+			},
+		}
+
+		err := NewStreamDecoder(r, opts...).Decode(handler)
+		if err != nil && !errors.Is(err, errStopIteration) {
+			yield(Entry{}, err)
+		}
+	}
+}
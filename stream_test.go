@@ -0,0 +1,410 @@
+// Copyright (c) VirtualTam
+// SPDX-License-Identifier: MIT
+
+package netscape
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// recordingHandler records the events passed to a StreamHandler.
+type recordingHandler struct {
+	events []string
+}
+
+func (h *recordingHandler) OnFolderStart(path Path, folder *Folder) error {
+	h.events = append(h.events, fmt.Sprintf("start %q %q role=%s folded=%t", path, folder.Description, folder.Role, folder.Folded))
+	return nil
+}
+
+func (h *recordingHandler) OnFolderEnd(path Path, folder *Folder) error {
+	h.events = append(h.events, fmt.Sprintf("end %q", path))
+	return nil
+}
+
+func (h *recordingHandler) OnBookmark(path Path, bookmark *Bookmark) error {
+	h.events = append(h.events, fmt.Sprintf(
+		"bookmark %q %q %q %q tags=%q private=%t created=%s",
+		path,
+		bookmark.URL,
+		bookmark.Title,
+		bookmark.Description,
+		bookmark.Tags,
+		bookmark.Private,
+		bookmark.CreatedAt,
+	))
+	return nil
+}
+
+// replayFolder passes the folders and bookmarks of f to h, as a StreamDecoder
+// would.
+func replayFolder(h StreamHandler, path Path, f *Folder) {
+	_ = h.OnFolderStart(path, f)
+
+	for _, item := range f.Items() {
+		switch item := item.(type) {
+		case *Bookmark:
+			_ = h.OnBookmark(path, item)
+		case *Folder:
+			replayFolder(h, append(slices.Clip(path), item.Name), item)
+		}
+	}
+
+	_ = h.OnFolderEnd(path, f)
+}
+
+func TestStreamDecoderDecode(t *testing.T) {
+	inputFilenames := []string{
+		"firefox_separators.htm",
+		"netscape_basic.htm",
+		"netscape_extended.htm",
+		"netscape_multiline.htm",
+		"netscape_nested.htm",
+		"safari_folded.htm",
+	}
+
+	for _, inputFilename := range inputFilenames {
+		t.Run(inputFilename, func(t *testing.T) {
+			inputFilePath := filepath.Join("testdata", "input", inputFilename)
+
+			document, err := UnmarshalFile(inputFilePath)
+			if err != nil {
+				t.Fatalf("failed to unmarshal input file %s: %s", inputFilename, err)
+			}
+
+			want := &recordingHandler{}
+			replayFolder(want, Path{}, &document.Root)
+
+			file, err := os.Open(inputFilePath)
+			if err != nil {
+				t.Fatalf("failed to open input file: %s", err)
+			}
+			defer file.Close()
+
+			got := &recordingHandler{}
+			sd := NewStreamDecoder(file)
+
+			if err := sd.Decode(got); err != nil {
+				t.Fatalf("expected no error, got %q", err)
+			}
+
+			if sd.Title() != document.Title {
+				t.Errorf("want title %q, got %q", document.Title, sd.Title())
+			}
+
+			if !slices.Equal(got.events, want.events) {
+				t.Errorf("want events:\n%s\ngot:\n%s", strings.Join(want.events, "\n"), strings.Join(got.events, "\n"))
+			}
+		})
+	}
+}
+
+func TestStreamDecoderDecodeUnclosedFolders(t *testing.T) {
+	input := `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DD>Root description
+<DL><p>
+<DT><H3>Level 1</H3>
+<DL><p>
+<DT><A HREF="https://domain.tld" TAGS="a,b">Test Domain</A>
+<DD>Description
+<DT><H3>Level 2</H3>
+<DL><p>
+`
+
+	got := &recordingHandler{}
+
+	if err := NewStreamDecoder(strings.NewReader(input)).Decode(got); err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+
+	want := []string{
+		`start [] "Root description" role=none folded=false`,
+		`start ["Level 1"] "" role=none folded=false`,
+		`bookmark ["Level 1"] "https://domain.tld" "Test Domain" "Description" tags=["a" "b"] private=false created=0001-01-01 00:00:00 +0000 UTC`,
+		`start ["Level 1" "Level 2"] "" role=none folded=false`,
+		`end ["Level 1" "Level 2"]`,
+		`end ["Level 1"]`,
+		`end []`,
+	}
+
+	if !slices.Equal(got.events, want) {
+		t.Errorf("want events:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got.events, "\n"))
+	}
+}
+
+// largeDocument returns a document containing n bookmarks with a description
+// in a single folder, as exported from large collections.
+func largeDocument(n int) string {
+	var input strings.Builder
+
+	input.WriteString("<!DOCTYPE NETSCAPE-Bookmark-file-1>\n<TITLE>Bookmarks</TITLE>\n<H1>Bookmarks</H1>\n<DL><p>\n")
+	input.WriteString("<DT><H3>Folder</H3>\n<DD>Folder description\n<DL><p>\n")
+	for i := range n {
+		fmt.Fprintf(&input, "<DT><A HREF=\"https://domain.tld/%d\" ADD_DATE=\"1646154000\">Bookmark %d</A>\n<DD>Description %d\n", i, i, i)
+	}
+	input.WriteString("</DL><p>\n</DL><p>\n")
+
+	return input.String()
+}
+
+func TestStreamDecoderDecodeLargeFolder(t *testing.T) {
+	const n = 100000
+
+	var count int
+	var last *Bookmark
+
+	handler := StreamHandlerFuncs{
+		Bookmark: func(path Path, bookmark *Bookmark) error {
+			if !slices.Equal(path, Path{"Folder"}) {
+				return fmt.Errorf("unexpected path %q for bookmark %q", path, bookmark.URL)
+			}
+
+			count++
+			last = bookmark
+			return nil
+		},
+	}
+
+	if err := NewStreamDecoder(strings.NewReader(largeDocument(n))).Decode(handler); err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+
+	if count != n {
+		t.Fatalf("want %d bookmarks, got %d", n, count)
+	}
+
+	wantURL := fmt.Sprintf("https://domain.tld/%d", n-1)
+	wantDescription := fmt.Sprintf("Description %d", n-1)
+
+	if last.URL != wantURL || last.Description != wantDescription {
+		t.Errorf("want last bookmark %q %q, got %q %q", wantURL, wantDescription, last.URL, last.Description)
+	}
+}
+
+func TestStreamDecoderDecodeError(t *testing.T) {
+	input := `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DL><p>
+<DT><A HREF="https://domain.tld">Test Domain</A>
+<DT><A HREF="https://test.domain.tld" ADD_DATE="invalid">Test Domain</A>
+<DT><A HREF="https://other.domain.tld">Test Domain</A>
+</DL><p>
+`
+
+	t.Run("handler error", func(t *testing.T) {
+		errStop := errors.New("stop")
+		var urls []string
+
+		handler := StreamHandlerFuncs{
+			Bookmark: func(_ Path, bookmark *Bookmark) error {
+				urls = append(urls, bookmark.URL)
+				return errStop
+			},
+		}
+
+		err := NewStreamDecoder(strings.NewReader(input), WithMode(Lenient)).Decode(handler)
+		if !errors.Is(err, errStop) {
+			t.Fatalf("want error %q, got %q", errStop, err)
+		}
+
+		if !slices.Equal(urls, []string{"https://domain.tld"}) {
+			t.Errorf("want only the first bookmark, got %q", urls)
+		}
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		err := NewStreamDecoder(strings.NewReader(input)).Decode(StreamHandlerFuncs{})
+		if !errors.Is(err, ErrDateInvalid) {
+			t.Fatalf("want error %q, got %q", ErrDateInvalid, err)
+		}
+	})
+
+	t.Run("lenient", func(t *testing.T) {
+		var count int
+
+		handler := StreamHandlerFuncs{
+			Bookmark: func(_ Path, _ *Bookmark) error {
+				count++
+				return nil
+			},
+		}
+
+		sd := NewStreamDecoder(strings.NewReader(input), WithMode(Lenient))
+
+		if err := sd.Decode(handler); err != nil {
+			t.Fatalf("expected no error, got %q", err)
+		}
+
+		if count != 3 {
+			t.Errorf("want 3 bookmarks, got %d", count)
+		}
+
+		diagnostics := sd.Diagnostics()
+		if len(diagnostics) != 1 || !errors.Is(diagnostics[0].Err, ErrDateInvalid) {
+			t.Errorf("want 1 ErrDateInvalid diagnostic, got %v", diagnostics)
+		}
+	})
+}
+
+// countingListener counts the nodes passed to a nodeListener.
+type countingListener struct {
+	folders   int
+	bookmarks int
+}
+
+func (l *countingListener) folderStart(_ *FolderNode) error {
+	l.folders++
+	return nil
+}
+
+func (l *countingListener) folderEnd(_ *FolderNode) error {
+	return nil
+}
+
+func (l *countingListener) bookmark(_ *BookmarkNode) error {
+	l.bookmarks++
+	return nil
+}
+
+func TestParseWithListenerReleasesNodes(t *testing.T) {
+	var input strings.Builder
+
+	input.WriteString("<!DOCTYPE NETSCAPE-Bookmark-file-1>\n<TITLE>Bookmarks</TITLE>\n<H1>Bookmarks</H1>\n<DL><p>\n")
+	for i := range 100 {
+		fmt.Fprintf(&input, "<DT><H3>Folder %d</H3>\n<DL><p>\n", i)
+		for j := range 10 {
+			fmt.Fprintf(&input, "<DT><A HREF=\"https://%d.domain.tld/%d\">Test Domain</A>\n<DD>Description\n<HR>\n", i, j)
+		}
+		input.WriteString("</DL><p>\n")
+	}
+	input.WriteString("</DL><p>\n")
+
+	listener := &countingListener{}
+
+	p := newParser(strings.NewReader(input.String()), ParseOptions{})
+	p.listener = listener
+
+	file, err := p.parse()
+	if err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+
+	if listener.folders != 101 {
+		t.Errorf("want 101 folders, got %d", listener.folders)
+	}
+
+	if listener.bookmarks != 1000 {
+		t.Errorf("want 1000 bookmarks, got %d", listener.bookmarks)
+	}
+
+	root := file.Root
+	if len(root.Bookmarks) > 1 || len(root.Subfolders) > 1 || len(root.Separators) > 1 || len(root.Order) > 1 {
+		t.Errorf("want nodes to be released, got %d bookmarks, %d subfolders, %d separators",
+			len(root.Bookmarks), len(root.Subfolders), len(root.Separators))
+	}
+}
+
+func TestBookmarks(t *testing.T) {
+	input := `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DL><p>
+<DT><A HREF="https://domain.tld" PRIVATE="1">Test Domain</A>
+<DT><H3>Level 1</H3>
+<DL><p>
+<DT><A HREF="https://l1.domain.tld" TAGS="a,b">Level 1</A>
+<DD>Description
+</DL><p>
+<DT><A HREF="https://invalid.domain.tld" ADD_DATE="invalid">Invalid</A>
+</DL><p>
+`
+
+	t.Run("all", func(t *testing.T) {
+		var got []Entry
+		var gotErr error
+
+		for entry, err := range Bookmarks(strings.NewReader(input)) {
+			if err != nil {
+				gotErr = err
+				break
+			}
+			got = append(got, entry)
+		}
+
+		if !errors.Is(gotErr, ErrDateInvalid) {
+			t.Fatalf("want error %q, got %q", ErrDateInvalid, gotErr)
+		}
+
+		if len(got) != 2 {
+			t.Fatalf("want 2 entries, got %d", len(got))
+		}
+
+		if len(got[0].Path) != 0 || got[0].Bookmark.URL != "https://domain.tld" || !got[0].Bookmark.Private {
+			t.Errorf("unexpected first entry: %+v", got[0])
+		}
+
+		if !slices.Equal(got[1].Path, Path{"Level 1"}) {
+			t.Errorf("want path %q, got %q", Path{"Level 1"}, got[1].Path)
+		}
+
+		assertBookmarksEqual(t, got[1].Bookmark, Bookmark{
+			URL:         "https://l1.domain.tld",
+			Title:       "Level 1",
+			Description: "Description",
+			Tags:        []string{"a", "b"},
+		})
+	})
+
+	t.Run("break", func(t *testing.T) {
+		var urls []string
+
+		for entry, err := range Bookmarks(strings.NewReader(input)) {
+			if err != nil {
+				t.Fatalf("expected no error, got %q", err)
+			}
+
+			urls = append(urls, entry.Bookmark.URL)
+			break
+		}
+
+		if !slices.Equal(urls, []string{"https://domain.tld"}) {
+			t.Errorf("want only the first bookmark, got %q", urls)
+		}
+	})
+}
+
+func TestBookmarksLargeFolder(t *testing.T) {
+	const n = 100000
+
+	var count int
+
+	for entry, err := range Bookmarks(strings.NewReader(largeDocument(n))) {
+		if err != nil {
+			t.Fatalf("expected no error, got %q", err)
+		}
+
+		if !slices.Equal(entry.Path, Path{"Folder"}) {
+			t.Fatalf("want path %q, got %q", Path{"Folder"}, entry.Path)
+		}
+
+		wantURL := fmt.Sprintf("https://domain.tld/%d", count)
+		if entry.Bookmark.URL != wantURL {
+			t.Fatalf("want URL %q, got %q", wantURL, entry.Bookmark.URL)
+		}
+
+		count++
+	}
+
+	if count != n {
+		t.Errorf("want %d bookmarks, got %d", n, count)
+	}
+}
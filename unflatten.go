@@ -0,0 +1,250 @@
+// Copyright (c) VirtualTam
+// SPDX-License-Identifier: MIT
+
+package netscape
+
+import (
+	"slices"
+	"strings"
+)
+
+// An UnflattenStrategy controls in which folders a Bookmark with several tags
+// is placed by Unflatten.
+type UnflattenStrategy int
+
+const (
+	// UnflattenFirstTag places each Bookmark in the folder corresponding to
+	// its first tag.
+	UnflattenFirstTag UnflattenStrategy = iota
+
+	// UnflattenEachTag places a copy of each Bookmark in the folders
+	// corresponding to each of its tags.
+	UnflattenEachTag
+)
+
+const (
+	defaultUnsortedFolderName string = "Unsorted"
+)
+
+type unflattenOptions struct {
+	strategy UnflattenStrategy
+
+	hierarchySeparator string
+	mapping            map[string]Path
+
+	unsortedFolderName string
+}
+
+// An UnflattenOption configures how a Document is unflattened.
+type UnflattenOption func(*unflattenOptions)
+
+// WithUnflattenStrategy sets in which folders a Bookmark with several tags is
+// placed.
+//
+// Defaults to UnflattenFirstTag.
+func WithUnflattenStrategy(strategy UnflattenStrategy) UnflattenOption {
+	return func(o *unflattenOptions) {
+		o.strategy = strategy
+	}
+}
+
+// WithHierarchicalTags splits tags with separator to obtain the Path of the
+// corresponding folders, e.g. "dev/go/testing" with "/".
+func WithHierarchicalTags(separator string) UnflattenOption {
+	return func(o *unflattenOptions) {
+		o.hierarchySeparator = separator
+	}
+}
+
+// WithTagMapping sets the Path of the folder corresponding to each tag; other
+// tags are not used to place Bookmarks.
+//
+// WithTagMapping takes precedence over WithHierarchicalTags.
+func WithTagMapping(mapping map[string]Path) UnflattenOption {
+	return func(o *unflattenOptions) {
+		o.mapping = mapping
+	}
+}
+
+// WithUnsortedFolder sets the name of the folder containing the Bookmarks that
+// have no tag corresponding to a folder.
+//
+// An empty name leaves these Bookmarks in the Root Folder. Defaults to
+// "Unsorted".
+func WithUnsortedFolder(name string) UnflattenOption {
+	return func(o *unflattenOptions) {
+		o.unsortedFolderName = name
+	}
+}
+
+// Unflatten returns a version of this Document where the Bookmarks are placed
+// in folders built from their tags, which are left unchanged.
+//
+// All the Bookmarks of this Document are placed, in document order; its
+// folders and separators are not kept. Folders are created in the order they
+// are first needed, and the unsorted folder comes last.
+func (d *Document) Unflatten(opts ...UnflattenOption) *Document {
+	o := unflattenOptions{
+		unsortedFolderName: defaultUnsortedFolderName,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	root := newFolderBuilder(cloneHeader(&d.Root))
+
+	var unsorted []Bookmark
+
+	for _, bookmark := range d.All() {
+		paths := o.paths(bookmark.Tags)
+
+		if len(paths) == 0 {
+			unsorted = append(unsorted, bookmark.clone())
+			continue
+		}
+
+		for _, path := range paths {
+			root.folder(path).addBookmark(bookmark.clone())
+		}
+	}
+
+	if len(unsorted) > 0 {
+		folder := root
+		if o.unsortedFolderName != "" {
+			folder = root.folder(Path{o.unsortedFolderName})
+		}
+
+		for _, bookmark := range unsorted {
+			folder.addBookmark(bookmark)
+		}
+	}
+
+	return &Document{
+		Title: d.Title,
+		Root:  root.build(),
+	}
+}
+
+// paths returns the distinct Paths of the folders where a Bookmark with the
+// given tags is placed.
+func (o *unflattenOptions) paths(tags []string) []Path {
+	var paths []Path
+
+	for _, tag := range tags {
+		path, ok := o.path(tag)
+		if !ok {
+			continue
+		}
+
+		if !slices.ContainsFunc(paths, func(p Path) bool { return slices.Equal(p, path) }) {
+			paths = append(paths, path)
+		}
+
+		if o.strategy == UnflattenFirstTag {
+			break
+		}
+	}
+
+	return paths
+}
+
+// path returns the Path of the folder corresponding to tag, if any.
+func (o *unflattenOptions) path(tag string) (Path, bool) {
+	if o.mapping != nil {
+		path, ok := o.mapping[tag]
+		if !ok || slices.Contains(path, "") {
+			return nil, false
+		}
+
+		return path, true
+	}
+
+	tag = strings.TrimSpace(tag)
+	if tag == "" {
+		return nil, false
+	}
+
+	if o.hierarchySeparator == "" {
+		return Path{tag}, true
+	}
+
+	var path Path
+	for name := range strings.SplitSeq(tag, o.hierarchySeparator) {
+		if name = strings.TrimSpace(name); name != "" {
+			path = append(path, name)
+		}
+	}
+
+	return path, len(path) > 0
+}
+
+// A folderBuilder builds a Folder tree, looking up subfolders by name.
+type folderBuilder struct {
+	f Folder
+
+	subfolders []*folderBuilder
+	names      map[string]int
+}
+
+func newFolderBuilder(f Folder) *folderBuilder {
+	return &folderBuilder{
+		f:     f,
+		names: make(map[string]int),
+	}
+}
+
+// folder returns the builder of the folder with the given Path, relative to
+// this folder, creating it and its missing parent folders as needed.
+func (b *folderBuilder) folder(path Path) *folderBuilder {
+	builder := b
+
+	for _, name := range path {
+		builder, _ = builder.subfolder(Folder{Name: name})
+	}
+
+	return builder
+}
+
+// subfolder returns the builder of the subfolder with the same name as
+// header, and whether it has been created with header.
+func (b *folderBuilder) subfolder(header Folder) (*folderBuilder, bool) {
+	if index, ok := b.names[header.Name]; ok {
+		return b.subfolders[index], false
+	}
+
+	index := len(b.subfolders)
+	b.names[header.Name] = index
+	b.subfolders = append(b.subfolders, newFolderBuilder(header))
+	b.f.Order = append(b.f.Order, ItemRef{Kind: FolderKind, Index: index})
+
+	return b.subfolders[index], true
+}
+
+// addBookmark adds bookmark after the existing items, and returns its index.
+func (b *folderBuilder) addBookmark(bookmark Bookmark) int {
+	b.f.Bookmarks = append(b.f.Bookmarks, bookmark)
+	index := len(b.f.Bookmarks) - 1
+
+	b.f.Order = append(b.f.Order, ItemRef{Kind: BookmarkKind, Index: index})
+
+	return index
+}
+
+func (b *folderBuilder) addSeparator(separator Separator) {
+	b.f.Separators = append(b.f.Separators, separator)
+	b.f.Order = append(b.f.Order, ItemRef{Kind: SeparatorKind, Index: len(b.f.Separators) - 1})
+}
+
+// build returns the Folder tree.
+func (b *folderBuilder) build() Folder {
+	folder := b.f
+
+	if len(b.subfolders) > 0 {
+		folder.Subfolders = make([]Folder, 0, len(b.subfolders))
+	}
+	for _, subfolder := range b.subfolders {
+		folder.Subfolders = append(folder.Subfolders, subfolder.build())
+	}
+
+	return folder
+}
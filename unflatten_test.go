@@ -0,0 +1,260 @@
+// Copyright (c) VirtualTam
+// SPDX-License-Identifier: MIT
+
+package netscape
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDocumentUnflatten(t *testing.T) {
+	document := Document{
+		Title: "Bookmarks",
+		Root: Folder{
+			Name: "Bookmarks",
+			Bookmarks: []Bookmark{
+				{URL: "https://go.dev", Tags: []string{"dev/go", "reference"}},
+				{URL: "https://pkg.go.dev/testing", Tags: []string{"dev/go/testing"}},
+				{URL: "https://untagged.domain.tld"},
+				{URL: "https://www.rust-lang.org", Tags: []string{"dev/rust", "dev/go"}},
+			},
+			Subfolders: []Folder{
+				{
+					Name: "Existing",
+					Bookmarks: []Bookmark{
+						{URL: "https://nested.domain.tld", Tags: []string{" ", "reference"}},
+					},
+				},
+			},
+		},
+	}
+
+	cases := []struct {
+		tname string
+		opts  []UnflattenOption
+		want  Folder
+	}{
+		{
+			tname: "first tag",
+			want: Folder{
+				Name: "Bookmarks",
+				Subfolders: []Folder{
+					{
+						Name: "dev/go",
+						Bookmarks: []Bookmark{
+							{URL: "https://go.dev", Tags: []string{"dev/go", "reference"}},
+						},
+					},
+					{
+						Name: "dev/go/testing",
+						Bookmarks: []Bookmark{
+							{URL: "https://pkg.go.dev/testing", Tags: []string{"dev/go/testing"}},
+						},
+					},
+					{
+						Name: "dev/rust",
+						Bookmarks: []Bookmark{
+							{URL: "https://www.rust-lang.org", Tags: []string{"dev/rust", "dev/go"}},
+						},
+					},
+					{
+						Name: "reference",
+						Bookmarks: []Bookmark{
+							{URL: "https://nested.domain.tld", Tags: []string{" ", "reference"}},
+						},
+					},
+					{
+						Name: "Unsorted",
+						Bookmarks: []Bookmark{
+							{URL: "https://untagged.domain.tld"},
+						},
+					},
+				},
+			},
+		},
+		{
+			tname: "each tag, hierarchical tags",
+			opts: []UnflattenOption{
+				WithUnflattenStrategy(UnflattenEachTag),
+				WithHierarchicalTags("/"),
+				WithUnsortedFolder("Misc"),
+			},
+			want: Folder{
+				Name: "Bookmarks",
+				Subfolders: []Folder{
+					{
+						Name: "dev",
+						Subfolders: []Folder{
+							{
+								Name: "go",
+								Bookmarks: []Bookmark{
+									{URL: "https://go.dev", Tags: []string{"dev/go", "reference"}},
+									{URL: "https://www.rust-lang.org", Tags: []string{"dev/rust", "dev/go"}},
+								},
+								Subfolders: []Folder{
+									{
+										Name: "testing",
+										Bookmarks: []Bookmark{
+											{URL: "https://pkg.go.dev/testing", Tags: []string{"dev/go/testing"}},
+										},
+									},
+								},
+								Order: []ItemRef{
+									{Kind: BookmarkKind, Index: 0},
+									{Kind: FolderKind, Index: 0},
+									{Kind: BookmarkKind, Index: 1},
+								},
+							},
+							{
+								Name: "rust",
+								Bookmarks: []Bookmark{
+									{URL: "https://www.rust-lang.org", Tags: []string{"dev/rust", "dev/go"}},
+								},
+							},
+						},
+					},
+					{
+						Name: "reference",
+						Bookmarks: []Bookmark{
+							{URL: "https://go.dev", Tags: []string{"dev/go", "reference"}},
+							{URL: "https://nested.domain.tld", Tags: []string{" ", "reference"}},
+						},
+					},
+					{
+						Name: "Misc",
+						Bookmarks: []Bookmark{
+							{URL: "https://untagged.domain.tld"},
+						},
+					},
+				},
+			},
+		},
+		{
+			tname: "tag mapping, unsorted bookmarks in the root folder",
+			opts: []UnflattenOption{
+				WithTagMapping(map[string]Path{
+					"dev/go":    {"Programming", "Go"},
+					"reference": {"Reference"},
+				}),
+				WithUnsortedFolder(""),
+			},
+			want: Folder{
+				Name: "Bookmarks",
+				Bookmarks: []Bookmark{
+					{URL: "https://pkg.go.dev/testing", Tags: []string{"dev/go/testing"}},
+					{URL: "https://untagged.domain.tld"},
+				},
+				Subfolders: []Folder{
+					{
+						Name: "Programming",
+						Subfolders: []Folder{
+							{
+								Name: "Go",
+								Bookmarks: []Bookmark{
+									{URL: "https://go.dev", Tags: []string{"dev/go", "reference"}},
+									{URL: "https://www.rust-lang.org", Tags: []string{"dev/rust", "dev/go"}},
+								},
+							},
+						},
+					},
+					{
+						Name: "Reference",
+						Bookmarks: []Bookmark{
+							{URL: "https://nested.domain.tld", Tags: []string{" ", "reference"}},
+						},
+					},
+				},
+				Order: []ItemRef{
+					{Kind: FolderKind, Index: 0},
+					{Kind: FolderKind, Index: 1},
+					{Kind: BookmarkKind, Index: 0},
+					{Kind: BookmarkKind, Index: 1},
+				},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.tname, func(t *testing.T) {
+			got := document.Unflatten(tc.opts...)
+
+			if got.Title != document.Title {
+				t.Errorf("want title %q, got %q", document.Title, got.Title)
+			}
+
+			assertFoldersEqual(t, got.Root, tc.want)
+		})
+	}
+}
+
+func TestDocumentUnflattenDoesNotModifySource(t *testing.T) {
+	document := Document{
+		Root: Folder{
+			Attributes: map[string]string{"ID": "root"},
+			Bookmarks: []Bookmark{
+				{URL: "https://go.dev", Tags: []string{"dev", "go"}, Attributes: map[string]string{"ID": "1"}},
+				{URL: "https://domain.tld", Attributes: map[string]string{"ID": "2"}},
+			},
+		},
+	}
+
+	got := document.Unflatten(WithUnflattenStrategy(UnflattenEachTag))
+
+	got.Root.Attributes["ID"] = "edited"
+	for _, bookmark := range got.All() {
+		bookmark.Tags = append(bookmark.Tags[:0], "edited")
+		bookmark.Attributes["ID"] = "edited"
+	}
+
+	if id := document.Root.Attributes["ID"]; id != "root" {
+		t.Errorf("want root folder ID %q, got %q", "root", id)
+	}
+
+	if tags := document.Root.Bookmarks[0].Tags; !slices.Equal(tags, []string{"dev", "go"}) {
+		t.Errorf("want tags %q, got %q", []string{"dev", "go"}, tags)
+	}
+
+	for i, want := range []string{"1", "2"} {
+		if id := document.Root.Bookmarks[i].Attributes["ID"]; id != want {
+			t.Errorf("want bookmark ID %q, got %q", want, id)
+		}
+	}
+}
+
+func TestDocumentUnflattenRoundtrip(t *testing.T) {
+	document := loadTestDocument(t, "netscape_nested.htm")
+
+	// drop existing tags, so that bookmarks are only placed by folder tags
+	for _, bookmark := range document.All() {
+		bookmark.Tags = nil
+	}
+
+	got := document.FlattenWithOptions(WithPathTags("/")).Unflatten(WithHierarchicalTags("/"))
+
+	var gotPaths, wantPaths []string
+	for path, bookmark := range document.All() {
+		if len(path) == 0 {
+			// bookmarks of the root folder are unsorted
+			path = Path{defaultUnsortedFolderName}
+		}
+
+		// commas are replaced in path tags
+		path = slices.Clone(path)
+		for index, name := range path {
+			path[index] = sanitizeTag(name)
+		}
+
+		wantPaths = append(wantPaths, FormatPath(path)+" "+bookmark.URL)
+	}
+	for path, bookmark := range got.All() {
+		gotPaths = append(gotPaths, FormatPath(path)+" "+bookmark.URL)
+	}
+
+	slices.Sort(gotPaths)
+	slices.Sort(wantPaths)
+
+	if !slices.Equal(gotPaths, wantPaths) {
+		t.Errorf("want bookmarks %q, got %q", wantPaths, gotPaths)
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright (c) VirtualTam
+// SPDX-License-Identifier: MIT
+
+package netscape
+
+import (
+	"slices"
+)
+
+type walkOp int
+
+const (
+	walkContinue walkOp = iota
+	walkSkip
+	walkStop
+	walkDelete
+	walkReplace
+)
+
+// A WalkAction tells Walk how to proceed after visiting an Item.
+type WalkAction struct {
+	op   walkOp
+	item Item
+}
+
+var (
+	// WalkContinue continues the walk, descending into the visited Folder.
+	WalkContinue = WalkAction{op: walkContinue}
+
+	// WalkSkip continues the walk without descending into the visited
+	// Folder. For other Items, it is equivalent to WalkContinue.
+	WalkSkip = WalkAction{op: walkSkip}
+
+	// WalkStop stops the walk. Items deleted or replaced previously are
+	// still removed or replaced.
+	WalkStop = WalkAction{op: walkStop}
+
+	// WalkDelete removes the visited Item from its Folder.
+	WalkDelete = WalkAction{op: walkDelete}
+)
+
+// WalkReplace replaces the visited Item with item, which may be of a
+// different kind, and continues the walk without visiting item.
+//
+// Replacing an Item with nil, or with a nil *Bookmark, *Folder or *Separator,
+// removes it.
+func WalkReplace(item Item) WalkAction {
+	if isNilItem(item) {
+		return WalkDelete
+	}
+
+	return WalkAction{op: walkReplace, item: item}
+}
+
+// isNilItem returns true if item is nil, or is a nil pointer.
+func isNilItem(item Item) bool {
+	switch item := item.(type) {
+	case *Bookmark:
+		return item == nil
+	case *Folder:
+		return item == nil
+	case *Separator:
+		return item == nil
+	}
+
+	return item == nil
+}
+
+// A WalkFunc is called by Walk for each visited Item, with the path of the
+// Folder containing it, as a chain of folder names.
+//
+// The Item may be modified in place; paths may be retained.
+type WalkFunc func(path []string, item Item) WalkAction
+
+// Walk visits the Bookmarks, Separators and Folders of this Document in
+// document order, starting with the items of the Root Folder and visiting
+// each Folder before its items.
+//
+// The WalkAction returned by fn allows skipping Folders, deleting or replacing
+// Items, and stopping the walk; the Order of the edited Folders is updated
+// accordingly.
+func (d *Document) Walk(fn WalkFunc) {
+	d.Root.walk(Path{}, fn)
+}
+
+// walk visits the items of this Folder and its Subfolders, applies the
+// requested edits, and returns false if the walk has been stopped.
+func (f *Folder) walk(path Path, fn WalkFunc) bool {
+	refs := f.orderedItems()
+
+	// actions to apply to the items of this Folder, if any
+	var actions []WalkAction
+
+	stopped := false
+
+	for i, ref := range refs {
+		item := f.item(ref)
+
+		action := fn(path, item)
+
+		switch action.op {
+		case walkDelete, walkReplace:
+			if actions == nil {
+				actions = make([]WalkAction, len(refs))
+			}
+			actions[i] = action
+		case walkContinue:
+			if subfolder, ok := item.(*Folder); ok {
+				stopped = !subfolder.walk(append(slices.Clip(path), subfolder.Name), fn)
+			}
+		case walkStop:
+			stopped = true
+		}
+
+		if stopped {
+			break
+		}
+	}
+
+	if actions != nil {
+		f.applyWalkActions(refs, actions)
+	}
+
+	return !stopped
+}
+
+// applyWalkActions deletes and replaces the items referenced by refs, and
+// rebuilds the Order of this Folder.
+func (f *Folder) applyWalkActions(refs []ItemRef, actions []WalkAction) {
+	var (
+		bookmarks  []Bookmark
+		subfolders []Folder
+		separators []Separator
+	)
+
+	order := make([]ItemRef, 0, len(refs))
+
+	for i, ref := range refs {
+		var item Item
+
+		switch actions[i].op {
+		case walkDelete:
+			continue
+		case walkReplace:
+			item = actions[i].item
+		default:
+			item = f.item(ref)
+		}
+
+		switch item := item.(type) {
+		case *Bookmark:
+			bookmarks = append(bookmarks, *item)
+			order = append(order, ItemRef{Kind: BookmarkKind, Index: len(bookmarks) - 1})
+		case *Folder:
+			subfolders = append(subfolders, *item)
+			order = append(order, ItemRef{Kind: FolderKind, Index: len(subfolders) - 1})
+		case *Separator:
+			separators = append(separators, *item)
+			order = append(order, ItemRef{Kind: SeparatorKind, Index: len(separators) - 1})
+		}
+	}
+
+	f.Bookmarks = bookmarks
+	f.Subfolders = subfolders
+	f.Separators = separators
+	f.Order = order
+}
@@ -0,0 +1,296 @@
+// Copyright (c) VirtualTam
+// SPDX-License-Identifier: MIT
+
+package netscape
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestDocumentWalk(t *testing.T) {
+	cases := []struct {
+		tname      string
+		fn         WalkFunc
+		wantVisits []string
+		want       Folder
+	}{
+		{
+			tname: "visit all items",
+			fn: func(_ []string, _ Item) WalkAction {
+				return WalkContinue
+			},
+			wantVisits: []string{
+				`[] https://root.domain.tld`,
+				`[] <HR>`,
+				`[] Level 1/`,
+				`["Level 1"] https://l1.domain.tld`,
+				`["Level 1"] Level 2/`,
+				`["Level 1" "Level 2"] https://l2.domain.tld`,
+				`[] https://last.domain.tld`,
+			},
+			want: loadTestDocument(t, "walk.htm").Root,
+		},
+		{
+			tname: "skip folder",
+			fn: func(_ []string, item Item) WalkAction {
+				if folder, ok := item.(*Folder); ok && folder.Name == "Level 2" {
+					return WalkSkip
+				}
+				return WalkContinue
+			},
+			wantVisits: []string{
+				`[] https://root.domain.tld`,
+				`[] <HR>`,
+				`[] Level 1/`,
+				`["Level 1"] https://l1.domain.tld`,
+				`["Level 1"] Level 2/`,
+				`[] https://last.domain.tld`,
+			},
+			want: loadTestDocument(t, "walk.htm").Root,
+		},
+		{
+			tname: "stop",
+			fn: func(_ []string, item Item) WalkAction {
+				if _, ok := item.(*Separator); ok {
+					return WalkStop
+				}
+				return WalkContinue
+			},
+			wantVisits: []string{
+				`[] https://root.domain.tld`,
+				`[] <HR>`,
+			},
+			want: loadTestDocument(t, "walk.htm").Root,
+		},
+		{
+			tname: "update bookmarks",
+			fn: func(_ []string, item Item) WalkAction {
+				if bookmark, ok := item.(*Bookmark); ok && slices.Contains(bookmark.Tags, "old") {
+					bookmark.Tags = []string{"new"}
+				}
+				return WalkContinue
+			},
+			wantVisits: []string{
+				`[] https://root.domain.tld`,
+				`[] <HR>`,
+				`[] Level 1/`,
+				`["Level 1"] https://l1.domain.tld`,
+				`["Level 1"] Level 2/`,
+				`["Level 1" "Level 2"] https://l2.domain.tld`,
+				`[] https://last.domain.tld`,
+			},
+			want: Folder{
+				Name: "Bookmarks",
+				Bookmarks: []Bookmark{
+					{URL: "https://root.domain.tld", Tags: []string{"root"}},
+					{URL: "https://last.domain.tld"},
+				},
+				Separators: []Separator{{}},
+				Subfolders: []Folder{
+					{
+						Name: "Level 1",
+						Bookmarks: []Bookmark{
+							{URL: "https://l1.domain.tld", Tags: []string{"new"}},
+						},
+						Subfolders: []Folder{
+							{
+								Name: "Level 2",
+								Bookmarks: []Bookmark{
+									{URL: "https://l2.domain.tld", Tags: []string{"new"}},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			tname: "delete items",
+			fn: func(path []string, item Item) WalkAction {
+				switch item := item.(type) {
+				case *Separator:
+					return WalkDelete
+				case *Bookmark:
+					if len(path) > 0 {
+						return WalkDelete
+					}
+				case *Folder:
+					if item.Name == "Level 2" {
+						return WalkDelete
+					}
+				}
+				return WalkContinue
+			},
+			wantVisits: []string{
+				`[] https://root.domain.tld`,
+				`[] <HR>`,
+				`[] Level 1/`,
+				`["Level 1"] https://l1.domain.tld`,
+				`["Level 1"] Level 2/`,
+				`[] https://last.domain.tld`,
+			},
+			want: Folder{
+				Name: "Bookmarks",
+				Bookmarks: []Bookmark{
+					{URL: "https://root.domain.tld", Tags: []string{"root"}},
+					{URL: "https://last.domain.tld"},
+				},
+				Subfolders: []Folder{
+					{Name: "Level 1"},
+				},
+				Order: []ItemRef{
+					{Kind: BookmarkKind, Index: 0},
+					{Kind: FolderKind, Index: 0},
+					{Kind: BookmarkKind, Index: 1},
+				},
+			},
+		},
+		{
+			tname: "replace items",
+			fn: func(_ []string, item Item) WalkAction {
+				switch item := item.(type) {
+				case *Separator:
+					return WalkReplace(&Bookmark{URL: "https://separator.domain.tld"})
+				case *Bookmark:
+					if item.URL == "https://root.domain.tld" {
+						return WalkReplace(&Separator{})
+					}
+				case *Folder:
+					return WalkReplace(&Folder{Name: "Replaced"})
+				}
+				return WalkContinue
+			},
+			wantVisits: []string{
+				`[] https://root.domain.tld`,
+				`[] <HR>`,
+				`[] Level 1/`,
+				`[] https://last.domain.tld`,
+			},
+			want: Folder{
+				Name: "Bookmarks",
+				Bookmarks: []Bookmark{
+					{URL: "https://separator.domain.tld"},
+					{URL: "https://last.domain.tld"},
+				},
+				Separators: []Separator{{}},
+				Subfolders: []Folder{
+					{Name: "Replaced"},
+				},
+				Order: []ItemRef{
+					{Kind: SeparatorKind, Index: 0},
+					{Kind: BookmarkKind, Index: 0},
+					{Kind: FolderKind, Index: 0},
+					{Kind: BookmarkKind, Index: 1},
+				},
+			},
+		},
+		{
+			tname: "delete then stop",
+			fn: func(_ []string, item Item) WalkAction {
+				switch item.(type) {
+				case *Separator:
+					return WalkStop
+				case *Bookmark:
+					return WalkDelete
+				}
+				return WalkContinue
+			},
+			wantVisits: []string{
+				`[] https://root.domain.tld`,
+				`[] <HR>`,
+			},
+			want: Folder{
+				Name: "Bookmarks",
+				Bookmarks: []Bookmark{
+					{URL: "https://last.domain.tld"},
+				},
+				Separators: []Separator{{}},
+				Subfolders: []Folder{
+					loadTestDocument(t, "walk.htm").Root.Subfolders[0],
+				},
+				Order: []ItemRef{
+					{Kind: SeparatorKind, Index: 0},
+					{Kind: FolderKind, Index: 0},
+					{Kind: BookmarkKind, Index: 0},
+				},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.tname, func(t *testing.T) {
+			document := loadTestDocument(t, "walk.htm")
+
+			var visits []string
+
+			document.Walk(func(path []string, item Item) WalkAction {
+				switch item := item.(type) {
+				case *Bookmark:
+					visits = append(visits, fmt.Sprintf("%q %s", path, item.URL))
+				case *Folder:
+					visits = append(visits, fmt.Sprintf("%q %s/", path, item.Name))
+				case *Separator:
+					visits = append(visits, fmt.Sprintf("%q <HR>", path))
+				}
+
+				return tc.fn(path, item)
+			})
+
+			if !slices.Equal(visits, tc.wantVisits) {
+				t.Errorf("want visits %q, got %q", tc.wantVisits, visits)
+			}
+
+			assertFoldersEqual(t, document.Root, tc.want)
+		})
+	}
+}
+
+func TestWalkReplaceNil(t *testing.T) {
+	cases := []struct {
+		tname string
+		item  Item
+	}{
+		{
+			tname: "nil",
+		},
+		{
+			tname: "nil bookmark",
+			item:  (*Bookmark)(nil),
+		},
+		{
+			tname: "nil folder",
+			item:  (*Folder)(nil),
+		},
+		{
+			tname: "nil separator",
+			item:  (*Separator)(nil),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.tname, func(t *testing.T) {
+			if got := WalkReplace(tc.item); got != WalkDelete {
+				t.Errorf("want WalkDelete, got %v", got)
+			}
+
+			document := loadTestDocument(t, "walk.htm")
+
+			document.Walk(func(_ []string, item Item) WalkAction {
+				if _, ok := item.(*Separator); ok {
+					return WalkReplace(tc.item)
+				}
+				return WalkSkip
+			})
+
+			if len(document.Root.Separators) != 0 {
+				t.Errorf("want no separators, got %d", len(document.Root.Separators))
+			}
+
+			if len(document.Root.Bookmarks) != 2 {
+				t.Errorf("want 2 bookmarks, got %d", len(document.Root.Bookmarks))
+			}
+		})
+	}
+}